@@ -0,0 +1,249 @@
+package bootstrap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/drew-english/system-configurator/cmd/config"
+	"github.com/drew-english/system-configurator/internal/manifest"
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/internal/secret"
+	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/pkg/run"
+	"github.com/drew-english/system-configurator/pkg/sys/pkgmanager"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	"github.com/spf13/cobra"
+)
+
+// Files in the configuration directory that must not be committed to its git repository.
+var gitIgnored = []string{config.SigningKeyFileName, secret.AgeIdentityFileName}
+
+// IsInteractive reports whether packages can be selected interactively.
+// Provides a hook for testing
+var IsInteractive = termio.IsInteractive
+
+var InitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create a configuration from the packages installed on this machine",
+	Long: `Create a new configuration from the packages explicitly installed on this machine, leaving out the packages installed as their dependencies.
+The packages are selected interactively, or pass --all to include every package or --from-file to include the packages listed in a file, one per line.
+Packages are added without versions, so other machines install the latest version available.
+
+Pass --git to initialize a git repository for the configuration, so it can be shared across machines.
+
+Usage: scfg init [--all | --from-file <file>] [--git] [--force]`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		fromFile, _ := cmd.Flags().GetString("from-file")
+		force, _ := cmd.Flags().GetBool("force")
+		git, _ := cmd.Flags().GetBool("git")
+
+		cfgPath, err := store.LocalConfigPath()
+		if err != nil {
+			return fmt.Errorf("Unable to locate configuration: %w", err)
+		}
+
+		if store.IsRemote(cfgPath) {
+			return fmt.Errorf("Cannot initialize the remote configuration `%s`", cfgPath)
+		}
+
+		if !force && hasPackages(cfgPath) {
+			return fmt.Errorf("Configuration `%s` already exists, pass --force to replace it", cfgPath)
+		}
+
+		if !all && fromFile == "" && !IsInteractive() {
+			return errors.New("Select the packages from a terminal, or pass --all or --from-file")
+		}
+
+		manager, err := pkgmanager.FindPackageManager()
+		if err != nil {
+			return fmt.Errorf("Failed to find the package manager: %w", err)
+		}
+
+		installed, err := manager.ListExplicitPackages(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("Unable to read system packages: %w", err)
+		}
+
+		selected := installed
+		switch {
+		case fromFile != "":
+			if selected, err = selectFromFile(fromFile, installed); err != nil {
+				return err
+			}
+		case !all:
+			termio.Printf("Found %d packages installed with %s:\n", len(installed), manager.Name())
+			if selected, err = selectInteractively(cmd.InOrStdin(), installed); err != nil {
+				return err
+			}
+		}
+
+		cfg := &store.Configuration{Version: store.CurrentVersion, Packages: []*model.Package{}}
+		for _, pkg := range selected {
+			cfg.AddPackage(pkg)
+		}
+
+		localStore, err := store.NewLocal(&store.LocalCfg{Location: filepath.Dir(cfgPath), FileName: filepath.Base(cfgPath)})
+		if err != nil {
+			return fmt.Errorf("Unable to create configuration: %w", err)
+		}
+
+		if err := localStore.WriteConfiguration(cfg); err != nil {
+			return fmt.Errorf("Failed to write configuration: %w", err)
+		}
+
+		termio.Printf("Created `%s` with %d packages\n", cfgPath, len(cfg.Packages))
+		if git {
+			return initGit(cmd, cfgPath)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	InitCmd.Flags().Bool("all", false, "Include every explicitly installed package without asking.")
+	InitCmd.Flags().String("from-file", "", "Include the installed packages listed in the given file, one per line.")
+	InitCmd.Flags().Bool("force", false, "Replace an existing configuration.")
+	InitCmd.Flags().Bool("git", false, "Initialize a git repository for the configuration.")
+	InitCmd.MarkFlagsMutuallyExclusive("all", "from-file")
+}
+
+// hasPackages reports whether the configuration at cfgPath already lists any packages.
+func hasPackages(cfgPath string) bool {
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		return false
+	}
+
+	switch strings.TrimSpace(string(data)) {
+	case "", "{}":
+		return false
+	}
+
+	return true
+}
+
+// selectFromFile selects the installed packages listed in file.
+func selectFromFile(file string, installed []*model.Package) ([]*model.Package, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read `%s`: %w", file, err)
+	}
+
+	listed, err := manifest.Import("list", data, manifest.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read `%s`: %w", file, err)
+	}
+
+	var selected []*model.Package
+	for _, pkg := range listed.Packages {
+		if !slices.ContainsFunc(installed, func(p *model.Package) bool { return p.Name == pkg.Name }) {
+			termio.Warnf("Skipping package `%s`, it is not installed\n", pkg.Name)
+			continue
+		}
+
+		selected = append(selected, pkg)
+	}
+
+	return selected, nil
+}
+
+// selectInteractively lists the packages and asks which of them to include until a valid selection is given.
+func selectInteractively(in io.Reader, pkgs []*model.Package) ([]*model.Package, error) {
+	for i, pkg := range pkgs {
+		termio.Printf("%4d) %s\n", i+1, pkg.Name)
+	}
+
+	scanner := bufio.NewScanner(in)
+	for {
+		termio.Printf("Select the packages to include, e.g. 1-3 5 (all, none) [all]: ")
+		if !scanner.Scan() {
+			return nil, errors.New("No packages were selected")
+		}
+
+		indexes, err := parseSelection(scanner.Text(), len(pkgs))
+		if err != nil {
+			termio.Warnf("%v\n", err)
+			continue
+		}
+
+		selected := make([]*model.Package, 0, len(indexes))
+		for _, i := range indexes {
+			selected = append(selected, pkgs[i])
+		}
+
+		return selected, nil
+	}
+}
+
+// parseSelection parses a selection of 1-based numbers and ranges, or all or none, into indexes of n items.
+func parseSelection(text string, n int) ([]int, error) {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+	if len(fields) == 0 || slices.Equal(fields, []string{"all"}) {
+		fields = []string{fmt.Sprintf("1-%d", n)}
+	} else if slices.Equal(fields, []string{"none"}) {
+		return nil, nil
+	}
+
+	selected := make([]bool, n)
+	for _, field := range fields {
+		from, to, isRange := strings.Cut(field, "-")
+		if !isRange {
+			to = from
+		}
+
+		first, err1 := strconv.Atoi(from)
+		last, err2 := strconv.Atoi(to)
+		if err1 != nil || err2 != nil || first < 1 || last > n || first > last {
+			return nil, fmt.Errorf("Invalid selection `%s`, expected numbers or ranges from 1 to %d", field, n)
+		}
+
+		for i := first; i <= last; i++ {
+			selected[i-1] = true
+		}
+	}
+
+	var indexes []int
+	for i, ok := range selected {
+		if ok {
+			indexes = append(indexes, i)
+		}
+	}
+
+	return indexes, nil
+}
+
+// initGit initializes a git repository for the configuration directory, unless it is already in one.
+func initGit(cmd *cobra.Command, cfgPath string) error {
+	dir := filepath.Dir(cfgPath)
+	if err := run.Command(cmd.Context(), "git", "-C", dir, "rev-parse", "--is-inside-work-tree").Run(); err == nil {
+		termio.Printf("`%s` is already in a git repository\n", dir)
+		return nil
+	}
+
+	if err := run.Command(cmd.Context(), "git", "-C", dir, "init").Run(); err != nil {
+		return fmt.Errorf("Unable to initialize a git repository: %w", err)
+	}
+
+	ignorePath := filepath.Join(dir, ".gitignore")
+	if err := os.WriteFile(ignorePath, []byte(strings.Join(gitIgnored, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("Unable to write `%s`: %w", ignorePath, err)
+	}
+
+	if err := run.Command(cmd.Context(), "git", "-C", dir, "add", filepath.Base(cfgPath), ".gitignore").Run(); err != nil {
+		return fmt.Errorf("Unable to add the configuration to git: %w", err)
+	}
+
+	termio.Printf("Initialized a git repository in `%s`, commit the configuration and push it to share it across machines\n", dir)
+	return nil
+}
@@ -0,0 +1,145 @@
+package bootstrap_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/drew-english/system-configurator/cmd/bootstrap"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	"github.com/drew-english/system-configurator/spec/stub/pkgmanager"
+	"github.com/drew-english/system-configurator/spec/stub/run"
+	"github.com/drew-english/system-configurator/spec/stub/store"
+	termio_stub "github.com/drew-english/system-configurator/spec/stub/termio"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Init", func() {
+	var (
+		stdout, stderr   string
+		userDir, cfgPath string
+		interactive      bool
+		commandStubs     *run.CommandStubManager
+		teardownCmdStubs func(testing.TB)
+
+		s = termio.Style()
+	)
+
+	subject := func(flags ...string) error {
+		for i := 0; i < len(flags); i += 2 {
+			Expect(bootstrap.InitCmd.Flags().Set(flags[i], flags[i+1])).To(Succeed())
+		}
+
+		bootstrap.InitCmd.SetContext(context.Background())
+
+		var err error
+		stdout, stderr = termio_stub.CaptureTermOut(func() {
+			err = bootstrap.InitCmd.RunE(bootstrap.InitCmd, nil)
+		})
+
+		return err
+	}
+
+	config := func() string {
+		data, err := os.ReadFile(cfgPath)
+		Expect(err).ToNot(HaveOccurred())
+		return string(data)
+	}
+
+	BeforeEach(func() {
+		root := GinkgoT().TempDir()
+		userDir = filepath.Join(root, "home")
+		cfgPath = filepath.Join(userDir, "config.yml")
+		DeferCleanup(store.StubLayers(filepath.Join(root, "etc"), userDir, root))
+
+		interactive = false
+		original := bootstrap.IsInteractive
+		bootstrap.IsInteractive = func() bool { return interactive }
+		DeferCleanup(func() { bootstrap.IsInteractive = original })
+
+		commandStubs, teardownCmdStubs = run.StubCommand()
+		DeferCleanup(func() { teardownCmdStubs(GinkgoTB()) })
+		pkgmanager.StubFindPackageManager("apt")
+
+		DeferCleanup(func() {
+			for _, flag := range []string{"all", "force", "git"} {
+				bootstrap.InitCmd.Flags().Set(flag, "false")
+			}
+
+			bootstrap.InitCmd.Flags().Set("from-file", "")
+			bootstrap.InitCmd.SetIn(nil)
+		})
+	})
+
+	It("creates a configuration with every explicitly installed package", func() {
+		commandStubs.Register("apt-mark showmanual", "ripgrep\nfzf\n")
+		Expect(subject("all", "true")).To(Succeed())
+		Expect(stdout).To(Equal("Created `" + cfgPath + "` with 2 packages\n"))
+		Expect(config()).To(Equal("version: 1\npackages:\n  - name: fzf\n  - name: ripgrep\n"))
+	})
+
+	It("selects the installed packages listed in a file", func() {
+		commandStubs.Register("apt-mark showmanual", "ripgrep\nfzf\n")
+		list := filepath.Join(GinkgoT().TempDir(), "packages.txt")
+		Expect(os.WriteFile(list, []byte("fzf\nstow\n"), 0644)).To(Succeed())
+
+		Expect(subject("from-file", list)).To(Succeed())
+		Expect(stderr).To(Equal(s.Yellow("WARNING: ") + "Skipping package `stow`, it is not installed\n"))
+		Expect(config()).To(Equal("version: 1\npackages:\n  - name: fzf\n"))
+	})
+
+	Context("when run from a terminal", func() {
+		BeforeEach(func() {
+			interactive = true
+		})
+
+		It("asks which packages to include until the selection is valid", func() {
+			commandStubs.Register("apt-mark showmanual", "bat\nfzf\nripgrep\nstow\n")
+			bootstrap.InitCmd.SetIn(strings.NewReader("7\n1, 3-4\n"))
+
+			Expect(subject()).To(Succeed())
+			prompt := "Select the packages to include, e.g. 1-3 5 (all, none) [all]: "
+			Expect(stdout).To(Equal("Found 4 packages installed with apt:\n   1) bat\n   2) fzf\n   3) ripgrep\n   4) stow\n" +
+				prompt + prompt + "Created `" + cfgPath + "` with 3 packages\n"))
+			Expect(stderr).To(Equal(s.Yellow("WARNING: ") + "Invalid selection `7`, expected numbers or ranges from 1 to 4\n"))
+			Expect(config()).To(Equal("version: 1\npackages:\n  - name: bat\n  - name: ripgrep\n  - name: stow\n"))
+		})
+	})
+
+	It("refuses to select packages without a terminal", func() {
+		Expect(subject()).To(MatchError("Select the packages from a terminal, or pass --all or --from-file"))
+	})
+
+	Context("when the configuration already has packages", func() {
+		BeforeEach(func() {
+			Expect(os.MkdirAll(userDir, 0755)).To(Succeed())
+			Expect(os.WriteFile(cfgPath, []byte("packages:\n  - bat\n"), 0644)).To(Succeed())
+		})
+
+		It("refuses to replace it", func() {
+			Expect(subject("all", "true")).To(MatchError("Configuration `" + cfgPath + "` already exists, pass --force to replace it"))
+			Expect(config()).To(Equal("packages:\n  - bat\n"))
+		})
+
+		It("replaces it when forced", func() {
+			commandStubs.Register("apt-mark showmanual", "fzf\n")
+			Expect(subject("all", "true", "force", "true")).To(Succeed())
+			Expect(config()).To(Equal("version: 1\npackages:\n  - name: fzf\n"))
+		})
+	})
+
+	It("initializes a git repository for the configuration", func() {
+		commandStubs.Register("apt-mark showmanual", "fzf\n")
+		commandStubs.RegisterError("git -C .* rev-parse --is-inside-work-tree", 128, "not a git repository")
+		commandStubs.Register("git -C .* init", "")
+		commandStubs.Register("git -C .* add config.yml .gitignore", "")
+
+		Expect(subject("all", "true", "git", "true")).To(Succeed())
+		Expect(stdout).To(HaveSuffix("Initialized a git repository in `" + userDir + "`, commit the configuration and push it to share it across machines\n"))
+		Expect(os.ReadFile(filepath.Join(userDir, ".gitignore"))).To(Equal([]byte("signing.key\nage-identity.txt\n")))
+	})
+})
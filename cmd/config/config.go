@@ -0,0 +1,57 @@
+package config
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+
+	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var ConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and maintain the configuration",
+	Long:  `Inspect and maintain the configuration file.`,
+}
+
+// configPaths returns the file given as an argument, or the file of every configuration layer that exists.
+// Remote configurations are skipped, as they cannot be read or written as files.
+func configPaths(args []string) ([]string, error) {
+	if len(args) > 0 {
+		return args[:1], nil
+	}
+
+	layers, err := store.Layers()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, layer := range layers {
+		if layer.Path == "" || store.IsRemote(layer.Path) {
+			continue
+		}
+
+		if _, err := os.Stat(layer.Path); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		paths = append(paths, layer.Path)
+	}
+
+	if len(paths) == 0 {
+		return nil, errors.New("no configuration file exists")
+	}
+
+	return paths, nil
+}
+
+// configPath returns the file given as an argument, or the local configuration.
+func configPath(args []string) (string, error) {
+	if len(args) > 0 {
+		return args[0], nil
+	}
+
+	return store.LocalConfigPath()
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var MigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade the configuration to the latest schema version",
+	Long: `Upgrade a configuration file, by default the file of every configuration layer, to the latest schema version.
+The original file is kept alongside it with a .v<version>.bak suffix. Older configurations are also upgraded in memory whenever they are loaded, this rewrites the file so the upgrade is permanent.
+
+Usage: scfg config migrate [<file>]`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		paths, err := configPaths(args)
+		if err != nil {
+			return fmt.Errorf("Unable to locate configuration: %w", err)
+		}
+
+		var errs []error
+		for _, path := range paths {
+			errs = append(errs, migrateFile(path))
+		}
+
+		return errors.Join(errs...)
+	},
+}
+
+// migrateFile upgrades the configuration at path, keeping the original in a backup file.
+func migrateFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Unable to read configuration: %w", err)
+	}
+
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return fmt.Errorf("Unable to parse `%s`: %w", path, err)
+	}
+
+	from, err := store.MigrateDocument(doc)
+	if err != nil {
+		return fmt.Errorf("Unable to migrate `%s`: %w", path, err)
+	}
+
+	if from == store.CurrentVersion || len(doc.Content) == 0 {
+		termio.Printf("`%s` is already at version %d\n", path, store.CurrentVersion)
+		return nil
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("Unable to encode configuration: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("Unable to read configuration: %w", err)
+	}
+
+	backupPath := fmt.Sprintf("%s.v%d.bak", path, from)
+	if err := os.WriteFile(backupPath, data, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("Unable to back up configuration: %w", err)
+	}
+
+	if err := os.WriteFile(path, buf.Bytes(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("Unable to write configuration: %w", err)
+	}
+
+	termio.Printf("Migrated `%s` from version %d to %d, the original was saved to `%s`\n", path, from, store.CurrentVersion, backupPath)
+	return nil
+}
+
+func init() {
+	ConfigCmd.AddCommand(MigrateCmd)
+}
@@ -0,0 +1,91 @@
+package config_test
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/drew-english/system-configurator/cmd/config"
+	store_stub "github.com/drew-english/system-configurator/spec/stub/store"
+	termio_stub "github.com/drew-english/system-configurator/spec/stub/termio"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Migrate", func() {
+	var (
+		stdout  string
+		cfgPath string
+	)
+
+	subject := func(content string) error {
+		cfgPath = path.Join(GinkgoT().TempDir(), "config.yml")
+		Expect(os.WriteFile(cfgPath, []byte(content), 0600)).To(Succeed())
+
+		var err error
+		stdout, _ = termio_stub.CaptureTermOut(func() {
+			err = config.MigrateCmd.RunE(nil, []string{cfgPath})
+		})
+
+		return err
+	}
+
+	It("upgrades the file and keeps a backup", func() {
+		original := "# packages\npackages:\n  - fzf\n"
+		Expect(subject(original)).To(Succeed())
+		Expect(stdout).To(Equal("Migrated `" + cfgPath + "` from version 0 to 1, the original was saved to `" + cfgPath + ".v0.bak`\n"))
+
+		migrated, _ := os.ReadFile(cfgPath)
+		Expect(string(migrated)).To(Equal("version: 1\n# packages\npackages:\n  - fzf\n"))
+
+		backup, _ := os.ReadFile(cfgPath + ".v0.bak")
+		Expect(string(backup)).To(Equal(original))
+
+		info, _ := os.Stat(cfgPath)
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+	})
+
+	It("does nothing when the file is up to date", func() {
+		Expect(subject("version: 1\npackages: []\n")).To(Succeed())
+		Expect(stdout).To(Equal("`" + cfgPath + "` is already at version 1\n"))
+		Expect(cfgPath + ".v1.bak").ToNot(BeAnExistingFile())
+	})
+
+	It("refuses files from a newer version", func() {
+		Expect(subject("version: 2\n")).To(MatchError(ContainSubstring("configuration version 2 is newer")))
+	})
+
+	Context("without a file", func() {
+		var root string
+
+		BeforeEach(func() {
+			root = GinkgoT().TempDir()
+			Expect(os.Mkdir(filepath.Join(root, ".git"), 0755)).To(Succeed())
+			DeferCleanup(store_stub.StubLayers(filepath.Join(root, "etc"), filepath.Join(root, "home"), root))
+		})
+
+		It("upgrades the file of every configuration layer that exists", func() {
+			systemPath := filepath.Join(root, "etc", "config.yml")
+			projectPath := filepath.Join(root, ".scfg.yml")
+			Expect(os.MkdirAll(filepath.Dir(systemPath), 0755)).To(Succeed())
+			Expect(os.WriteFile(systemPath, []byte("packages:\n  - curl\n"), 0644)).To(Succeed())
+			Expect(os.WriteFile(projectPath, []byte("version: 1\npackages:\n  - go\n"), 0644)).To(Succeed())
+
+			var err error
+			stdout, _ = termio_stub.CaptureTermOut(func() {
+				err = config.MigrateCmd.RunE(nil, nil)
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdout).To(Equal(
+				"Migrated `" + systemPath + "` from version 0 to 1, the original was saved to `" + systemPath + ".v0.bak`\n" +
+					"`" + projectPath + "` is already at version 1\n",
+			))
+		})
+
+		It("returns an error when no configuration file exists", func() {
+			Expect(config.MigrateCmd.RunE(nil, nil)).To(MatchError("Unable to locate configuration: no configuration file exists"))
+		})
+	})
+})
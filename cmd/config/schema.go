@@ -0,0 +1,31 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	"github.com/spf13/cobra"
+)
+
+var SchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the configuration JSON Schema",
+	Long: `Print a JSON Schema document describing the configuration, for editor completion and validation.
+
+Usage: scfg config schema > config.schema.json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		schema, err := store.Schema()
+		if err != nil {
+			return fmt.Errorf("Unable to generate schema: %w", err)
+		}
+
+		termio.Printf("%s\n", schema)
+		return nil
+	},
+}
+
+func init() {
+	ConfigCmd.AddCommand(SchemaCmd)
+}
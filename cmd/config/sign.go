@@ -0,0 +1,137 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/drew-english/system-configurator/internal/signature"
+	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	"github.com/spf13/cobra"
+)
+
+// SigningKeyFileName is the private key written by `scfg config sign --generate-key` in the configuration directory.
+const SigningKeyFileName = "signing.key"
+
+var SignCmd = &cobra.Command{
+	Use:   "sign",
+	Short: "Sign the configuration",
+	Long: `Create a detached signature for a configuration file, the local configuration by default, written next to it with a .minisig suffix.
+Signatures use the minisign format, so they can also be verified with ` + "`minisign -V`" + `.
+
+Configurations with a signature are verified against the keys in the trusted_keys file of the system and user configuration directories whenever they are loaded.
+Packages are only installed from a remote configuration when it is signed by a trusted key.
+
+Use --generate-key to create a signing key, which is also added to your trusted keys. Add its public key to the trusted_keys file of other machines to trust your configurations there.
+
+Usage: scfg config sign [<file>]`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keyPath, err := signingKeyPath(cmd)
+		if err != nil {
+			return fmt.Errorf("Unable to locate signing key: %w", err)
+		}
+
+		if generate, _ := cmd.Flags().GetBool("generate-key"); generate {
+			return generateKey(keyPath)
+		}
+
+		path, err := configPath(args)
+		if err != nil {
+			return fmt.Errorf("Unable to locate configuration: %w", err)
+		}
+
+		keyData, err := os.ReadFile(keyPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("No signing key at `%s`, create one with `scfg config sign --generate-key`", keyPath)
+		} else if err != nil {
+			return fmt.Errorf("Unable to read signing key: %w", err)
+		}
+
+		key, err := signature.ParsePrivateKey(keyData)
+		if err != nil {
+			return fmt.Errorf("Unable to read signing key `%s`: %w", keyPath, err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("Unable to read configuration: %w", err)
+		}
+
+		sigPath := path + signature.Extension
+		if err := os.WriteFile(sigPath, signature.Sign(key, data, filepath.Base(path)), 0644); err != nil {
+			return fmt.Errorf("Unable to write signature: %w", err)
+		}
+
+		termio.Printf("%s Signed `%s` with key %s, the signature was written to `%s`\n", termio.Style().SuccessIcon(), path, key.Public(), sigPath)
+		return nil
+	},
+}
+
+// generateKey creates a signing key, writing its public key alongside it and adding it to the user's trusted keys.
+func generateKey(keyPath string) error {
+	if _, err := os.Stat(keyPath); err == nil {
+		return fmt.Errorf("Signing key `%s` already exists", keyPath)
+	}
+
+	key, err := signature.GenerateKey()
+	if err != nil {
+		return fmt.Errorf("Unable to generate signing key: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0755); err != nil {
+		return fmt.Errorf("Unable to write signing key: %w", err)
+	}
+
+	if err := os.WriteFile(keyPath, key.Marshal(), 0600); err != nil {
+		return fmt.Errorf("Unable to write signing key: %w", err)
+	}
+
+	public := key.Public().Marshal()
+	publicPath := keyPath[:len(keyPath)-len(filepath.Ext(keyPath))] + ".pub"
+	if err := os.WriteFile(publicPath, public, 0644); err != nil {
+		return fmt.Errorf("Unable to write public key: %w", err)
+	}
+
+	userLocation, err := store.LocalDefaultLocation()
+	if err != nil {
+		return fmt.Errorf("Unable to locate trusted keys: %w", err)
+	}
+
+	trustedPath := filepath.Join(userLocation, store.TrustedKeysFileName)
+	trusted, err := os.OpenFile(trustedPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("Unable to update trusted keys: %w", err)
+	}
+	defer trusted.Close()
+
+	if _, err := trusted.Write(public); err != nil {
+		return fmt.Errorf("Unable to update trusted keys: %w", err)
+	}
+
+	termio.Printf("%s Created signing key %s at `%s` and added it to `%s`\n", termio.Style().SuccessIcon(), key.Public(), keyPath, trustedPath)
+	termio.Printf("Trust it on other machines by adding `%s` to their trusted_keys file:\n%s", publicPath, public)
+	return nil
+}
+
+func signingKeyPath(cmd *cobra.Command) (string, error) {
+	if keyPath, _ := cmd.Flags().GetString("key"); keyPath != "" {
+		return keyPath, nil
+	}
+
+	location, err := store.LocalDefaultLocation()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(location, SigningKeyFileName), nil
+}
+
+func init() {
+	SignCmd.Flags().String("key", "", "Sign with the given key instead of the one in the configuration directory.")
+	SignCmd.Flags().Bool("generate-key", false, "Create a signing key instead of signing.")
+	ConfigCmd.AddCommand(SignCmd)
+}
@@ -0,0 +1,85 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/drew-english/system-configurator/cmd/config"
+	"github.com/drew-english/system-configurator/internal/signature"
+	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	store_stub "github.com/drew-english/system-configurator/spec/stub/store"
+	termio_stub "github.com/drew-english/system-configurator/spec/stub/termio"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sign", func() {
+	var (
+		stdout           string
+		userDir, cfgPath string
+
+		s = termio.Style()
+	)
+
+	subject := func(generate bool, args ...string) error {
+		Expect(config.SignCmd.Flags().Set("generate-key", strconv.FormatBool(generate))).To(Succeed())
+
+		var err error
+		stdout, _ = termio_stub.CaptureTermOut(func() {
+			err = config.SignCmd.RunE(config.SignCmd, args)
+		})
+
+		return err
+	}
+
+	BeforeEach(func() {
+		root := GinkgoT().TempDir()
+		userDir = filepath.Join(root, "home")
+		cfgPath = filepath.Join(userDir, "config.yml")
+		DeferCleanup(store_stub.StubLayers(filepath.Join(root, "etc"), userDir, root))
+
+		Expect(os.MkdirAll(userDir, 0755)).To(Succeed())
+		Expect(os.WriteFile(cfgPath, []byte("packages:\n  - fzf\n"), 0644)).To(Succeed())
+	})
+
+	It("generates a key, trusts it and signs the configuration with it", func() {
+		Expect(subject(true)).To(Succeed())
+		keyPath := filepath.Join(userDir, "signing.key")
+		Expect(stdout).To(HavePrefix(s.SuccessIcon() + " Created signing key "))
+		Expect(stdout).To(ContainSubstring("Trust it on other machines by adding `" + filepath.Join(userDir, "signing.pub") + "`"))
+
+		info, err := os.Stat(keyPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+		Expect(subject(false)).To(Succeed())
+		Expect(stdout).To(HaveSuffix("the signature was written to `" + cfgPath + signature.Extension + "`\n"))
+
+		cfg, err := store.LoadConfiguration()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.Packages).To(HaveLen(1))
+
+		sig, err := os.ReadFile(cfgPath + signature.Extension)
+		Expect(err).ToNot(HaveOccurred())
+		data, err := os.ReadFile(cfgPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(signature.Verify(must(store.TrustedKeys()), data, sig)).ToNot(BeNil())
+	})
+
+	It("refuses to replace an existing key", func() {
+		Expect(subject(true)).To(Succeed())
+		Expect(subject(true)).To(MatchError("Signing key `" + filepath.Join(userDir, "signing.key") + "` already exists"))
+	})
+
+	It("returns an error when there is no signing key", func() {
+		Expect(subject(false)).To(MatchError("No signing key at `" + filepath.Join(userDir, "signing.key") + "`, create one with `scfg config sign --generate-key`"))
+	})
+})
+
+func must[T any](v T, err error) T {
+	Expect(err).ToNot(HaveOccurred())
+	return v
+}
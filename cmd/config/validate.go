@@ -0,0 +1,63 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	"github.com/spf13/cobra"
+)
+
+var ValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the configuration",
+	Long: `Validate a configuration file, by default the file of every configuration layer: system, user and project.
+Reports unknown keys, unknown package managers in alternates, duplicate packages and malformed versions, each with its line and column.
+Unknown package managers in alternates are warnings, which do not prevent the configuration from loading.
+
+Usage: scfg config validate [<file>]`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		paths, err := configPaths(args)
+		if err != nil {
+			return fmt.Errorf("Unable to locate configuration: %w", err)
+		}
+
+		var errs []error
+		for _, path := range paths {
+			errs = append(errs, validateFile(path))
+		}
+
+		return errors.Join(errs...)
+	},
+}
+
+// validateFile reports the problems found in the configuration at path, returning an error if it cannot be loaded.
+func validateFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Unable to read configuration: %w", err)
+	}
+
+	err = store.Validate(data)
+	if errs, ok := store.AsValidationErrors(err); ok {
+		for _, validationErr := range errs {
+			termio.PrintErr(fmt.Sprintf("%s:%s\n", path, validationErr))
+		}
+
+		if len(errs.Fatal()) > 0 {
+			return fmt.Errorf("Found %d problems in `%s`", len(errs), path)
+		}
+	} else if err != nil {
+		return fmt.Errorf("Unable to parse `%s`: %w", path, err)
+	}
+
+	termio.Printf("%s `%s` is valid\n", termio.Style().SuccessIcon(), path)
+	return nil
+}
+
+func init() {
+	ConfigCmd.AddCommand(ValidateCmd)
+}
@@ -0,0 +1,104 @@
+package config_test
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/drew-english/system-configurator/cmd/config"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	store_stub "github.com/drew-english/system-configurator/spec/stub/store"
+	termio_stub "github.com/drew-english/system-configurator/spec/stub/termio"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Validate", func() {
+	var (
+		stdout, stderr string
+		cfgPath        string
+
+		s = termio.Style()
+	)
+
+	subject := func(content string) error {
+		cfgPath = path.Join(GinkgoT().TempDir(), "config.yml")
+		Expect(os.WriteFile(cfgPath, []byte(content), 0644)).To(Succeed())
+
+		var err error
+		stdout, stderr = termio_stub.CaptureTermOut(func() {
+			err = config.ValidateCmd.RunE(nil, []string{cfgPath})
+		})
+
+		return err
+	}
+
+	It("reports a valid configuration", func() {
+		Expect(subject("packages:\n  - fzf\n")).To(Succeed())
+		Expect(stdout).To(Equal(s.SuccessIcon() + " `" + cfgPath + "` is valid\n"))
+	})
+
+	It("reports each problem with its position", func() {
+		Expect(subject("packages:\n  - name: fzf\n    alternates: {ap: fzf}\n  - fzf@1.0\n")).To(
+			MatchError("Found 2 problems in `" + cfgPath + "`"),
+		)
+		Expect(stderr).To(Equal(
+			cfgPath + ":3:18: warning: unknown package manager `ap`\n" +
+				cfgPath + ":4:5: duplicate package `fzf`, first defined on line 2\n",
+		))
+	})
+
+	It("reports warnings without failing", func() {
+		Expect(subject("packages:\n  - name: fzf\n    alternates: {flatpak: fzf}\n")).To(Succeed())
+		Expect(stderr).To(Equal(cfgPath + ":3:18: warning: unknown package manager `flatpak`\n"))
+		Expect(stdout).To(Equal(s.SuccessIcon() + " `" + cfgPath + "` is valid\n"))
+	})
+
+	It("returns an error for invalid YAML", func() {
+		Expect(subject("packages:\n  - fzf\n bad")).To(MatchError(HavePrefix("Unable to parse `" + cfgPath + "`: yaml: line 2")))
+	})
+
+	Context("without a file", func() {
+		var systemPath, userPath, projectPath string
+
+		BeforeEach(func() {
+			root := GinkgoT().TempDir()
+			Expect(os.Mkdir(filepath.Join(root, ".git"), 0755)).To(Succeed())
+			DeferCleanup(store_stub.StubLayers(filepath.Join(root, "etc"), filepath.Join(root, "home"), root))
+
+			systemPath = filepath.Join(root, "etc", "config.yml")
+			userPath = filepath.Join(root, "home", "config.yml")
+			projectPath = filepath.Join(root, ".scfg.yml")
+			Expect(os.MkdirAll(filepath.Dir(systemPath), 0755)).To(Succeed())
+			Expect(os.WriteFile(systemPath, []byte("packages:\n  - curl\n  - curl\n"), 0644)).To(Succeed())
+			Expect(os.WriteFile(projectPath, []byte("packages:\n  - go\n"), 0644)).To(Succeed())
+		})
+
+		It("validates the file of every configuration layer that exists", func() {
+			var err error
+			stdout, stderr = termio_stub.CaptureTermOut(func() {
+				err = config.ValidateCmd.RunE(nil, nil)
+			})
+
+			Expect(err).To(MatchError("Found 1 problems in `" + systemPath + "`"))
+			Expect(stderr).To(Equal(systemPath + ":3:5: duplicate package `curl`, first defined on line 2\n"))
+			Expect(stdout).To(Equal(s.SuccessIcon() + " `" + projectPath + "` is valid\n"))
+			Expect(stdout).ToNot(ContainSubstring(userPath))
+		})
+	})
+})
+
+var _ = Describe("Schema", func() {
+	It("prints the JSON Schema", func() {
+		var err error
+		stdout, _ := termio_stub.CaptureTermOut(func() {
+			err = config.SchemaCmd.RunE(nil, nil)
+		})
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(HavePrefix("{\n"))
+		Expect(stdout).To(ContainSubstring(`"$schema": "https://json-schema.org/draft/2020-12/schema"`))
+	})
+
+})
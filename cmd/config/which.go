@@ -0,0 +1,94 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"text/tabwriter"
+
+	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	"github.com/spf13/cobra"
+)
+
+var WhichCmd = &cobra.Command{
+	Use:   "which",
+	Short: "Show where the configuration comes from",
+	Long: `Show the configuration layers and the layer and file each package was loaded from.
+Layers are merged in order, so a package in a later layer overrides the same package in an earlier one:
+
+  system   /etc/system-configurator/config.yml
+  user     $XDG_CONFIG_HOME/system-configurator/config.yml
+  project  .scfg.yml in the current directory or a parent, up to the repository root
+
+Passing --config uses only the given file or URL.
+
+Usage: scfg config which [<package>...]`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := store.LoadConfiguration()
+		if err != nil {
+			return fmt.Errorf("Unable to load configuration: %w", err)
+		}
+
+		names := args
+		if len(names) == 0 {
+			for _, pkg := range cfg.Packages {
+				names = append(names, pkg.Name)
+			}
+		}
+
+		for _, name := range names {
+			if pkg, _ := cfg.FindPackage(name); pkg == nil {
+				return fmt.Errorf("Package `%s` is not in the configuration", name)
+			}
+		}
+
+		w := tabwriter.NewWriter(termio.DefaultIO.Out, 0, 4, 2, ' ', 0)
+		if len(args) == 0 {
+			layers, err := store.Layers()
+			if err != nil {
+				return fmt.Errorf("Unable to locate configuration: %w", err)
+			}
+
+			fmt.Fprintln(w, "LAYER\tPATH")
+			for _, layer := range layers {
+				fmt.Fprintf(w, "%s\t%s\n", layer.Name, layerPath(layer))
+			}
+
+			fmt.Fprintln(w)
+		}
+
+		fmt.Fprintln(w, "PACKAGE\tLAYER\tPATH")
+		for _, name := range names {
+			source, ok := cfg.SourceOf(name)
+			if !ok {
+				source = store.Source{Layer: "-", Path: "-"}
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\n", name, source.Layer, source.Path)
+		}
+
+		return w.Flush()
+	},
+}
+
+func layerPath(layer store.Layer) string {
+	if layer.Path == "" {
+		return "(not found)"
+	}
+
+	if store.IsRemote(layer.Path) {
+		return layer.Path
+	}
+
+	if _, err := os.Stat(layer.Path); errors.Is(err, fs.ErrNotExist) {
+		return layer.Path + " (not found)"
+	}
+
+	return layer.Path
+}
+
+func init() {
+	ConfigCmd.AddCommand(WhichCmd)
+}
@@ -0,0 +1,67 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/drew-english/system-configurator/cmd/config"
+	store_stub "github.com/drew-english/system-configurator/spec/stub/store"
+	termio_stub "github.com/drew-english/system-configurator/spec/stub/termio"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Which", func() {
+	var (
+		stdout             string
+		systemDir, userDir string
+	)
+
+	subject := func(args ...string) error {
+		var err error
+		stdout, _ = termio_stub.CaptureTermOut(func() {
+			err = config.WhichCmd.RunE(nil, args)
+		})
+
+		return err
+	}
+
+	BeforeEach(func() {
+		root := GinkgoT().TempDir()
+		systemDir = filepath.Join(root, "etc")
+		userDir = filepath.Join(root, "home")
+		DeferCleanup(store_stub.StubLayers(systemDir, userDir, root))
+
+		Expect(os.MkdirAll(systemDir, 0755)).To(Succeed())
+		Expect(os.MkdirAll(userDir, 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(systemDir, "config.yml"), []byte("packages:\n  - curl\n  - fzf\n"), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(userDir, "config.yml"), []byte("packages:\n  - fzf@0.50.0\n"), 0644)).To(Succeed())
+	})
+
+	It("shows the layers and where each package came from", func() {
+		Expect(subject()).To(Succeed())
+		Expect(stdout).To(Equal(
+			"LAYER    PATH\n" +
+				"system   " + filepath.Join(systemDir, "config.yml") + "\n" +
+				"user     " + filepath.Join(userDir, "config.yml") + "\n" +
+				"project  (not found)\n" +
+				"\n" +
+				"PACKAGE  LAYER   PATH\n" +
+				"curl     system  " + filepath.Join(systemDir, "config.yml") + "\n" +
+				"fzf      user    " + filepath.Join(userDir, "config.yml") + "\n",
+		))
+	})
+
+	It("shows only the given packages", func() {
+		Expect(subject("curl")).To(Succeed())
+		Expect(stdout).To(Equal(
+			"PACKAGE  LAYER   PATH\n" +
+				"curl     system  " + filepath.Join(systemDir, "config.yml") + "\n",
+		))
+	})
+
+	It("returns an error for a package that is not in the configuration", func() {
+		Expect(subject("bat")).To(MatchError("Package `bat` is not in the configuration"))
+	})
+})
@@ -0,0 +1,46 @@
+package doctor
+
+import (
+	"fmt"
+
+	"github.com/drew-english/system-configurator/internal/doctor"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	"github.com/spf13/cobra"
+)
+
+var DoctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the environment",
+	Long: `Check the environment scfg runs in: the detected vendor, the available package managers, the configuration file of every layer and its contents, and terminal colors.
+Each check passes, warns or fails, with a hint on how to fix any problem. Exits with an error when a check fails.
+
+Usage: scfg doctor`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		style := termio.Style()
+		failed := 0
+		for _, check := range doctor.Checks {
+			result := check.Run()
+
+			icon := style.SuccessIcon()
+			switch result.Status {
+			case doctor.StatusWarn:
+				icon = style.WarningIcon()
+			case doctor.StatusFail:
+				icon = style.FailureIcon()
+				failed++
+			}
+
+			termio.Printf("%s %s: %s\n", icon, style.Bold(check.Name), result.Message)
+			if result.Hint != "" {
+				termio.Printf("    %s\n", style.Gray(result.Hint))
+			}
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d of %d checks failed", failed, len(doctor.Checks))
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,66 @@
+package doctor_test
+
+import (
+	doctor_cmd "github.com/drew-english/system-configurator/cmd/doctor"
+	"github.com/drew-english/system-configurator/internal/doctor"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	termio_stub "github.com/drew-english/system-configurator/spec/stub/termio"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Doctor", func() {
+	var (
+		stdout string
+		checks []doctor.Check
+
+		s = termio.Style()
+	)
+
+	subject := func() error {
+		original := doctor.Checks
+		doctor.Checks = checks
+		defer func() { doctor.Checks = original }()
+
+		var err error
+		stdout, _ = termio_stub.CaptureTermOut(func() {
+			err = doctor_cmd.DoctorCmd.RunE(nil, nil)
+		})
+
+		return err
+	}
+
+	BeforeEach(func() {
+		checks = []doctor.Check{
+			{Name: "First", Run: func() doctor.Result {
+				return doctor.Result{Status: doctor.StatusPass, Message: "all good"}
+			}},
+			{Name: "Second", Run: func() doctor.Result {
+				return doctor.Result{Status: doctor.StatusWarn, Message: "not great", Hint: "do this"}
+			}},
+		}
+	})
+
+	It("prints the result of every check", func() {
+		Expect(subject()).To(Succeed())
+		Expect(stdout).To(Equal(
+			s.SuccessIcon() + " " + s.Bold("First") + ": all good\n" +
+				s.WarningIcon() + " " + s.Bold("Second") + ": not great\n" +
+				"    " + s.Gray("do this") + "\n",
+		))
+	})
+
+	Context("when a check fails", func() {
+		BeforeEach(func() {
+			checks = append(checks, doctor.Check{Name: "Third", Run: func() doctor.Result {
+				return doctor.Result{Status: doctor.StatusFail, Message: "broken", Hint: "fix it"}
+			}})
+		})
+
+		It("returns an error", func() {
+			Expect(subject()).To(MatchError("1 of 3 checks failed"))
+			Expect(stdout).To(ContainSubstring(s.FailureIcon() + " " + s.Bold("Third") + ": broken\n"))
+		})
+	})
+})
@@ -0,0 +1,66 @@
+package exporter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/drew-english/system-configurator/internal/manifest"
+	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	"github.com/spf13/cobra"
+)
+
+var ExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the configuration to another format",
+	Long: `Export the packages of the configuration, resolved for a package manager, to another format. The host's package manager is used unless --manager is given.
+Supported formats:
+- ansible: an Ansible task list installing the packages with the module for the package manager.
+- brewfile: a Homebrew Brewfile, always resolved for brew.
+- dockerfile: a Dockerfile RUN instruction refreshing the package index, installing the packages and cleaning up.
+- nix: a Nix expression listing the packages by their base names.
+- shell: a POSIX shell script installing the packages.
+
+Usage: scfg export --format <format> [--manager <manager>] [--output <file>]`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		manager, _ := cmd.Flags().GetString("manager")
+		output, _ := cmd.Flags().GetString("output")
+
+		cfg, err := store.LoadConfiguration()
+		if err != nil {
+			return fmt.Errorf("Unable to load configuration: %w", err)
+		}
+
+		if len(cfg.Packages) == 0 {
+			return errors.New("The configuration has no packages to export")
+		}
+
+		buf := &bytes.Buffer{}
+		if err := manifest.Export(buf, format, cfg.Packages, manager); err != nil {
+			return fmt.Errorf("Unable to export the configuration: %w", err)
+		}
+
+		if output == "" {
+			termio.Print(buf.String())
+			return nil
+		}
+
+		if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("Unable to write `%s`: %w", output, err)
+		}
+
+		termio.Printf("Exported %d packages to `%s`\n", len(cfg.Packages), output)
+		return nil
+	},
+}
+
+func init() {
+	ExportCmd.Flags().StringP("format", "f", "", "The format to export to: ansible, brewfile, dockerfile, nix or shell.")
+	ExportCmd.Flags().String("manager", "", "The package manager to resolve the packages for, instead of the host's.")
+	ExportCmd.Flags().StringP("output", "o", "", "Write to the given file instead of stdout.")
+	ExportCmd.MarkFlagRequired("format")
+}
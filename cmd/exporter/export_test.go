@@ -0,0 +1,85 @@
+package exporter_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/drew-english/system-configurator/cmd/exporter"
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/spec/stub/store"
+	termio_stub "github.com/drew-english/system-configurator/spec/stub/termio"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Export", func() {
+	var (
+		stdout string
+		cfg    *store.Configuration
+	)
+
+	subject := func(flags map[string]string) error {
+		for name, value := range flags {
+			Expect(exporter.ExportCmd.Flags().Set(name, value)).To(Succeed())
+			DeferCleanup(exporter.ExportCmd.Flags().Set, name, "")
+		}
+
+		var err error
+		stdout, _ = termio_stub.CaptureTermOut(func() {
+			err = exporter.ExportCmd.RunE(exporter.ExportCmd, nil)
+		})
+
+		return err
+	}
+
+	BeforeEach(func() {
+		cfg = &store.Configuration{
+			Packages: []*model.Package{
+				{Name: "fd", Alternates: map[string]*model.Package{"apt": {Name: "fd-find"}}},
+				{Name: "stow", Version: "2.3.1"},
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		store.StubLoadConfiguration(cfg)
+	})
+
+	It("prints the configuration in the format", func() {
+		Expect(subject(map[string]string{"format": "shell", "manager": "apt"})).To(Succeed())
+		Expect(stdout).To(Equal("#!/bin/sh\nset -eu\n\napt install -y \\\n  fd-find \\\n  stow=2.3.1\n"))
+	})
+
+	It("writes the output to a file", func() {
+		output := filepath.Join(GinkgoT().TempDir(), "packages.nix")
+		Expect(subject(map[string]string{"format": "nix", "output": output})).To(Succeed())
+		Expect(stdout).To(Equal("Exported 2 packages to `" + output + "`\n"))
+		Expect(os.ReadFile(output)).To(Equal([]byte("{ pkgs ? import <nixpkgs> { } }:\n\nwith pkgs; [\n  fd\n  stow\n]\n")))
+	})
+
+	It("returns an error for an unknown format", func() {
+		Expect(subject(map[string]string{"format": "rpm"})).To(MatchError("Unable to export the configuration: unknown format `rpm`, expected one of: ansible, brewfile, dockerfile, nix, shell"))
+		Expect(stdout).To(BeEmpty())
+	})
+
+	Context("when the configuration has no packages", func() {
+		BeforeEach(func() {
+			cfg.Packages = nil
+		})
+
+		It("returns an error", func() {
+			Expect(subject(map[string]string{"format": "shell"})).To(MatchError("The configuration has no packages to export"))
+		})
+	})
+
+	Context("when the configuration cannot be loaded", func() {
+		JustBeforeEach(func() {
+			store.StubLoadConfigurationError()
+		})
+
+		It("returns an error", func() {
+			Expect(subject(map[string]string{"format": "shell"})).To(MatchError("Unable to load configuration: error loading configuration"))
+		})
+	})
+})
@@ -0,0 +1,125 @@
+package history
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/drew-english/system-configurator/internal/history"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	"github.com/spf13/cobra"
+)
+
+const shortHashLength = 12
+
+var HistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Browse the history of operations",
+	Long: `Browse the history of operations that modified the system or configuration on this machine.
+Without a subcommand, lists every operation.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ListCmd.RunE(cmd, args)
+	},
+}
+
+var ListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List operations",
+	Long: `List every operation that modified the system or configuration, oldest first.
+
+Usage: scfg history list`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := history.List()
+		if err != nil {
+			return fmt.Errorf("Unable to read history: %w", err)
+		}
+
+		w := tabwriter.NewWriter(termio.DefaultIO.Out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTIME\tCOMMAND\tMODE\tMANAGER\tPACKAGES\tEXIT")
+		for _, entry := range entries {
+			fmt.Fprintf(
+				w,
+				"%d\t%s\t%s\t%s\t%s\t%s\t%d\n",
+				entry.ID,
+				entry.Time.Local().Format("2006-01-02 15:04:05"),
+				entry.Command,
+				entry.Mode,
+				valueOrDash(entry.Manager),
+				valueOrDash(strings.Join(entry.Packages, ", ")),
+				entry.ExitStatus,
+			)
+		}
+
+		return w.Flush()
+	},
+}
+
+var ShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show an operation",
+	Long: `Show the details of an operation.
+
+Usage: scfg history show <id>`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("Invalid history id `%s`", args[0])
+		}
+
+		entry, err := history.Find(id)
+		if err != nil {
+			return fmt.Errorf("Unable to read history: %w", err)
+		}
+
+		if entry == nil {
+			return fmt.Errorf("Unable to find history entry `%d`", id)
+		}
+
+		w := tabwriter.NewWriter(termio.DefaultIO.Out, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "ID:\t%d\n", entry.ID)
+		fmt.Fprintf(w, "Time:\t%s\n", entry.Time.Local().Format("2006-01-02 15:04:05 MST"))
+		fmt.Fprintf(w, "Command:\t%s\n", strings.TrimSpace(entry.Command+" "+strings.Join(entry.Args, " ")))
+		fmt.Fprintf(w, "Mode:\t%s\n", entry.Mode)
+		fmt.Fprintf(w, "Manager:\t%s\n", valueOrDash(entry.Manager))
+		fmt.Fprintf(w, "Packages:\t%s\n", valueOrDash(strings.Join(entry.Packages, ", ")))
+		fmt.Fprintf(w, "Exit status:\t%d\n", entry.ExitStatus)
+		fmt.Fprintf(w, "Config before:\t%s\n", valueOrDash(shortHash(entry.ConfigHashBefore)))
+		fmt.Fprintf(w, "Config after:\t%s\n", valueOrDash(shortHash(entry.ConfigHashAfter)))
+		if entry.Undoes != 0 {
+			fmt.Fprintf(w, "Undoes:\t%d\n", entry.Undoes)
+		}
+
+		fmt.Fprintf(w, "Changes:\t%d\n", len(entry.Steps))
+		for _, step := range entry.Steps {
+			fmt.Fprintf(w, "\t%s `%s`\n", step.Kind, step.Subject())
+		}
+
+		return w.Flush()
+	},
+}
+
+func init() {
+	HistoryCmd.AddCommand(ListCmd)
+	HistoryCmd.AddCommand(ShowCmd)
+}
+
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+func shortHash(hash string) string {
+	if len(hash) > shortHashLength {
+		return hash[:shortHashLength]
+	}
+
+	return hash
+}
@@ -0,0 +1,85 @@
+package history_test
+
+import (
+	"time"
+
+	history_cmd "github.com/drew-english/system-configurator/cmd/history"
+	"github.com/drew-english/system-configurator/internal/history"
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/internal/transaction"
+	termio_stub "github.com/drew-english/system-configurator/spec/stub/termio"
+	"github.com/spf13/viper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("History", func() {
+	var (
+		stdout string
+		now    = time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	)
+
+	BeforeEach(func() {
+		viper.Set("mode", "hybrid")
+		original := history.Now
+		history.Now = func() time.Time { return now }
+		DeferCleanup(func() { history.Now = original })
+
+		entry := history.Begin("package add", []string{"fzf", "zoxide@1.2.3"})
+		entry.Manager = "apt"
+		entry.Packages = []string{"fzf", "zoxide@1.2.3"}
+		entry.ConfigHashBefore = "0123456789abcdef"
+		entry.Steps = []transaction.Step{
+			{Kind: transaction.StepInstall, Manager: "apt", Package: &model.Package{Name: "fzf"}},
+			{Kind: transaction.StepConfigAdd, Package: &model.Package{Name: "fzf"}},
+		}
+		Expect(history.Append(entry)).To(Succeed())
+	})
+
+	Describe("List", func() {
+		It("lists every operation", func() {
+			var err error
+			stdout, _ = termio_stub.CaptureTermOut(func() {
+				err = history_cmd.ListCmd.RunE(nil, nil)
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdout).To(Equal(
+				"ID  TIME                 COMMAND      MODE    MANAGER  PACKAGES           EXIT\n" +
+					"1   2024-03-01 12:00:00  package add  hybrid  apt      fzf, zoxide@1.2.3  0\n",
+			))
+		})
+	})
+
+	Describe("Show", func() {
+		subject := func(id string) error {
+			var err error
+			stdout, _ = termio_stub.CaptureTermOut(func() {
+				err = history_cmd.ShowCmd.RunE(nil, []string{id})
+			})
+
+			return err
+		}
+
+		It("shows the details of the operation", func() {
+			Expect(subject("1")).To(Succeed())
+			Expect(stdout).To(ContainSubstring("Command:        package add fzf zoxide@1.2.3\n"))
+			Expect(stdout).To(ContainSubstring("Config before:  0123456789ab\n"))
+			Expect(stdout).To(ContainSubstring("Config after:   -\n"))
+			Expect(stdout).To(ContainSubstring("Changes:        2\n                install `fzf`\n                config-add `fzf`\n"))
+		})
+
+		Context("when the entry does not exist", func() {
+			It("returns an error", func() {
+				Expect(subject("2")).To(MatchError("Unable to find history entry `2`"))
+			})
+		})
+
+		Context("when the id is invalid", func() {
+			It("returns an error", func() {
+				Expect(subject("latest")).To(MatchError("Invalid history id `latest`"))
+			})
+		})
+	})
+})
@@ -0,0 +1,152 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/drew-english/system-configurator/internal/history"
+	"github.com/drew-english/system-configurator/internal/manifest"
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/internal/transaction"
+	"github.com/drew-english/system-configurator/pkg/sys/pkgmanager"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	"github.com/spf13/cobra"
+)
+
+var ImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import packages from another package manifest",
+	Long: `Import the packages of a manifest from another tool into the configuration. Packages already in the configuration are left unchanged.
+Supported formats:
+- brewfile: a Homebrew Brewfile, importing its brew and cask entries, and its taps as brew repositories. Mac App Store (mas) entries are listed as unsupported.
+- list: one package per line, optionally followed by its version, as printed by ` + "`pacman -Qqe`" + ` or ` + "`apt-mark showmanual`" + `. Use --manager to record the names and versions as alternates for the package manager the list came from.
+- apt-clone: the archive created by ` + "`apt-clone clone`" + ` or its installed.pkgs file, importing the manually installed packages with their exact version as apt alternates.
+
+Use - as the file to read from stdin.
+
+Usage: scfg import <format> <file>`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		entry := history.Begin("import", args)
+		defer func() { entry.Finish(err) }()
+
+		format, file := args[0], args[1]
+		manager, _ := cmd.Flags().GetString("manager")
+		if _, ok := pkgmanager.Managers[manager]; manager != "" && !ok {
+			return fmt.Errorf("Unknown package manager `%s`", manager)
+		}
+
+		data, err := readManifest(cmd, file)
+		if err != nil {
+			return fmt.Errorf("Unable to read `%s`: %w", file, err)
+		}
+
+		imported, err := manifest.Import(format, data, manifest.Options{Manager: manager})
+		if err != nil {
+			return fmt.Errorf("Unable to import `%s`: %w", file, err)
+		}
+
+		for _, skipped := range imported.Skipped {
+			termio.Warnf("Skipping line %d `%s`: %s\n", skipped.Line, skipped.Entry, skipped.Reason)
+		}
+
+		cfg, err := store.LoadConfiguration()
+		if err != nil {
+			return fmt.Errorf("Unable to load configuration: %w", err)
+		}
+
+		entry.TrackConfig(cfg)
+
+		tx, err := transaction.Begin(cmd.Context(), nil, cfg)
+		if err != nil {
+			return err
+		}
+
+		entry.TrackTransaction(tx)
+
+		repositories := 0
+		for _, repo := range imported.Repositories {
+			if repositoryConfigured(cfg, repo) {
+				continue
+			}
+
+			termio.Printf("Adding repository `%s`\n", repo.Name)
+			if err := tx.AddRepositoryToConfig(repo); err != nil {
+				return fmt.Errorf("Failed to add repository `%s`: %w", repo.Name, err)
+			}
+
+			repositories++
+		}
+
+		existing := 0
+		for _, pkg := range imported.Packages {
+			if found, _ := cfg.FindPackage(pkg.Name); found != nil {
+				existing++
+				continue
+			}
+
+			termio.Printf("Adding package `%s`\n", pkg)
+			if err := tx.AddToConfig(pkg); err != nil {
+				return fmt.Errorf("Failed to add package `%s`: %w", pkg, err)
+			}
+
+			entry.Packages = append(entry.Packages, pkg.String())
+		}
+
+		if err := store.WriteConfiguration(cfg); err != nil {
+			return fmt.Errorf("Failed to write configuration: %w", err)
+		}
+
+		termio.Printf("Imported %d packages", len(entry.Packages))
+		if repositories > 0 {
+			termio.Printf(" and %d repositories", repositories)
+		}
+
+		termio.Printf(" from `%s`", file)
+		if existing > 0 {
+			termio.Printf(", %d already in the configuration", existing)
+		}
+
+		termio.Printf("\n")
+		if len(imported.Unsupported) > 0 {
+			termio.Printf("%s %d entries are not supported and were not imported:\n", termio.Style().WarningIcon(), len(imported.Unsupported))
+			for _, unsupported := range imported.Unsupported {
+				termio.Printf("  line %d `%s`: %s\n", unsupported.Line, unsupported.Entry, unsupported.Reason)
+			}
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	ImportCmd.Flags().String("manager", "", "The package manager a list was produced by, recording its names and versions as alternates.")
+}
+
+// repositoryConfigured reports whether repo, or a repository with the same brew tap, is already configured.
+func repositoryConfigured(cfg *store.Configuration, repo *model.Repository) bool {
+	if cfg.FindRepository(repo.Name) != nil {
+		return true
+	}
+
+	tap := repo.For("brew")
+	for _, configured := range cfg.Repositories {
+		if src := configured.For("brew"); src != nil && tap != nil && strings.EqualFold(src.Tap, tap.Tap) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readManifest reads the manifest file, or stdin when the file is -.
+func readManifest(cmd *cobra.Command, file string) ([]byte, error) {
+	if file == "-" {
+		return io.ReadAll(cmd.InOrStdin())
+	}
+
+	return os.ReadFile(file)
+}
@@ -0,0 +1,131 @@
+package importer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/drew-english/system-configurator/cmd/importer"
+	"github.com/drew-english/system-configurator/internal/history"
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/internal/transaction"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	"github.com/drew-english/system-configurator/spec/stub/store"
+	termio_stub "github.com/drew-english/system-configurator/spec/stub/termio"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Import", func() {
+	var (
+		stdout, stderr string
+		cfg            *store.Configuration
+		file           string
+
+		s = termio.Style()
+	)
+
+	subject := func(args ...string) error {
+		importer.ImportCmd.SetContext(context.Background())
+
+		var err error
+		stdout, stderr = termio_stub.CaptureTermOut(func() {
+			err = importer.ImportCmd.RunE(importer.ImportCmd, args)
+		})
+
+		return err
+	}
+
+	BeforeEach(func() {
+		cfg = &store.Configuration{
+			Packages: []*model.Package{{Name: "fzf"}},
+		}
+
+		file = filepath.Join(GinkgoT().TempDir(), "Brewfile")
+		Expect(os.WriteFile(file, []byte("tap \"hashicorp/tap\"\nbrew \"fzf\"\nbrew \"hashicorp/tap/terraform\"\nbrew \"ripgrep\"\nmas \"Xcode\", id: 497799835\n"), 0644)).To(Succeed())
+		DeferCleanup(importer.ImportCmd.Flags().Set, "manager", "")
+	})
+
+	JustBeforeEach(func() {
+		store.StubLoadConfiguration(cfg)
+		store.StubWriteConfiguration()
+	})
+
+	It("adds the packages that are not in the configuration", func() {
+		Expect(subject("brewfile", file)).To(Succeed())
+		Expect(stdout).To(Equal("Adding repository `hashicorp-tap`\nAdding package `terraform`\nAdding package `ripgrep`\n" +
+			"Imported 2 packages and 1 repositories from `" + file + "`, 1 already in the configuration\n" +
+			s.WarningIcon() + " 1 entries are not supported and were not imported:\n" +
+			"  line 5 `mas \"Xcode\", id: 497799835`: Mac App Store apps are not supported\n"))
+		Expect(stderr).To(BeEmpty())
+		Expect(cfg.Packages).To(Equal([]*model.Package{
+			{Name: "fzf"},
+			{Name: "ripgrep"},
+			{Name: "terraform", Tap: "hashicorp/tap"},
+		}))
+		Expect(cfg.Repositories).To(Equal([]*model.Repository{
+			{Name: "hashicorp-tap", Sources: map[string]*model.RepositorySource{"brew": {Tap: "hashicorp/tap"}}},
+		}))
+	})
+
+	Context("when the tap is already configured as a repository", func() {
+		BeforeEach(func() {
+			cfg.Repositories = []*model.Repository{
+				{Name: "hashicorp", Sources: map[string]*model.RepositorySource{"brew": {Tap: "hashicorp/tap"}}},
+			}
+		})
+
+		It("does not add it again", func() {
+			Expect(subject("brewfile", file)).To(Succeed())
+			Expect(stdout).ToNot(ContainSubstring("Adding repository"))
+			Expect(cfg.Repositories).To(HaveLen(1))
+		})
+	})
+
+	It("records the import in the history so it can be undone", func() {
+		Expect(subject("brewfile", file)).To(Succeed())
+
+		entry, err := history.LastUndoable()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entry.Command).To(Equal("import"))
+		Expect(entry.Packages).To(Equal([]string{"terraform", "ripgrep"}))
+		Expect(entry.Steps).To(HaveLen(3))
+		Expect(entry.Steps[0].Kind).To(Equal(transaction.StepConfigAddRepository))
+		Expect(entry.Steps[1:]).To(HaveEach(HaveField("Kind", transaction.StepConfigAdd)))
+	})
+
+	It("reads the manifest from stdin", func() {
+		importer.ImportCmd.SetIn(strings.NewReader("stow\n"))
+		DeferCleanup(func() { importer.ImportCmd.SetIn(nil) })
+
+		Expect(subject("list", "-")).To(Succeed())
+		Expect(stdout).To(Equal("Adding package `stow`\nImported 1 packages from `-`\n"))
+	})
+
+	It("records versions as alternates for the given package manager", func() {
+		Expect(importer.ImportCmd.Flags().Set("manager", "pacman")).To(Succeed())
+		Expect(os.WriteFile(file, []byte("ripgrep 14.1.0-1\n"), 0644)).To(Succeed())
+
+		Expect(subject("list", file)).To(Succeed())
+		Expect(cfg.Packages).To(ContainElement(&model.Package{
+			Name:       "ripgrep",
+			Alternates: map[string]*model.Package{"pacman": {Name: "ripgrep", Version: "14.1.0-1"}},
+		}))
+	})
+
+	It("returns an error for an unknown package manager", func() {
+		Expect(importer.ImportCmd.Flags().Set("manager", "yum")).To(Succeed())
+		Expect(subject("list", file)).To(MatchError("Unknown package manager `yum`"))
+	})
+
+	It("returns an error for an unknown format", func() {
+		Expect(subject("gemfile", file)).To(MatchError("Unable to import `" + file + "`: unknown format `gemfile`, expected one of: apt-clone, brewfile, list"))
+		Expect(stdout).To(BeEmpty())
+	})
+
+	It("returns an error when the file cannot be read", func() {
+		Expect(subject("brewfile", "missing")).To(MatchError(HavePrefix("Unable to read `missing`: ")))
+	})
+})
@@ -0,0 +1,19 @@
+package importer_test
+
+import (
+	"testing"
+
+	"github.com/drew-english/system-configurator/spec/stub/store"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestImporter(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Importer Suite")
+}
+
+var _ = BeforeEach(func() {
+	DeferCleanup(store.StubStateLocation(GinkgoT().TempDir()))
+})
@@ -3,12 +3,16 @@ package pkg
 import (
 	"fmt"
 
+	"github.com/drew-english/system-configurator/internal/history"
 	"github.com/drew-english/system-configurator/internal/mode"
 	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/internal/report"
 	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/internal/transaction"
 	"github.com/drew-english/system-configurator/pkg/sys/pkgmanager"
 	"github.com/drew-english/system-configurator/pkg/termio"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var AddCmd = &cobra.Command{
@@ -17,10 +21,20 @@ var AddCmd = &cobra.Command{
 	Short:   "Add packages",
 	Long: `Add an arbitrary number of packages.
 Packages are specified in the form <package-name>[@<version>], where the version is optional.
+Use --cask to add Homebrew casks and --tap to install the packages from a third-party Homebrew repository.
+When the system is managed, --cask and --tap are refused unless the package manager is brew.
 
-Usage: scfg pkg add <package-name>@<version> <package-name> ...`,
+Usage: scfg pkg add [--cask] [--tap <user>/<repository>] <package-name>@<version> <package-name> ...`,
 	Args: cobra.MinimumNArgs(1),
-	RunE: func(cmd *cobra.Command, args []string) error {
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		entry := history.Begin("package add", args)
+		defer func() { entry.Finish(err) }()
+
+		tap := viper.GetString("tap")
+		if tap != "" && !model.TapRegex.MatchString(tap) {
+			return fmt.Errorf("Invalid tap `%s`, expected <user>/<repository>", tap)
+		}
+
 		pkgsToAdd := make([]*model.Package, 0, len(args))
 		for _, pkgStr := range args {
 			pkg, err := model.ParsePackage(pkgStr)
@@ -28,7 +42,13 @@ Usage: scfg pkg add <package-name>@<version> <package-name> ...`,
 				return err
 			}
 
+			if viper.GetBool("cask") {
+				pkg.Kind = model.KindCask
+			}
+
+			pkg.Tap = tap
 			pkgsToAdd = append(pkgsToAdd, pkg)
+			entry.Packages = append(entry.Packages, pkg.String())
 		}
 
 		var cfg *store.Configuration
@@ -37,6 +57,8 @@ Usage: scfg pkg add <package-name>@<version> <package-name> ...`,
 			if cfg, err = store.LoadConfiguration(); err != nil {
 				return fmt.Errorf("Unable to load configuration: %w", err)
 			}
+
+			entry.TrackConfig(cfg)
 		}
 
 		var manager pkgmanager.PacakgeManager
@@ -45,18 +67,46 @@ Usage: scfg pkg add <package-name>@<version> <package-name> ...`,
 			if manager, err = pkgmanager.FindPackageManager(); err != nil {
 				return fmt.Errorf("Failed to resolve a package manager: %w", err)
 			}
+
+			entry.Manager = manager.Name()
+			if (viper.GetBool("cask") || tap != "") && manager.Name() != "brew" {
+				return fmt.Errorf("--cask and --tap are only supported by brew, not %s", manager.Name())
+			}
+		}
+
+		ctx := commandContext(cmd)
+		tx, err := transaction.Begin(ctx, manager, cfg)
+		if err != nil {
+			return fmt.Errorf("Unable to read system packages: %w", err)
 		}
 
+		entry.TrackTransaction(tx)
+
+		summary := report.NewSummary("add")
 		for _, pkg := range pkgsToAdd {
-			if err := addPackage(cfg, manager, pkg); err != nil {
-				return fmt.Errorf("Failed to add package `%s`: %v\n", pkg, err)
+			if stopProcessing(ctx, summary) {
+				summary.Skipped(pkg.String())
+				continue
 			}
-		}
 
-		if cfg != nil {
-			if err := store.WriteConfiguration(cfg); err != nil {
-				return fmt.Errorf("Failed to write configuration: %w", err)
+			if err := tx.AddPackage(ctx, pkg); err != nil {
+				recordFailure(ctx, summary, pkg.String(), fmt.Sprintf("Failed to add package `%s`", pkg), err)
+				continue
 			}
+
+			summary.Succeeded(pkg.String())
+		}
+
+		if err := rollbackOnFailure(ctx, tx, summary); err != nil {
+			return err
+		}
+
+		if err := writeConfiguration(cfg); err != nil {
+			return err
+		}
+
+		if err := finish(ctx, summary); err != nil {
+			return err
 		}
 
 		termio.Printf("Successfully added %d packages\n", len(pkgsToAdd))
@@ -65,20 +115,20 @@ Usage: scfg pkg add <package-name>@<version> <package-name> ...`,
 }
 
 func init() {
+	AddCmd.Flags().Bool("cask", false, "Add the packages as Homebrew casks.")
+	AddCmd.Flags().String("tap", "", "Install the packages from the given third-party Homebrew repository, as <user>/<repository>.")
+	viper.BindPFlag("cask", AddCmd.Flags().Lookup("cask"))
+	viper.BindPFlag("tap", AddCmd.Flags().Lookup("tap"))
 	PkgCmd.AddCommand(AddCmd)
 }
 
-func addPackage(cfg *store.Configuration, manager pkgmanager.PacakgeManager, pkg *model.Package) error {
-	if manager != nil {
-		if err := manager.AddPackage(pkg); err != nil {
-			return err
-		}
+func writeConfiguration(cfg *store.Configuration) error {
+	if cfg == nil {
+		return nil
 	}
 
-	if cfg != nil {
-		if err := cfg.AddPackage(pkg); err != nil {
-			return err
-		}
+	if err := store.WriteConfiguration(cfg); err != nil {
+		return fmt.Errorf("Failed to write configuration: %w", err)
 	}
 
 	return nil
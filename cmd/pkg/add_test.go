@@ -1,15 +1,21 @@
 package pkg_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/drew-english/system-configurator/cmd/pkg"
+	"github.com/drew-english/system-configurator/internal/history"
 	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/internal/report"
+	sys_pkgmanager "github.com/drew-english/system-configurator/pkg/sys/pkgmanager"
+	"github.com/drew-english/system-configurator/pkg/termio"
 	"github.com/drew-english/system-configurator/spec/stub/pkgmanager"
 	"github.com/drew-english/system-configurator/spec/stub/run"
 	"github.com/drew-english/system-configurator/spec/stub/store"
 	termio_stub "github.com/drew-english/system-configurator/spec/stub/termio"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -18,15 +24,18 @@ import (
 
 var _ = Describe("Add", func() {
 	var (
-		stdout string
-		cfg    *store.Configuration
-		args   []string
+		stdout, stderr string
+		cfg            *store.Configuration
+		args           []string
+		command        *cobra.Command
+
+		s = termio.Style()
 	)
 
 	subject := func() error {
 		var err error
-		stdout, _ = termio_stub.CaptureTermOut(func() {
-			err = pkg.AddCmd.RunE(nil, args)
+		stdout, stderr = termio_stub.CaptureTermOut(func() {
+			err = pkg.AddCmd.RunE(command, args)
 		})
 
 		return err
@@ -34,6 +43,8 @@ var _ = Describe("Add", func() {
 
 	BeforeEach(func() {
 		viper.Set("mode", "configuration")
+		viper.Set("fail-fast", false)
+		command = nil
 		args = []string{"some-new-package@1.2.3", "some-other-package"}
 		cfg = &store.Configuration{
 			Packages: []*model.Package{
@@ -58,6 +69,7 @@ var _ = Describe("Add", func() {
 
 	AfterEach(func() {
 		stdout = ""
+		stderr = ""
 	})
 
 	It("adds the package to the configuration", func() {
@@ -70,7 +82,23 @@ var _ = Describe("Add", func() {
 		Expect(cfg.Packages[1]).To(BeComparableTo(&model.Package{
 			Name: "some-other-package",
 		}, cmpopts.IgnoreUnexported(model.Package{})))
-		Expect(stdout).To(Equal("Successfully added 2 packages\n"))
+		Expect(stdout).To(Equal("\n" +
+			"  PACKAGE                 DETAILS\n" +
+			s.SuccessIcon() + " some-new-package@1.2.3\n" +
+			s.SuccessIcon() + " some-other-package\n" +
+			"Successfully added 2 packages\n"))
+	})
+
+	It("records the operation in the history", func() {
+		Expect(subject()).To(Succeed())
+
+		entries, err := history.List()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Command).To(Equal("package add"))
+		Expect(entries[0].Packages).To(Equal([]string{"some-new-package@1.2.3", "some-other-package"}))
+		Expect(entries[0].ExitStatus).To(Equal(0))
+		Expect(entries[0].ConfigHashAfter).ToNot(Equal(entries[0].ConfigHashBefore))
 	})
 
 	Context("when in a mode that modifies the system", func() {
@@ -90,15 +118,100 @@ var _ = Describe("Add", func() {
 		})
 
 		It("adds the package to the system", func() {
+			commandStubs.Register("apt list --installed", "")
 			commandStubs.Register("apt install -y some-new-package=1.2.3", "package added successfully")
 			commandStubs.Register("apt install -y some-other-package", "package added successfully")
 			Expect(subject()).To(Succeed())
 		})
 
 		Context("when adding a package to the system fails", func() {
-			It("returns an error", func() {
+			It("continues with the remaining packages and returns a partial failure", func() {
+				commandStubs.Register("apt list --installed", "")
 				commandStubs.RegisterError("apt install -y some-new-package=1.2.3", 1, "failed to find package")
-				Expect(subject()).To(MatchError("Failed to add package `some-new-package@1.2.3`: failed to find package\napt: generic error\n"))
+				commandStubs.Register("apt install -y some-other-package", "package added successfully")
+
+				err := subject()
+				Expect(err).To(MatchError("Failed to add 1 of 2 packages"))
+				Expect(err).To(HaveField("Code", report.ExitPartialFailure))
+				Expect(cfg.Packages).To(HaveLen(2))
+				Expect(cfg.Packages[0].Name).To(Equal("some-other-package"))
+				Expect(stderr).To(Equal(s.Yellow("WARNING: ") + "Failed to add package `some-new-package@1.2.3`: failed to find package\napt: generic error\n"))
+				Expect(stdout).To(ContainSubstring(s.FailureIcon() + " some-new-package@1.2.3  failed to find package\n"))
+				Expect(stdout).To(ContainSubstring(s.SuccessIcon() + " some-other-package"))
+			})
+
+			Context("and fail fast is enabled", func() {
+				BeforeEach(func() {
+					viper.Set("fail-fast", true)
+				})
+
+				It("skips the remaining packages and returns a total failure", func() {
+					commandStubs.Register("apt list --installed", "")
+					commandStubs.RegisterError("apt install -y some-new-package=1.2.3", 1, "failed to find package")
+
+					err := subject()
+					Expect(err).To(MatchError("Failed to add 2 of 2 packages"))
+					Expect(err).To(HaveField("Code", report.ExitTotalFailure))
+					Expect(cfg.Packages).To(HaveLen(1))
+					Expect(stdout).To(ContainSubstring(s.WarningIcon() + " some-other-package      not attempted\n"))
+				})
+
+				Context("and an earlier package succeeded", func() {
+					BeforeEach(func() {
+						args = []string{"some-other-package", "some-new-package@1.2.3"}
+					})
+
+					It("rolls back the completed packages", func() {
+						commandStubs.Register("apt list --installed", "")
+						commandStubs.Register("apt install -y some-other-package", "package added successfully")
+						commandStubs.RegisterError("apt install -y some-new-package=1.2.3", 1, "failed to find package")
+						commandStubs.Register("apt remove some-other-package", "package removed successfully")
+
+						err := subject()
+						Expect(err).To(MatchError("Failed to add 2 of 2 packages"))
+						Expect(err).To(HaveField("Code", report.ExitTotalFailure))
+						Expect(cfg.Packages).To(HaveLen(1))
+						Expect(stderr).To(ContainSubstring(s.Yellow("WARNING: ") + "Rolling back completed changes\n"))
+						Expect(stdout).To(ContainSubstring(s.WarningIcon() + " some-other-package      rolled back\n"))
+					})
+				})
+			})
+		})
+
+		Context("when the operation is cancelled", func() {
+			var cancel context.CancelFunc
+
+			BeforeEach(func() {
+				var ctx context.Context
+				ctx, cancel = context.WithCancel(context.Background())
+				command = &cobra.Command{}
+				command.SetContext(ctx)
+			})
+
+			It("records the completed packages and reports what did not complete", func() {
+				commandStubs.Register("apt list --installed", "")
+				commandStubs.Register("apt install -y some-new-package=1.2.3", "package added successfully", func([]string) { cancel() })
+				Expect(subject()).To(MatchError("Operation cancelled: context canceled"))
+				Expect(cfg.Packages).To(HaveLen(2))
+				Expect(cfg.Packages[0].Name).To(Equal("some-new-package"))
+				Expect(stdout).To(ContainSubstring(s.SuccessIcon() + " some-new-package@1.2.3\n"))
+				Expect(stdout).To(ContainSubstring(s.WarningIcon() + " some-other-package      not attempted\n"))
+			})
+
+			Context("and fail fast is enabled", func() {
+				BeforeEach(func() {
+					viper.Set("fail-fast", true)
+				})
+
+				It("rolls back the completed packages", func() {
+					commandStubs.Register("apt list --installed", "")
+					commandStubs.Register("apt install -y some-new-package=1.2.3", "package added successfully", func([]string) { cancel() })
+					commandStubs.Register("apt remove some-new-package", "package removed successfully")
+					Expect(subject()).To(MatchError("Operation cancelled: context canceled"))
+					Expect(cfg.Packages).To(HaveLen(1))
+					Expect(stderr).To(ContainSubstring(s.Yellow("WARNING: ") + "Rolling back completed changes\n"))
+					Expect(stdout).To(ContainSubstring(s.WarningIcon() + " some-new-package@1.2.3  rolled back\n"))
+				})
 			})
 		})
 
@@ -118,6 +231,7 @@ var _ = Describe("Add", func() {
 			})
 
 			It("does not write the configuration", func() {
+				commandStubs.Register("apt list --installed", "")
 				commandStubs.Register("apt install -y some-new-package=1.2.3", "package added successfully")
 				commandStubs.Register("apt install -y some-other-package", "package added successfully")
 				Expect(subject()).To(Succeed())
@@ -126,6 +240,67 @@ var _ = Describe("Add", func() {
 		})
 	})
 
+	Context("when adding Homebrew casks from a tap", func() {
+		BeforeEach(func() {
+			args = []string{"font-fira-code"}
+			viper.Set("cask", true)
+			viper.Set("tap", "homebrew/cask-fonts")
+			DeferCleanup(viper.Set, "cask", false)
+			DeferCleanup(viper.Set, "tap", "")
+		})
+
+		It("records the kind and tap of the packages", func() {
+			Expect(subject()).To(Succeed())
+			Expect(cfg.Packages).To(ContainElement(&model.Package{Name: "font-fira-code", Kind: model.KindCask, Tap: "homebrew/cask-fonts"}))
+		})
+
+		Context("and the package manager is not brew", func() {
+			BeforeEach(func() {
+				viper.Set("mode", "hybrid")
+				pkgmanager.StubFindPackageManager("apt")
+			})
+
+			It("returns an error without changing the configuration", func() {
+				Expect(subject()).To(MatchError("--cask and --tap are only supported by brew, not apt"))
+				Expect(cfg.Packages).To(HaveLen(1))
+			})
+		})
+
+		Context("and the package manager is brew", func() {
+			var commandStubs *run.CommandStubManager
+
+			BeforeEach(func() {
+				viper.Set("mode", "hybrid")
+				pkgmanager.StubFindPackageManager("brew")
+				original := sys_pkgmanager.CasksSupported
+				sys_pkgmanager.CasksSupported = true
+				DeferCleanup(func() { sys_pkgmanager.CasksSupported = original })
+
+				var teardownCmdStubs func(testing.TB)
+				commandStubs, teardownCmdStubs = run.StubCommand()
+				DeferCleanup(teardownCmdStubs, GinkgoTB())
+			})
+
+			It("installs the cask from the tap", func() {
+				commandStubs.Register("brew list --formula --versions", "")
+				commandStubs.Register("brew list --cask --versions", "")
+				commandStubs.Register("brew tap homebrew/cask-fonts", "")
+				commandStubs.Register("brew install --cask font-fira-code", "")
+				Expect(subject()).To(Succeed())
+			})
+		})
+
+		Context("and the tap is malformed", func() {
+			BeforeEach(func() {
+				viper.Set("tap", "homebrew")
+			})
+
+			It("returns an error", func() {
+				Expect(subject()).To(MatchError("Invalid tap `homebrew`, expected <user>/<repository>"))
+			})
+		})
+	})
+
 	Context("when parsing a package fails", func() {
 		BeforeEach(func() {
 			args = []string{"invalid-package@"}
@@ -151,9 +326,11 @@ var _ = Describe("Add", func() {
 			args = []string{"some-package@1.2.3"}
 		})
 
-		It("prints a warning", func() {
+		It("prints a warning and returns a total failure", func() {
 			err := subject()
-			Expect(err).To(MatchError("Failed to add package `some-package@1.2.3`: package already exists in configuration\n"))
+			Expect(err).To(MatchError("Failed to add 1 of 1 packages"))
+			Expect(err).To(HaveField("Code", report.ExitTotalFailure))
+			Expect(stderr).To(Equal(s.Yellow("WARNING: ") + "Failed to add package `some-package@1.2.3`: package already exists in configuration\n"))
 		})
 	})
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/drew-english/system-configurator/internal/history"
 	"github.com/drew-english/system-configurator/internal/model"
 	"github.com/drew-english/system-configurator/internal/store"
 	"github.com/drew-english/system-configurator/pkg/sys/pkgmanager"
@@ -22,8 +23,12 @@ Only modifies configuration, so modes have no effect.
 
 Usage: scfg pkg alt add <base-package-name> <package-name>[@<version>] <manager-name>`,
 	Args: cobra.ExactArgs(3),
-	RunE: func(cmd *cobra.Command, args []string) error {
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		entry := history.Begin("package alternate add", args)
+		defer func() { entry.Finish(err) }()
+
 		basePkgName, altPkgName, mgrName := args[0], args[1], args[2]
+		entry.Packages = []string{basePkgName}
 
 		alternateToAdd, err := model.ParsePackage(altPkgName)
 		if err != nil {
@@ -43,6 +48,8 @@ Usage: scfg pkg alt add <base-package-name> <package-name>[@<version>] <manager-
 			return fmt.Errorf("Unable to load configuration: %w", err)
 		}
 
+		entry.TrackConfig(cfg)
+
 		basePkg, _ := cfg.FindPackage(basePkgName)
 		if basePkg == nil {
 			return fmt.Errorf("Unable to find base package `%s`", basePkgName)
@@ -3,6 +3,8 @@ package alternate_test
 import (
 	"testing"
 
+	"github.com/drew-english/system-configurator/spec/stub/store"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -11,3 +13,7 @@ func TestAlternate(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Alternate Suite")
 }
+
+var _ = BeforeEach(func() {
+	DeferCleanup(store.StubStateLocation(GinkgoT().TempDir()))
+})
@@ -45,7 +45,7 @@ Usage: scfg pkg list`,
 				return fmt.Errorf("Unable to load configuration: %w", err)
 			}
 
-			sysPackages, err = manager.ListPackages()
+			sysPackages, err = manager.ListPackages(commandContext(cmd))
 			if err != nil {
 				return fmt.Errorf("Unable to read system packages: %w", err)
 			}
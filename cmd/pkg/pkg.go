@@ -1,17 +1,104 @@
 package pkg
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	"github.com/drew-english/system-configurator/cmd/pkg/alternate"
+	"github.com/drew-english/system-configurator/internal/report"
+	"github.com/drew-english/system-configurator/internal/transaction"
+	"github.com/drew-english/system-configurator/pkg/termio"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var PkgCmd = &cobra.Command{
 	Use:     "package",
 	Aliases: []string{"pkg"},
 	Short:   "Manage configuration and system packages",
-	Long:    "Manage configuration and system packages.",
+	Long: `Manage configuration and system packages.
+
+By default every package is attempted even if an earlier one fails, and the configuration is written for the packages that succeeded.
+Use --fail-fast to stop at the first failure, or on cancellation, and roll back the changes already made, so the operation is applied completely or not at all.
+Changes are only rolled back with --fail-fast, otherwise the packages that succeeded are kept and can be reverted with scfg undo.
+
+Exit codes: 2 when some packages failed, 3 when no package succeeded.`,
 }
 
 func init() {
 	PkgCmd.AddCommand(alternate.AlternateCmd)
+
+	PkgCmd.PersistentFlags().Bool("fail-fast", false, "Stop at the first package that fails and roll back completed changes.")
+	PkgCmd.PersistentFlags().Bool("keep-going", false, "Attempt every package even if some fail (default).")
+	PkgCmd.MarkFlagsMutuallyExclusive("fail-fast", "keep-going")
+	viper.BindPFlag("fail-fast", PkgCmd.PersistentFlags().Lookup("fail-fast"))
+	viper.BindPFlag("keep-going", PkgCmd.PersistentFlags().Lookup("keep-going"))
+}
+
+// rollbackTimeout bounds a rollback, which still runs when the operation was cancelled.
+const rollbackTimeout = 10 * time.Minute
+
+// commandContext returns the context the command was executed with,
+// falling back to a background context when run directly (e.g. in tests).
+func commandContext(cmd *cobra.Command) context.Context {
+	if cmd != nil && cmd.Context() != nil {
+		return cmd.Context()
+	}
+
+	return context.Background()
+}
+
+func failFast() bool {
+	return viper.GetBool("fail-fast") && !viper.GetBool("keep-going")
+}
+
+// stopProcessing reports whether the remaining packages should be skipped, either because
+// the operation was cancelled or because a package failed while in fail fast mode.
+func stopProcessing(ctx context.Context, summary *report.Summary) bool {
+	return ctx.Err() != nil || (failFast() && summary.Count(report.StatusFailed) > 0)
+}
+
+// recordFailure records a failed package, or a skipped one when the failure was caused by cancellation.
+func recordFailure(ctx context.Context, summary *report.Summary, name, msg string, err error) {
+	if ctx.Err() != nil {
+		summary.Skipped(name)
+		return
+	}
+
+	termio.Warnf("%s: %v\n", msg, err)
+	summary.Failed(name, err)
+}
+
+// rollbackOnFailure reverses the changes made by tx when a package failed or the operation was cancelled in fail fast mode.
+// The rollback is not stopped by the cancellation of ctx, only by rollbackTimeout.
+func rollbackOnFailure(ctx context.Context, tx *transaction.Transaction, summary *report.Summary) error {
+	if !failFast() || (summary.Count(report.StatusFailed) == 0 && ctx.Err() == nil) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+	defer cancel()
+
+	termio.Warn("Rolling back completed changes\n")
+	if err := tx.Rollback(ctx); err != nil {
+		return fmt.Errorf("Failed to roll back changes, run `scfg undo` to retry: %w", err)
+	}
+
+	summary.RolledBack()
+	return nil
+}
+
+// finish prints the summary and returns the resulting error when not every package succeeded.
+func finish(ctx context.Context, summary *report.Summary) error {
+	summary.Print()
+	if summary.Complete() {
+		return nil
+	}
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("Operation cancelled: %w", context.Cause(ctx))
+	}
+
+	return summary.Err()
 }
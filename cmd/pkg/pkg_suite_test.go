@@ -1,8 +1,11 @@
 package pkg_test
 
 import (
+	"path/filepath"
 	"testing"
 
+	"github.com/drew-english/system-configurator/spec/stub/store"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -11,3 +14,8 @@ func TestPkg(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Pkg Suite")
 }
+
+var _ = BeforeEach(func() {
+	DeferCleanup(store.StubStateLocation(GinkgoT().TempDir()))
+	DeferCleanup(store.StubLockPath(filepath.Join(GinkgoT().TempDir(), "config.lock.yml")))
+})
@@ -3,8 +3,11 @@ package pkg
 import (
 	"fmt"
 
+	"github.com/drew-english/system-configurator/internal/history"
 	"github.com/drew-english/system-configurator/internal/mode"
+	"github.com/drew-english/system-configurator/internal/report"
 	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/internal/transaction"
 	"github.com/drew-english/system-configurator/pkg/sys/pkgmanager"
 	"github.com/drew-english/system-configurator/pkg/termio"
 	"github.com/spf13/cobra"
@@ -19,13 +22,19 @@ var RemoveCmd = &cobra.Command{
 
 	Usage: scfg pkg rm <package-name>...`,
 	Args: cobra.MinimumNArgs(1),
-	RunE: func(cmd *cobra.Command, args []string) error {
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		entry := history.Begin("package remove", args)
+		entry.Packages = args
+		defer func() { entry.Finish(err) }()
+
 		var cfg *store.Configuration
 		if mode.ManageConfig() {
 			var err error
 			if cfg, err = store.LoadConfiguration(); err != nil {
 				return fmt.Errorf("Unable to load configuration: %w", err)
 			}
+
+			entry.TrackConfig(cfg)
 		}
 
 		var manager pkgmanager.PacakgeManager
@@ -34,16 +43,43 @@ var RemoveCmd = &cobra.Command{
 			if manager, err = pkgmanager.FindPackageManager(); err != nil {
 				return fmt.Errorf("Failed to resolve a package manager: %w", err)
 			}
+
+			entry.Manager = manager.Name()
 		}
 
+		ctx := commandContext(cmd)
+		tx, err := transaction.Begin(ctx, manager, cfg)
+		if err != nil {
+			return fmt.Errorf("Unable to read system packages: %w", err)
+		}
+
+		entry.TrackTransaction(tx)
+
+		summary := report.NewSummary("remove")
 		for _, pkgName := range args {
-			if err := removePackage(cfg, manager, pkgName); err != nil {
-				return fmt.Errorf("Failed to remove package `%s`: %s\n", pkgName, err)
+			if stopProcessing(ctx, summary) {
+				summary.Skipped(pkgName)
+				continue
 			}
+
+			if err := tx.RemovePackage(ctx, pkgName); err != nil {
+				recordFailure(ctx, summary, pkgName, fmt.Sprintf("Failed to remove package `%s`", pkgName), err)
+				continue
+			}
+
+			summary.Succeeded(pkgName)
+		}
+
+		if err := rollbackOnFailure(ctx, tx, summary); err != nil {
+			return err
 		}
 
-		if err := store.WriteConfiguration(cfg); err != nil {
-			return fmt.Errorf("Failed to write configuration: %w", err)
+		if err := writeConfiguration(cfg); err != nil {
+			return err
+		}
+
+		if err := finish(ctx, summary); err != nil {
+			return err
 		}
 
 		termio.Printf("Successfully removed %d packages\n", len(args))
@@ -54,19 +90,3 @@ var RemoveCmd = &cobra.Command{
 func init() {
 	PkgCmd.AddCommand(RemoveCmd)
 }
-
-func removePackage(cfg *store.Configuration, manager pkgmanager.PacakgeManager, pkgName string) error {
-	if manager != nil {
-		if err := manager.RemovePackage(pkgName); err != nil {
-			return err
-		}
-	}
-
-	if cfg != nil {
-		if err := cfg.RemovePackage(pkgName); err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
@@ -5,6 +5,8 @@ import (
 
 	"github.com/drew-english/system-configurator/cmd/pkg"
 	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/internal/report"
+	"github.com/drew-english/system-configurator/pkg/termio"
 	"github.com/drew-english/system-configurator/spec/stub/pkgmanager"
 	"github.com/drew-english/system-configurator/spec/stub/run"
 	"github.com/drew-english/system-configurator/spec/stub/store"
@@ -17,14 +19,16 @@ import (
 
 var _ = Describe("Remove", func() {
 	var (
-		stdout string
-		cfg    *store.Configuration
-		args   []string
+		stdout, stderr string
+		cfg            *store.Configuration
+		args           []string
+
+		s = termio.Style()
 	)
 
 	subject := func() error {
 		var err error
-		stdout, _ = termio_stub.CaptureTermOut(func() {
+		stdout, stderr = termio_stub.CaptureTermOut(func() {
 			err = pkg.RemoveCmd.RunE(nil, args)
 		})
 
@@ -33,6 +37,7 @@ var _ = Describe("Remove", func() {
 
 	BeforeEach(func() {
 		viper.Set("mode", "configuration")
+		viper.Set("fail-fast", false)
 		args = []string{"some-package"}
 		cfg = &store.Configuration{
 			Packages: []*model.Package{
@@ -57,12 +62,16 @@ var _ = Describe("Remove", func() {
 
 	AfterEach(func() {
 		stdout = ""
+		stderr = ""
 	})
 
 	It("removes the package to the configuration", func() {
 		Expect(subject()).To(Succeed())
 		Expect(cfg.Packages).To(HaveLen(0))
-		Expect(stdout).To(Equal("Successfully removed 1 packages\n"))
+		Expect(stdout).To(Equal("\n" +
+			"  PACKAGE       DETAILS\n" +
+			s.SuccessIcon() + " some-package\n" +
+			"Successfully removed 1 packages\n"))
 	})
 
 	Context("when in a mode that modifies the system", func() {
@@ -82,14 +91,20 @@ var _ = Describe("Remove", func() {
 		})
 
 		It("removes the package to the system", func() {
+			commandStubs.Register("apt list --installed", "some-package/now 1.2.3")
 			commandStubs.Register("apt remove some-package", "package removeed successfully")
 			Expect(subject()).To(Succeed())
 		})
 
 		Context("when removeing a package to the system fails", func() {
 			It("returns an error", func() {
+				commandStubs.Register("apt list --installed", "some-package/now 1.2.3")
 				commandStubs.RegisterError("apt remove some-package", 1, "failed to remove package")
-				Expect(subject()).To(MatchError("Failed to remove package `some-package`: failed to remove package\napt: generic error\n"))
+				err := subject()
+				Expect(err).To(MatchError("Failed to remove 1 of 1 packages"))
+				Expect(err).To(HaveField("Code", report.ExitTotalFailure))
+				Expect(stderr).To(Equal(s.Yellow("WARNING: ") + "Failed to remove package `some-package`: failed to remove package\napt: generic error\n"))
+				Expect(cfg.Packages).To(HaveLen(1))
 			})
 		})
 
@@ -109,6 +124,7 @@ var _ = Describe("Remove", func() {
 			})
 
 			It("does not write the configuration", func() {
+				commandStubs.Register("apt list --installed", "some-package/now 1.2.3")
 				commandStubs.Register("apt remove some-package", "package removeed successfully")
 				Expect(subject()).To(Succeed())
 				Expect(cfg.Packages).To(HaveLen(1))
@@ -131,7 +147,21 @@ var _ = Describe("Remove", func() {
 		})
 
 		It("prints a warning", func() {
-			Expect(subject()).To(MatchError("Failed to remove package `some-other-package`: package does not exist in configuration\n"))
+			Expect(subject()).To(MatchError("Failed to remove 1 of 1 packages"))
+			Expect(stderr).To(Equal(s.Yellow("WARNING: ") + "Failed to remove package `some-other-package`: package does not exist in configuration\n"))
+		})
+
+		Context("and other packages succeed", func() {
+			BeforeEach(func() {
+				args = []string{"some-other-package", "some-package"}
+			})
+
+			It("removes the other packages and returns a partial failure", func() {
+				err := subject()
+				Expect(err).To(MatchError("Failed to remove 1 of 2 packages"))
+				Expect(err).To(HaveField("Code", report.ExitPartialFailure))
+				Expect(cfg.Packages).To(BeEmpty())
+			})
 		})
 	})
 
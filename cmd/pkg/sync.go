@@ -1,32 +1,53 @@
 package pkg
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
+	"github.com/drew-english/system-configurator/internal/graph"
+	"github.com/drew-english/system-configurator/internal/history"
 	"github.com/drew-english/system-configurator/internal/mode"
 	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/internal/report"
 	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/internal/transaction"
+	"github.com/drew-english/system-configurator/pkg/logging"
 	"github.com/drew-english/system-configurator/pkg/sys/pkgmanager"
 	"github.com/drew-english/system-configurator/pkg/termio"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var SyncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Sync packages between configuration and system",
 	Long: `Sync packages between configuration and system. Has different behavior based on the current mode:
-- Configuration: Add packages to the system that are present only in the configuration.
+- Configuration: Add packages to the system that are present only in the configuration. Repositories and packages are installed after the ones they require, and are skipped when a requirement could not be installed.
 - System: Add packages to the configuration that are present only on the system.
 - Hybrid: Two-way sync packages between the configuration and system, only adding packages that are present in one but not the other.
 
-Usage: scfg pkg sync`,
-	RunE: func(cmd *cobra.Command, args []string) error {
+Usage: scfg pkg sync [--locked]`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		entry := history.Begin("package sync", args)
+		defer func() { entry.Finish(err) }()
+
 		configPackages := make(map[string]*model.Package)
 		cfg, err := store.LoadConfiguration()
 		if err != nil {
 			return fmt.Errorf("Unable to load configuration: %w", err)
 		}
 
+		entry.TrackConfig(cfg)
+
+		if mode.ManageConfig() {
+			if unsigned := cfg.UnsignedRemoteSources(); len(unsigned) > 0 {
+				return fmt.Errorf("Refusing to install packages from `%s`, which is not signed by a trusted key", unsigned[0].Path)
+			}
+		}
+
 		cfgPkgList, err := cfg.ResolvedPkgs()
 		if err != nil {
 			termio.Warn("Unable to resolve packages for host manager, showing base configuration\n")
@@ -43,46 +64,362 @@ Usage: scfg pkg sync`,
 			return fmt.Errorf("Failed to find the package manager: %w", err)
 		}
 
-		sysPkgList, err := manager.ListPackages()
+		entry.Manager = manager.Name()
+
+		order, nodes, err := installOrder(cfg)
+		if err != nil {
+			return fmt.Errorf("Unable to order the configuration: %w", err)
+		}
+
+		locked := viper.GetBool("locked")
+		if locked {
+			if !pkgmanager.PinsVersions(manager) {
+				return fmt.Errorf("Unable to install the locked versions, %s cannot install a given version of a package", manager.Name())
+			}
+
+			lock, err := store.LoadLock()
+			if err != nil {
+				return fmt.Errorf("Unable to read lockfile: %w", err)
+			}
+
+			if err := pinLockedVersions(lock, manager.Name(), configPackages); err != nil {
+				return err
+			}
+		}
+
+		ctx := commandContext(cmd)
+		tx, err := transaction.Begin(ctx, manager, cfg)
 		if err != nil {
 			return fmt.Errorf("Unable to read system packages: %w", err)
 		}
 
-		for _, pkg := range sysPkgList {
+		entry.TrackTransaction(tx)
+
+		for _, pkg := range tx.SystemPackages() {
 			sysPackages[pkg.Name] = pkg
 		}
 
+		summary := report.NewSummary("sync")
 		if mode.ManageConfig() {
-			for name, pkg := range configPackages {
-				if _, ok := sysPackages[name]; !ok {
-					managerPackageName := manager.FmtPackageVersion(pkg)
-					termio.Printf("[System] Adding package `%s`\n", managerPackageName)
-					if err := manager.AddPackage(pkg); err != nil {
-						termio.Warnf("[System] Failed to add package `%s`: %v\n", managerPackageName, err)
-					}
-				}
+			configSync := &configurationSync{
+				ctx:       ctx,
+				manager:   manager,
+				tx:        tx,
+				summary:   summary,
+				cfg:       cfg,
+				order:     order,
+				locked:    locked,
+				packages:  configPackages,
+				installed: sysPackages,
+				failed:    make(map[string]bool),
 			}
+
+			configSync.run(nodes)
 		}
 
 		if mode.ManageSystem() {
-			for name, pkg := range sysPackages {
-				if _, ok := configPackages[name]; !ok {
-					termio.Printf("[Configuration] Adding package `%s`\n", pkg)
-					if err := cfg.AddPackage(pkg); err != nil {
-						termio.Warnf("[Configuration] Failed to add package `%s`: %v\n", pkg, err)
-					}
+			for _, name := range sortedNames(sysPackages) {
+				pkg := sysPackages[name]
+				if _, ok := configPackages[name]; ok {
+					continue
 				}
+
+				resultName := "[Configuration] " + pkg.String()
+				if stopProcessing(ctx, summary) {
+					summary.Skipped(resultName)
+					continue
+				}
+
+				termio.Printf("[Configuration] Adding package `%s`\n", pkg)
+				if err := tx.AddToConfig(pkg); err != nil {
+					recordFailure(ctx, summary, resultName, fmt.Sprintf("[Configuration] Failed to add package `%s`", pkg), err)
+					continue
+				}
+
+				summary.Succeeded(resultName)
 			}
+		}
+
+		for _, result := range summary.Results {
+			entry.Packages = append(entry.Packages, result.Name)
+		}
+
+		if err := rollbackOnFailure(ctx, tx, summary); err != nil {
+			return err
+		}
 
+		if mode.ManageSystem() {
 			if err := store.WriteConfiguration(cfg); err != nil {
 				return fmt.Errorf("Failed to write configuration: %w", err)
 			}
 		}
 
-		return nil
+		// The lockfile is only updated once every package is synced, so it never records a partial sync.
+		if !locked && summary.Complete() {
+			updateLock(ctx, manager, cfg, tx)
+		}
+
+		return finish(ctx, summary)
 	},
 }
 
+// configurationSync installs the repositories and packages of the configuration onto the system.
+type configurationSync struct {
+	ctx       context.Context
+	manager   pkgmanager.PacakgeManager
+	tx        *transaction.Transaction
+	summary   *report.Summary
+	cfg       *store.Configuration
+	order     *graph.Graph
+	locked    bool
+	packages  map[string]*model.Package // configured packages, by the name the manager installs them with
+	installed map[string]*model.Package // system packages, by name
+	failed    map[string]bool           // requirements that were not installed
+}
+
+// installOrder orders the repositories and packages of the configuration so that each comes after the
+// repositories and packages it requires, returning the graph of requirements and the ordered requirements.
+// A package installed from a repository requires it.
+func installOrder(cfg *store.Configuration) (*graph.Graph, []string, error) {
+	g := graph.New()
+	for _, repo := range cfg.Repositories {
+		requires, err := parseRequirements(repo.Requires)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		g.Add(model.Requirement(model.RequireRepository, repo.Name), requires...)
+	}
+
+	for _, pkg := range cfg.Packages {
+		requires, err := parseRequirements(pkg.Requires)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if pkg.Repository != "" {
+			requires = append(requires, model.Requirement(model.RequireRepository, pkg.Repository))
+		}
+
+		g.Add(model.Requirement(model.RequirePackage, pkg.Name), requires...)
+	}
+
+	nodes, err := g.Sort()
+	return g, nodes, err
+}
+
+func parseRequirements(requires []string) ([]string, error) {
+	parsed := make([]string, 0, len(requires))
+	for _, r := range requires {
+		requirement, err := model.ParseRequirement(r)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed = append(parsed, requirement)
+	}
+
+	return parsed, nil
+}
+
+// run installs the repositories and packages in order, skipping those that require one that was not installed.
+func (s *configurationSync) run(nodes []string) {
+	for _, node := range nodes {
+		kind, name, _ := strings.Cut(node, ":")
+		switch kind {
+		case model.RequireRepository:
+			s.failed[node] = !s.repository(node, s.cfg.FindRepository(name))
+		case model.RequirePackage:
+			pkg, _ := s.cfg.FindPackage(name)
+			s.failed[node] = !s.pkg(node, pkg)
+		}
+	}
+}
+
+// blocked returns the first requirement of node that was not installed, which is empty when there is none.
+func (s *configurationSync) blocked(node string) string {
+	for _, required := range s.order.Requires(node) {
+		if s.failed[required] {
+			return required
+		}
+	}
+
+	return ""
+}
+
+// skip records an item that is not attempted, returning true when it was skipped.
+func (s *configurationSync) skip(node, resultName string) bool {
+	if stopProcessing(s.ctx, s.summary) {
+		s.summary.Skipped(resultName)
+		return true
+	}
+
+	if required := s.blocked(node); required != "" {
+		s.summary.SkippedFor(resultName, fmt.Sprintf("requires `%s`, which was not installed", required))
+		return true
+	}
+
+	return false
+}
+
+// repository adds a repository available for the manager, returning whether it is configured.
+func (s *configurationSync) repository(node string, repo *model.Repository) bool {
+	if repo.For(s.manager.Name()) == nil {
+		logging.Debug("repository unavailable for package manager", "repository", repo.Name, "manager", s.manager.Name())
+		return true
+	}
+
+	resultName := "[System] repository " + repo.Name
+	if s.skip(node, resultName) {
+		return false
+	}
+
+	added, err := s.tx.AddRepository(s.ctx, repo)
+	if err != nil {
+		recordFailure(s.ctx, s.summary, resultName, fmt.Sprintf("[System] Failed to add repository `%s`", repo.Name), err)
+		return false
+	}
+
+	if added {
+		termio.Printf("[System] Added repository `%s`\n", repo.Name)
+		s.summary.Succeeded(resultName)
+	}
+
+	return true
+}
+
+// pkg installs a configured package that is not on the system, returning whether it is installed.
+func (s *configurationSync) pkg(node string, base *model.Package) bool {
+	name := base.ForManager(s.manager.Name()).Name
+	pkg := s.packages[name]
+	if installed, ok := s.installed[name]; ok && (!s.locked || installed.Version == pkg.Version) {
+		return true
+	}
+
+	managerPackageName := s.manager.FmtPackageVersion(pkg)
+	if err := pkgmanager.Supports(s.manager, pkg); err != nil {
+		termio.Warnf("[System] Skipping package `%s`: %v\n", managerPackageName, err)
+		return false
+	}
+
+	resultName := "[System] " + managerPackageName
+	if s.skip(node, resultName) {
+		return false
+	}
+
+	termio.Printf("[System] Adding package `%s`\n", managerPackageName)
+	err := s.tx.Install(s.ctx, pkg)
+	if err == nil && s.locked {
+		err = s.verifyLocked(pkg)
+	}
+
+	if err != nil {
+		recordFailure(s.ctx, s.summary, resultName, fmt.Sprintf("[System] Failed to add package `%s`", managerPackageName), err)
+		return false
+	}
+
+	s.summary.Succeeded(resultName)
+	return true
+}
+
+// verifyLocked returns an error when the manager did not install the locked version of pkg.
+func (s *configurationSync) verifyLocked(pkg *model.Package) error {
+	installed, err := s.manager.ListPackages(s.ctx)
+	if err != nil {
+		return fmt.Errorf("unable to verify the installed version: %w", err)
+	}
+
+	for _, p := range installed {
+		if p.Name != pkg.Name {
+			continue
+		}
+
+		if p.Version != pkg.Version {
+			return fmt.Errorf("installed version %s instead of the locked version %s", p.Version, pkg.Version)
+		}
+
+		return nil
+	}
+
+	return errors.New("the package is not installed after adding it")
+}
+
+func sortedNames(pkgs map[string]*model.Package) []string {
+	names := make([]string, 0, len(pkgs))
+	for name := range pkgs {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+	return names
+}
+
+// pinLockedVersions replaces the version of each package with the version in the lockfile,
+// returning an error when any package is not locked.
+func pinLockedVersions(lock *store.Lock, managerName string, packages map[string]*model.Package) error {
+	var missing []string
+	for name, pkg := range packages {
+		version, ok := lock.Locked(managerName, name)
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+
+		pinned := *pkg
+		pinned.Version = version
+		packages[name] = &pinned
+	}
+
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return fmt.Errorf("Packages are not locked for %s: %s\nRun `scfg pkg sync` without --locked to lock them", managerName, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// updateLock records the installed version of each package in the configuration in the lockfile, keeping the
+// locked version of the packages that are not installed. Packages no longer in the configuration are unlocked.
+func updateLock(ctx context.Context, manager pkgmanager.PacakgeManager, cfg *store.Configuration, tx *transaction.Transaction) {
+	installed := tx.SystemPackages()
+	if tx.Installs() {
+		pkgs, err := manager.ListPackages(ctx)
+		if err != nil {
+			termio.Warnf("Unable to update the lockfile: %v\n", err)
+			return
+		}
+
+		installed = pkgs
+	}
+
+	versions := make(map[string]string, len(installed))
+	for _, pkg := range installed {
+		versions[pkg.Name] = pkg.Version
+	}
+
+	lock, err := store.LoadLock()
+	if err == nil {
+		locked := make(map[string]string)
+		for _, pkg := range cfg.Packages {
+			name := pkg.ForManager(manager.Name()).Name
+			if version := versions[name]; version != "" {
+				locked[name] = version
+			} else if version, ok := lock.Locked(manager.Name(), name); ok {
+				locked[name] = version
+			}
+		}
+
+		lock.SetManager(manager.Name(), locked)
+		err = store.WriteLock(lock)
+	}
+
+	if err != nil {
+		termio.Warnf("Unable to update the lockfile: %v\n", err)
+	}
+}
+
 func init() {
+	SyncCmd.Flags().Bool("locked", false, "Install exactly the versions in the lockfile, failing for packages that are not locked or not installed at the locked version.")
+	viper.BindPFlag("locked", SyncCmd.Flags().Lookup("locked"))
 	PkgCmd.AddCommand(SyncCmd)
 }
@@ -1,10 +1,20 @@
 package pkg_test
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/drew-english/system-configurator/cmd/pkg"
+	"github.com/drew-english/system-configurator/internal/history"
 	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/internal/report"
+	"github.com/drew-english/system-configurator/internal/secret"
+	internal_store "github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/internal/transaction"
+	sys_pkgmanager "github.com/drew-english/system-configurator/pkg/sys/pkgmanager"
 	"github.com/drew-english/system-configurator/pkg/termio"
 	"github.com/drew-english/system-configurator/spec/stub/pkgmanager"
 	"github.com/drew-english/system-configurator/spec/stub/run"
@@ -39,6 +49,7 @@ var _ = Describe("Sync", func() {
 
 	BeforeEach(func() {
 		manager = "apt"
+		viper.Set("fail-fast", false)
 		commandStubs, teardownCmdStubs = run.StubCommand()
 		cfg = &store.Configuration{
 			Packages: []*model.Package{
@@ -73,21 +84,319 @@ var _ = Describe("Sync", func() {
 	It("syncs the packages to the system from the configuration", func() {
 		commandStubs.Register("apt list --installed", "apt-some-sys-package/now 1.2.3")
 		commandStubs.Register("apt install -y apt-some-package=1.2.3", "successfully installed package")
+		commandStubs.Register("apt list --installed", "apt-some-package/now 1.2.3\napt-some-sys-package/now 1.2.3")
 		Expect(subject()).To(Succeed())
-		Expect(stdout).To(Equal("[System] Adding package `apt-some-package=1.2.3`\n"))
+		Expect(stdout).To(Equal("[System] Adding package `apt-some-package=1.2.3`\n\n" +
+			"  PACKAGE                          DETAILS\n" +
+			s.SuccessIcon() + " [System] apt-some-package=1.2.3\n"))
 		Expect(stderr).To(BeEmpty())
 	})
 
+	It("records the installed versions in the lockfile", func() {
+		commandStubs.Register("apt list --installed", "apt-some-sys-package/now 1.2.3")
+		commandStubs.Register("apt install -y apt-some-package=1.2.3", "successfully installed package")
+		commandStubs.Register("apt list --installed", "apt-some-package/now 1.2.4\napt-some-sys-package/now 1.2.3")
+		Expect(subject()).To(Succeed())
+
+		lock, err := internal_store.LoadLock()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lock.Managers).To(Equal(map[string]map[string]string{
+			"apt": {"apt-some-package": "1.2.4"},
+		}))
+	})
+
+	It("keeps the locked versions of configured packages that are not installed", func() {
+		cfg.Packages = append(cfg.Packages, &model.Package{Name: "other-package"})
+		cfg.Packages[1].Alternates = map[string]*model.Package{"apt": {Name: "other-package"}}
+		lock := &internal_store.Lock{}
+		lock.SetManager("apt", map[string]string{"other-package": "2.0.0", "removed-package": "1.0.0"})
+		Expect(internal_store.WriteLock(lock)).To(Succeed())
+
+		viper.Set("mode", "system")
+		commandStubs.Register("apt list --installed", "apt-some-package/now 1.2.3")
+		Expect(subject()).To(Succeed())
+
+		lock, err := internal_store.LoadLock()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lock.Managers["apt"]).To(Equal(map[string]string{"apt-some-package": "1.2.3", "other-package": "2.0.0"}))
+	})
+
+	It("does not update the lockfile when a package fails", func() {
+		commandStubs.Register("apt list --installed", "")
+		commandStubs.RegisterError("apt install -y apt-some-package=1.2.3", 1, "failed to install package")
+		Expect(subject()).ToNot(Succeed())
+
+		lock, err := internal_store.LoadLock()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lock.Managers).To(BeEmpty())
+	})
+
+	Context("when syncing the locked versions", func() {
+		BeforeEach(func() {
+			viper.Set("locked", true)
+			DeferCleanup(viper.Set, "locked", false)
+		})
+
+		Context("and the packages are locked", func() {
+			BeforeEach(func() {
+				lock := &internal_store.Lock{}
+				lock.SetManager("apt", map[string]string{"apt-some-package": "1.2.0"})
+				Expect(internal_store.WriteLock(lock)).To(Succeed())
+			})
+
+			It("installs the locked versions without updating the lockfile", func() {
+				commandStubs.Register("apt list --installed", "apt-some-package/now 1.2.3")
+				commandStubs.Register("apt install -y apt-some-package=1.2.0", "successfully installed package")
+				commandStubs.Register("apt list --installed", "apt-some-package/now 1.2.0")
+				Expect(subject()).To(Succeed())
+				Expect(stdout).To(Equal("[System] Adding package `apt-some-package=1.2.0`\n\n" +
+					"  PACKAGE                          DETAILS\n" +
+					s.SuccessIcon() + " [System] apt-some-package=1.2.0\n"))
+				Expect(stderr).To(BeEmpty())
+
+				lock, err := internal_store.LoadLock()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(lock.Managers["apt"]).To(Equal(map[string]string{"apt-some-package": "1.2.0"}))
+			})
+
+			It("returns an error when the manager cannot install a locked version", func() {
+				commandStubs.Register("apt list --installed", "")
+				commandStubs.RegisterError("apt install -y apt-some-package=1.2.0", 100, "Version '1.2.0' for 'apt-some-package' was not found")
+				err := subject()
+				Expect(err).To(MatchError("Failed to sync 1 of 1 packages"))
+				Expect(err).To(HaveField("Code", report.ExitTotalFailure))
+			})
+
+			It("fails the package when the manager installs a different version", func() {
+				commandStubs.Register("apt list --installed", "")
+				commandStubs.Register("apt install -y apt-some-package=1.2.0", "successfully installed package")
+				commandStubs.Register("apt list --installed", "apt-some-package/now 1.2.3")
+				err := subject()
+				Expect(err).To(MatchError("Failed to sync 1 of 1 packages"))
+				Expect(stdout).To(ContainSubstring(s.FailureIcon() + " [System] apt-some-package=1.2.0  installed version 1.2.3 instead of the locked version 1.2.0\n"))
+			})
+		})
+
+		Context("and the package manager cannot install a given version", func() {
+			BeforeEach(func() {
+				manager = "brew"
+				lock := &internal_store.Lock{}
+				lock.SetManager("brew", map[string]string{"some-package": "1.2.0"})
+				Expect(internal_store.WriteLock(lock)).To(Succeed())
+			})
+
+			It("returns an error without changing the system", func() {
+				Expect(subject()).To(MatchError("Unable to install the locked versions, brew cannot install a given version of a package"))
+				Expect(stdout).To(BeEmpty())
+			})
+		})
+
+		Context("and a package is not locked", func() {
+			It("returns an error without changing the system", func() {
+				Expect(subject()).To(MatchError("Packages are not locked for apt: apt-some-package\nRun `scfg pkg sync` without --locked to lock them"))
+				Expect(stdout).To(BeEmpty())
+			})
+		})
+	})
+
 	Context("when the pacakge manager fails to add the package", func() {
-		It("logs a warning and continues", func() {
+		It("logs a warning, prints a summary and returns a failure", func() {
 			commandStubs.Register("apt list --installed", "apt-some-sys-package/now 1.2.3")
 			commandStubs.RegisterError("apt install -y apt-some-package=1.2.3", 1, "failed to install package")
-			Expect(subject()).To(Succeed())
-			Expect(stdout).To(Equal("[System] Adding package `apt-some-package=1.2.3`\n"))
+			err := subject()
+			Expect(err).To(MatchError("Failed to sync 1 of 1 packages"))
+			Expect(err).To(HaveField("Code", report.ExitTotalFailure))
+			Expect(stdout).To(Equal("[System] Adding package `apt-some-package=1.2.3`\n\n" +
+				"  PACKAGE                          DETAILS\n" +
+				s.FailureIcon() + " [System] apt-some-package=1.2.3  failed to install package\n"))
 			Expect(stderr).To(Equal(s.Yellow("WARNING: ") + "[System] Failed to add package `apt-some-package=1.2.3`: failed to install package\napt: generic error\n"))
 		})
 	})
 
+	Context("when a package is a cask and casks are not supported", func() {
+		BeforeEach(func() {
+			manager = "brew"
+			cfg.Packages = append(cfg.Packages, &model.Package{Name: "firefox", Kind: model.KindCask})
+
+			original := sys_pkgmanager.CasksSupported
+			sys_pkgmanager.CasksSupported = false
+			DeferCleanup(func() { sys_pkgmanager.CasksSupported = original })
+		})
+
+		It("skips the cask with a warning", func() {
+			commandStubs.Register("brew list --formula --versions", "some-package 1.2.3")
+			Expect(subject()).To(Succeed())
+			Expect(stdout).To(BeEmpty())
+			Expect(stderr).To(Equal(s.Yellow("WARNING: ") + "[System] Skipping package `firefox`: cask `firefox` is not supported on this system, casks are only available on macOS\n"))
+		})
+	})
+
+	Context("when packages are installed from a repository", func() {
+		BeforeEach(func() {
+			manager = "dnf"
+			cfg.Repositories = []*model.Repository{
+				{Name: "docker", Sources: map[string]*model.RepositorySource{"dnf": {URL: "https://download.docker.com/linux/fedora/$releasever/$basearch/stable"}}},
+				{Name: "hashicorp", Sources: map[string]*model.RepositorySource{"brew": {Tap: "hashicorp/tap"}}},
+			}
+			cfg.Packages = []*model.Package{{Name: "docker-ce", Repository: "docker"}}
+
+			original := sys_pkgmanager.RootDir
+			sys_pkgmanager.RootDir = GinkgoT().TempDir()
+			DeferCleanup(func() { sys_pkgmanager.RootDir = original })
+		})
+
+		It("adds the repositories available for the manager before installing the packages", func() {
+			commandStubs.Register("dnf list --installed", "")
+			commandStubs.Register("dnf install -y docker-ce", "")
+			commandStubs.Register("dnf list --installed", "docker-ce.x86_64  26.1.0-1.fc40  docker-ce-stable")
+			Expect(subject()).To(Succeed())
+			Expect(stdout).To(Equal("[System] Added repository `docker`\n[System] Adding package `docker-ce`\n\n" +
+				"  PACKAGE                     DETAILS\n" +
+				s.SuccessIcon() + " [System] repository docker\n" +
+				s.SuccessIcon() + " [System] docker-ce\n"))
+			Expect(stderr).To(BeEmpty())
+			Expect(filepath.Join(sys_pkgmanager.RootDir, "etc/yum.repos.d/docker.repo")).To(BeAnExistingFile())
+		})
+
+		It("journals the repository so it can be undone", func() {
+			commandStubs.Register("dnf list --installed", "")
+			commandStubs.Register("dnf install -y docker-ce", "")
+			commandStubs.Register("dnf list --installed", "docker-ce.x86_64  26.1.0-1.fc40  docker-ce-stable")
+			Expect(subject()).To(Succeed())
+
+			entry, err := history.LastUndoable()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entry.Steps[0]).To(Equal(transaction.Step{Kind: transaction.StepAddRepository, Manager: "dnf", Repository: cfg.Repositories[0]}))
+		})
+
+		Context("and a package fails with fail fast enabled", func() {
+			BeforeEach(func() {
+				viper.Set("fail-fast", true)
+			})
+
+			It("removes the repository it added", func() {
+				commandStubs.Register("dnf list --installed", "")
+				commandStubs.RegisterError("dnf install -y docker-ce", 1, "No match for argument: docker-ce")
+				Expect(subject()).To(MatchError("Failed to sync 2 of 2 packages"))
+				Expect(stdout).To(ContainSubstring(s.WarningIcon() + " [System] repository docker  rolled back\n"))
+				Expect(filepath.Join(sys_pkgmanager.RootDir, "etc/yum.repos.d/docker.repo")).NotTo(BeAnExistingFile())
+			})
+		})
+
+		Context("and the repository references a secret", func() {
+			BeforeEach(func() {
+				cfg.Repositories[0].Sources["dnf"].URL = `https://{{ secret "docker_token" }}@download.docker.com/linux/fedora`
+				GinkgoT().Setenv("SCFG_SECRET_DOCKER_TOKEN", "dckr_0123456789")
+			})
+
+			It("writes the secret to the repository without journaling it", func() {
+				commandStubs.Register("dnf list --installed", "")
+				commandStubs.Register("dnf install -y docker-ce", "")
+				commandStubs.Register("dnf list --installed", "docker-ce.x86_64  26.1.0-1.fc40  docker-ce-stable")
+				Expect(subject()).To(Succeed())
+
+				repoFile, err := os.ReadFile(filepath.Join(sys_pkgmanager.RootDir, "etc/yum.repos.d/docker.repo"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(repoFile)).To(ContainSubstring("baseurl=https://dckr_0123456789@download.docker.com/linux/fedora\n"))
+
+				entry, err := history.LastUndoable()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(entry.Steps[0].Repository.For("dnf").URL).To(Equal(`https://{{ secret "docker_token" }}@download.docker.com/linux/fedora`))
+			})
+
+			It("fails the repository when the secret cannot be resolved", func() {
+				os.Unsetenv("SCFG_SECRET_DOCKER_TOKEN")
+				transaction.Secrets = func() (*secret.Resolver, error) { return secret.NewResolver(secret.Env{}), nil }
+				DeferCleanup(func() { transaction.Secrets = secret.Default })
+
+				commandStubs.Register("dnf list --installed", "")
+				Expect(subject()).To(MatchError("Failed to sync 2 of 2 packages"))
+				Expect(stderr).To(ContainSubstring("secret `docker_token` was not found in env"))
+			})
+		})
+
+		Context("and the repository cannot be added", func() {
+			BeforeEach(func() {
+				manager = "apt"
+				cfg.Repositories[0].Sources["apt"] = &model.RepositorySource{URL: "https://download.docker.com/linux/ubuntu", Suite: "noble", Key: "https://download.docker.com/linux/ubuntu/gpg"}
+			})
+
+			It("skips the packages installed from it", func() {
+				commandStubs.Register("apt list --installed", "")
+				commandStubs.RegisterError("curl", 22, "The requested URL returned error: 404")
+				err := subject()
+				Expect(err).To(MatchError("Failed to sync 2 of 2 packages"))
+				Expect(stdout).NotTo(ContainSubstring("Adding package"))
+				Expect(stderr).To(HavePrefix(s.Yellow("WARNING: ") + "[System] Failed to add repository `docker`: The requested URL returned error: 404\n"))
+			})
+		})
+
+		Context("and the repository is not configured", func() {
+			BeforeEach(func() {
+				cfg.Packages[0].Repository = "missing"
+			})
+
+			It("returns an error", func() {
+				Expect(subject()).To(MatchError("Unable to order the configuration: `package:docker-ce` requires `repository:missing`, which is not defined"))
+			})
+		})
+	})
+
+	Context("when packages require other packages", func() {
+		BeforeEach(func() {
+			cfg.Packages = []*model.Package{
+				{Name: "a-plugin", Requires: []string{"z-tool"}},
+				{Name: "b-package"},
+				{Name: "z-tool", Requires: []string{"package:b-package"}},
+			}
+		})
+
+		It("installs each package after the packages it requires", func() {
+			commandStubs.Register("apt list --installed", "")
+			commandStubs.Register("apt install -y b-package", "")
+			commandStubs.Register("apt install -y z-tool", "")
+			commandStubs.Register("apt install -y a-plugin", "")
+			commandStubs.Register("apt list --installed", "")
+			Expect(subject()).To(Succeed())
+			Expect(stdout).To(Equal("[System] Adding package `b-package`\n[System] Adding package `z-tool`\n[System] Adding package `a-plugin`\n\n" +
+				"  PACKAGE             DETAILS\n" +
+				s.SuccessIcon() + " [System] b-package\n" +
+				s.SuccessIcon() + " [System] z-tool\n" +
+				s.SuccessIcon() + " [System] a-plugin\n"))
+		})
+
+		It("skips the packages that require a package that failed", func() {
+			commandStubs.Register("apt list --installed", "")
+			commandStubs.RegisterError("apt install -y b-package", 100, "Unable to locate package b-package")
+			err := subject()
+			Expect(err).To(MatchError("Failed to sync 3 of 3 packages"))
+			Expect(stdout).To(Equal("[System] Adding package `b-package`\n\n" +
+				"  PACKAGE             DETAILS\n" +
+				s.FailureIcon() + " [System] b-package  Unable to locate package b-package\n" +
+				s.WarningIcon() + " [System] z-tool     not attempted, requires `package:b-package`, which was not installed\n" +
+				s.WarningIcon() + " [System] a-plugin   not attempted, requires `package:z-tool`, which was not installed\n"))
+		})
+
+		It("installs packages whose requirements are already installed", func() {
+			commandStubs.Register("apt list --installed", "b-package/now 1.0.0")
+			commandStubs.Register("apt install -y z-tool", "")
+			commandStubs.Register("apt install -y a-plugin", "")
+			commandStubs.Register("apt list --installed", "")
+			Expect(subject()).To(Succeed())
+		})
+
+		Context("and the requirements form a cycle", func() {
+			BeforeEach(func() {
+				cfg.Packages[1].Requires = []string{"a-plugin"}
+			})
+
+			It("returns an error without changing the system", func() {
+				Expect(subject()).To(MatchError("Unable to order the configuration: dependency cycle: package:a-plugin -> package:z-tool -> package:b-package -> package:a-plugin"))
+				Expect(stdout).To(BeEmpty())
+			})
+		})
+	})
+
 	Context("when the mode manages the system", func() {
 		BeforeEach(func() {
 			mode = "system"
@@ -96,7 +405,9 @@ var _ = Describe("Sync", func() {
 		It("syncs the system pacakges to the configuration", func() {
 			commandStubs.Register("apt list --installed", "apt-some-sys-package/now 1.2.3")
 			Expect(subject()).To(Succeed())
-			Expect(stdout).To(Equal("[Configuration] Adding package `apt-some-sys-package@1.2.3`\n"))
+			Expect(stdout).To(Equal("[Configuration] Adding package `apt-some-sys-package@1.2.3`\n\n" +
+				"  PACKAGE                                     DETAILS\n" +
+				s.SuccessIcon() + " [Configuration] apt-some-sys-package@1.2.3\n"))
 			Expect(stderr).To(BeEmpty())
 			Expect(cfg.Packages).To(ContainElement(&model.Package{
 				Name:    "apt-some-sys-package",
@@ -159,10 +470,44 @@ var _ = Describe("Sync", func() {
 			It("syncs both the system and configuration packages in an addition only manner", func() {
 				commandStubs.Register("apt list --installed", "apt-some-sys-package/now 1.2.3")
 				commandStubs.Register("apt install -y apt-some-package=1.2.3", "successfully installed package")
+				commandStubs.Register("apt list --installed", "apt-some-package/now 1.2.3\napt-some-sys-package/now 1.2.3")
 				Expect(subject()).To(Succeed())
-				Expect(stdout).To(Equal("[System] Adding package `apt-some-package=1.2.3`\n[Configuration] Adding package `apt-some-sys-package@1.2.3`\n"))
+				Expect(stdout).To(Equal("[System] Adding package `apt-some-package=1.2.3`\n[Configuration] Adding package `apt-some-sys-package@1.2.3`\n\n" +
+					"  PACKAGE                                     DETAILS\n" +
+					s.SuccessIcon() + " [System] apt-some-package=1.2.3\n" +
+					s.SuccessIcon() + " [Configuration] apt-some-sys-package@1.2.3\n"))
 				Expect(stderr).To(BeEmpty())
 			})
 		})
 	})
+
+	Context("when the configuration is remote and not signed", func() {
+		BeforeEach(func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/base.yml" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				w.Write([]byte("packages:\n  - some-package\n"))
+			}))
+			DeferCleanup(server.Close)
+
+			cacheDir := GinkgoT().TempDir()
+			original := internal_store.CacheDefaultLocation
+			internal_store.CacheDefaultLocation = func() (string, error) { return cacheDir, nil }
+			DeferCleanup(func() { internal_store.CacheDefaultLocation = original })
+
+			remote, err := internal_store.NewRemote(&internal_store.RemoteCfg{URL: server.URL + "/base.yml"})
+			Expect(err).ToNot(HaveOccurred())
+			remoteCfg, err := remote.LoadConfiguration()
+			Expect(err).ToNot(HaveOccurred())
+			cfg = (*store.Configuration)(remoteCfg)
+		})
+
+		It("refuses to install packages from it", func() {
+			Expect(subject()).To(MatchError(HavePrefix("Refusing to install packages from `http://")))
+			Expect(stdout).To(BeEmpty())
+		})
+	})
 })
@@ -0,0 +1,271 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/drew-english/system-configurator/internal/history"
+	"github.com/drew-english/system-configurator/internal/mode"
+	"github.com/drew-english/system-configurator/internal/report"
+	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/internal/transaction"
+	"github.com/drew-english/system-configurator/pkg/sys/pkgmanager"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	"github.com/spf13/cobra"
+)
+
+var UndoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Undo a package operation",
+	Long: `Reverse the changes made by a package operation, the last one by default or the history entry with the given id.
+Packages it installed are uninstalled, packages it removed are reinstalled at their recorded version, and configuration entries (including alternates) are restored.
+Has different behavior based on the current mode:
+- Configuration: Only configuration changes are reversed.
+- System: Only system changes are reversed.
+- Hybrid: Both configuration and system changes are reversed.
+
+An undo is itself recorded in the history, so undoing it reapplies the original changes.
+Changes that are not managed in the current mode, failed or were rolled back are kept as an unfinished operation,
+which the next undo of it reverses, and the operation is only marked as undone once all of its changes are.
+
+Usage: scfg undo [<id>]`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		entry := history.Begin("undo", args)
+		defer func() { entry.Finish(err) }()
+
+		target, journal, err := undoTarget(args)
+		if err != nil {
+			return err
+		}
+
+		var cfg *store.Configuration
+		if mode.ManageConfig() && journal.ModifiesConfig() {
+			if cfg, err = store.LoadConfiguration(); err != nil {
+				return fmt.Errorf("Unable to load configuration: %w", err)
+			}
+
+			entry.TrackConfig(cfg)
+		}
+
+		var manager pkgmanager.PacakgeManager
+		if mode.ManageSystem() && journal.ModifiesSystem() {
+			if manager, err = stepManager(journal.Steps); err != nil {
+				return err
+			}
+
+			entry.Manager = manager.Name()
+		}
+
+		ctx := commandContext(cmd)
+		tx, err := transaction.Begin(ctx, manager, cfg)
+		if err != nil {
+			return fmt.Errorf("Unable to read system packages: %w", err)
+		}
+
+		entry.TrackTransaction(tx)
+
+		summary := report.NewSummary("undo")
+		var remaining []transaction.Step
+		ignored := 0
+		for i := len(journal.Steps) - 1; i >= 0; i-- {
+			step := journal.Steps[i]
+			if !undoable(step) {
+				remaining = append([]transaction.Step{step}, remaining...)
+				ignored++
+				continue
+			}
+
+			if step.Package != nil && !slices.Contains(entry.Packages, step.Package.Name) {
+				entry.Packages = append(entry.Packages, step.Package.Name)
+			}
+
+			name := undoName(step)
+			if stopProcessing(ctx, summary) {
+				summary.Skipped(name)
+				remaining = append([]transaction.Step{step}, remaining...)
+				continue
+			}
+
+			termio.Printf("Undoing %s\n", name)
+			if err := undoStep(ctx, tx, step); err != nil {
+				recordFailure(ctx, summary, name, fmt.Sprintf("Failed to undo %s", name), err)
+				remaining = append([]transaction.Step{step}, remaining...)
+				continue
+			}
+
+			summary.Succeeded(name)
+		}
+
+		if ignored > 0 {
+			termio.Warnf("Ignoring %d changes that are not managed in %s mode\n", ignored, mode.Current())
+		}
+
+		rollbackErr := rollbackOnFailure(ctx, tx, summary)
+		if rollbackErr != nil || summary.Count(report.StatusRolledBack) > 0 {
+			remaining = journal.Steps
+		}
+
+		// The operation is only undone once all of its changes are, the rest are left for the next undo.
+		entry.KeepUnfinished(&transaction.Journal{Steps: remaining, Undoes: target})
+		if len(remaining) == 0 {
+			entry.Undoes = target
+		}
+
+		if rollbackErr != nil {
+			return rollbackErr
+		}
+
+		if err := writeConfiguration(cfg); err != nil {
+			return err
+		}
+
+		if err := finish(ctx, summary); err != nil {
+			return err
+		}
+
+		termio.Printf("Successfully reverted %d changes\n", len(summary.Results))
+		return nil
+	},
+}
+
+// undoTarget resolves the changes to undo. Without an id, the changes of an operation that never
+// finished, identified by id 0, take precedence over the changes left by a partial undo, which take
+// precedence over the last operation in the history. A history entry that was partially undone
+// resolves to the changes left of it.
+func undoTarget(args []string) (int, *transaction.Journal, error) {
+	if len(args) == 0 {
+		journal, err := transaction.LoadJournal()
+		if err != nil {
+			return 0, nil, fmt.Errorf("Unable to read the unfinished operation: %w", err)
+		}
+
+		if journal != nil && len(journal.Steps) > 0 {
+			return 0, journal, nil
+		}
+
+		unfinished, err := transaction.LoadUnfinished()
+		if err != nil {
+			return 0, nil, fmt.Errorf("Unable to read the unfinished operation: %w", err)
+		}
+
+		if len(unfinished) > 0 {
+			journal := unfinished[len(unfinished)-1]
+			return journal.Undoes, journal, nil
+		}
+
+		last, err := history.LastUndoable()
+		if err != nil {
+			return 0, nil, fmt.Errorf("Unable to read history: %w", err)
+		}
+
+		if last == nil {
+			return 0, nil, errors.New("Nothing to undo")
+		}
+
+		return last.ID, &transaction.Journal{Steps: last.Steps}, nil
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, nil, fmt.Errorf("Invalid history id `%s`", args[0])
+	}
+
+	target, err := history.Find(id)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Unable to read history: %w", err)
+	}
+
+	if target == nil {
+		return 0, nil, fmt.Errorf("Unable to find history entry `%d`", id)
+	}
+
+	if len(target.Steps) == 0 {
+		return 0, nil, fmt.Errorf("History entry `%d` made no changes to undo", id)
+	}
+
+	undoneBy, err := history.UndoneBy(id)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Unable to read history: %w", err)
+	}
+
+	if undoneBy != nil {
+		return 0, nil, fmt.Errorf("History entry `%d` was already undone by entry `%d`", id, undoneBy.ID)
+	}
+
+	unfinished, err := transaction.FindUnfinished(id)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Unable to read the unfinished operation: %w", err)
+	}
+
+	if unfinished != nil {
+		return id, unfinished, nil
+	}
+
+	return id, &transaction.Journal{Steps: target.Steps}, nil
+}
+
+// stepManager returns the package manager that applied the system changes in steps.
+func stepManager(steps []transaction.Step) (pkgmanager.PacakgeManager, error) {
+	for _, step := range steps {
+		if step.Manager == "" {
+			continue
+		}
+
+		manager, ok := pkgmanager.Managers[step.Manager]
+		if !ok {
+			return nil, fmt.Errorf("Unknown package manager `%s`", step.Manager)
+		}
+
+		return manager, nil
+	}
+
+	return nil, errors.New("No package manager recorded for system changes")
+}
+
+// undoable reports whether the step is reversed in the current mode.
+func undoable(step transaction.Step) bool {
+	switch step.Kind {
+	case transaction.StepInstall, transaction.StepUninstall, transaction.StepAddRepository, transaction.StepRemoveRepository:
+		return mode.ManageSystem()
+	default:
+		return mode.ManageConfig()
+	}
+}
+
+func undoName(step transaction.Step) string {
+	switch step.Kind {
+	case transaction.StepInstall, transaction.StepUninstall, transaction.StepAddRepository, transaction.StepRemoveRepository:
+		return fmt.Sprintf("[System] %s `%s`", step.Kind, step.Subject())
+	default:
+		return fmt.Sprintf("[Configuration] %s `%s`", step.Kind, step.Subject())
+	}
+}
+
+// undoStep applies the inverse of step through tx, so the undo is itself recorded and reversible.
+func undoStep(ctx context.Context, tx *transaction.Transaction, step transaction.Step) error {
+	switch step.Kind {
+	case transaction.StepInstall:
+		return tx.Uninstall(ctx, step.Package.Name)
+	case transaction.StepUninstall:
+		return tx.Install(ctx, step.Package)
+	case transaction.StepConfigAdd:
+		return tx.RemoveFromConfig(step.Package.Name)
+	case transaction.StepConfigRemove:
+		return tx.AddToConfig(step.Package)
+	case transaction.StepAddRepository:
+		return tx.RemoveRepository(ctx, step.Repository)
+	case transaction.StepRemoveRepository:
+		_, err := tx.AddRepository(ctx, step.Repository)
+		return err
+	case transaction.StepConfigAddRepository:
+		return tx.RemoveRepositoryFromConfig(step.Repository.Name)
+	case transaction.StepConfigRemoveRepository:
+		return tx.AddRepositoryToConfig(step.Repository)
+	}
+
+	return fmt.Errorf("unknown step `%s`", step.Kind)
+}
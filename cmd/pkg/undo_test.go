@@ -0,0 +1,300 @@
+package pkg_test
+
+import (
+	"testing"
+
+	"github.com/drew-english/system-configurator/cmd/pkg"
+	"github.com/drew-english/system-configurator/internal/history"
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/internal/report"
+	"github.com/drew-english/system-configurator/internal/transaction"
+	"github.com/drew-english/system-configurator/spec/stub/run"
+	"github.com/drew-english/system-configurator/spec/stub/store"
+	termio_stub "github.com/drew-english/system-configurator/spec/stub/termio"
+	"github.com/spf13/viper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Undo", func() {
+	var (
+		stdout, stderr   string
+		args             []string
+		cfg              *store.Configuration
+		commandStubs     *run.CommandStubManager
+		teardownCmdStubs func(testing.TB)
+	)
+
+	subject := func() error {
+		var err error
+		stdout, stderr = termio_stub.CaptureTermOut(func() {
+			err = pkg.UndoCmd.RunE(nil, args)
+		})
+
+		return err
+	}
+
+	lastEntry := func() *history.Entry {
+		entries, err := history.List()
+		Expect(err).ToNot(HaveOccurred())
+		return entries[len(entries)-1]
+	}
+
+	BeforeEach(func() {
+		viper.Set("mode", "hybrid")
+		viper.Set("fail-fast", false)
+		args = nil
+		commandStubs, teardownCmdStubs = run.StubCommand()
+		cfg = &store.Configuration{
+			Packages: []*model.Package{{Name: "new-package"}},
+		}
+
+		Expect(history.Append(&history.Entry{
+			Command: "package add",
+			Steps: []transaction.Step{
+				{Kind: transaction.StepInstall, Manager: "apt", Package: &model.Package{Name: "new-package"}},
+				{Kind: transaction.StepConfigAdd, Package: &model.Package{Name: "new-package"}},
+			},
+		})).To(Succeed())
+		Expect(history.Append(&history.Entry{
+			Command: "package remove",
+			Steps: []transaction.Step{
+				{Kind: transaction.StepUninstall, Manager: "apt", Package: &model.Package{Name: "old-package", Version: "1.2.3"}},
+				{Kind: transaction.StepConfigRemove, Package: &model.Package{
+					Name:       "old-package",
+					Alternates: map[string]*model.Package{"apt": {Name: "apt-old-package"}},
+				}},
+			},
+		})).To(Succeed())
+	})
+
+	JustBeforeEach(func() {
+		store.StubLoadConfiguration(cfg)
+		store.StubWriteConfiguration()
+	})
+
+	AfterEach(func() {
+		teardownCmdStubs(GinkgoTB())
+	})
+
+	It("reverses the last operation and records it", func() {
+		commandStubs.Register("apt list --installed", "")
+		commandStubs.Register("apt install -y old-package=1.2.3", "package added successfully")
+		Expect(subject()).To(Succeed())
+		Expect(stdout).To(HaveSuffix("Successfully reverted 2 changes\n"))
+		Expect(cfg.Packages).To(ContainElement(&model.Package{
+			Name:       "old-package",
+			Alternates: map[string]*model.Package{"apt": {Name: "apt-old-package"}},
+		}))
+
+		entry := lastEntry()
+		Expect(entry.Command).To(Equal("undo"))
+		Expect(entry.Undoes).To(Equal(2))
+		Expect(entry.Packages).To(Equal([]string{"old-package"}))
+		Expect(entry.Steps).To(HaveLen(2))
+	})
+
+	It("undoes an undo by reapplying the original changes", func() {
+		commandStubs.Register("apt list --installed", "")
+		commandStubs.Register("apt install -y old-package=1.2.3", "package added successfully")
+		Expect(subject()).To(Succeed())
+
+		store.StubLoadConfiguration(cfg)
+		store.StubWriteConfiguration()
+		commandStubs.Register("apt list --installed", "old-package/now 1.2.3")
+		commandStubs.Register("apt remove old-package", "package removed successfully")
+		Expect(subject()).To(Succeed())
+		Expect(cfg.Packages).To(HaveLen(1))
+		Expect(lastEntry().Undoes).To(Equal(3))
+	})
+
+	Context("when given the id of an operation", func() {
+		BeforeEach(func() {
+			args = []string{"1"}
+		})
+
+		It("reverses that operation", func() {
+			commandStubs.Register("apt list --installed", "new-package/now 2.0.0")
+			commandStubs.Register("apt remove new-package", "package removed successfully")
+			Expect(subject()).To(Succeed())
+			Expect(cfg.Packages).To(BeEmpty())
+			Expect(lastEntry().Undoes).To(Equal(1))
+		})
+
+		Context("and it was already undone", func() {
+			BeforeEach(func() {
+				Expect(history.Append(&history.Entry{Command: "undo", Undoes: 1})).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				Expect(subject()).To(MatchError("History entry `1` was already undone by entry `3`"))
+			})
+		})
+
+		Context("and it made no changes", func() {
+			BeforeEach(func() {
+				Expect(history.Append(&history.Entry{Command: "package add"})).To(Succeed())
+				args = []string{"3"}
+			})
+
+			It("returns an error", func() {
+				Expect(subject()).To(MatchError("History entry `3` made no changes to undo"))
+			})
+		})
+
+		Context("and it does not exist", func() {
+			BeforeEach(func() {
+				args = []string{"9"}
+			})
+
+			It("returns an error", func() {
+				Expect(subject()).To(MatchError("Unable to find history entry `9`"))
+			})
+		})
+	})
+
+	Context("when in configuration mode", func() {
+		BeforeEach(func() {
+			viper.Set("mode", "configuration")
+			args = []string{"1"}
+		})
+
+		It("only reverses configuration changes", func() {
+			Expect(subject()).To(Succeed())
+			Expect(cfg.Packages).To(BeEmpty())
+			Expect(stderr).To(ContainSubstring("Ignoring 1 changes that are not managed in configuration mode\n"))
+		})
+
+		It("keeps the ignored changes without marking the operation as undone", func() {
+			Expect(subject()).To(Succeed())
+			Expect(lastEntry().Undoes).To(BeZero())
+			Expect(transaction.FindUnfinished(1)).To(Equal(&transaction.Journal{
+				Steps:  []transaction.Step{{Kind: transaction.StepInstall, Manager: "apt", Package: &model.Package{Name: "new-package"}}},
+				Undoes: 1,
+			}))
+		})
+
+		It("keeps the ignored changes when another operation runs before the next undo", func() {
+			Expect(subject()).To(Succeed())
+
+			// Another operation journals its own steps and discards them once recorded.
+			other := &transaction.Journal{Steps: []transaction.Step{{Kind: transaction.StepConfigAdd, Package: &model.Package{Name: "fzf"}}}}
+			Expect(other.Save()).To(Succeed())
+			Expect(transaction.Discard()).To(Succeed())
+
+			viper.Set("mode", "system")
+			args = nil
+			commandStubs.Register("apt list --installed", "new-package/now 2.0.0")
+			commandStubs.Register("apt remove new-package", "package removed successfully")
+			Expect(subject()).To(Succeed())
+			Expect(lastEntry().Undoes).To(Equal(1))
+		})
+
+		It("reverses only the ignored changes when undoing the operation by id", func() {
+			Expect(subject()).To(Succeed())
+
+			store.StubLoadConfiguration(cfg)
+			viper.Set("mode", "hybrid")
+			commandStubs.Register("apt list --installed", "new-package/now 2.0.0")
+			commandStubs.Register("apt remove new-package", "package removed successfully")
+			Expect(subject()).To(Succeed())
+			Expect(stdout).To(HaveSuffix("Successfully reverted 1 changes\n"))
+			Expect(lastEntry().Undoes).To(Equal(1))
+			Expect(transaction.FindUnfinished(1)).To(BeNil())
+		})
+
+		It("reverses the ignored changes with the next undo and marks the operation as undone", func() {
+			Expect(subject()).To(Succeed())
+
+			viper.Set("mode", "system")
+			args = nil
+			commandStubs.Register("apt list --installed", "new-package/now 2.0.0")
+			commandStubs.Register("apt remove new-package", "package removed successfully")
+			Expect(subject()).To(Succeed())
+			Expect(lastEntry().Undoes).To(Equal(1))
+			Expect(transaction.LoadJournal()).To(BeNil())
+		})
+	})
+
+	Context("when a change cannot be reversed", func() {
+		It("reverses the rest and returns a partial failure", func() {
+			commandStubs.Register("apt list --installed", "")
+			commandStubs.RegisterError("apt install -y old-package=1.2.3", 1, "locked")
+			err := subject()
+			Expect(err).To(MatchError("Failed to undo 1 of 2 packages"))
+			Expect(err).To(HaveField("Code", report.ExitPartialFailure))
+			Expect(cfg.Packages).To(HaveLen(2))
+		})
+
+		It("keeps the change without marking the operation as undone", func() {
+			commandStubs.Register("apt list --installed", "")
+			commandStubs.RegisterError("apt install -y old-package=1.2.3", 1, "locked")
+			Expect(subject()).ToNot(Succeed())
+			Expect(lastEntry().Undoes).To(BeZero())
+			Expect(transaction.FindUnfinished(2)).To(Equal(&transaction.Journal{
+				Steps:  []transaction.Step{{Kind: transaction.StepUninstall, Manager: "apt", Package: &model.Package{Name: "old-package", Version: "1.2.3"}}},
+				Undoes: 2,
+			}))
+		})
+
+		It("retries the change with the next undo and marks the operation as undone", func() {
+			commandStubs.Register("apt list --installed", "")
+			commandStubs.RegisterError("apt install -y old-package=1.2.3", 1, "locked")
+			Expect(subject()).ToNot(Succeed())
+
+			store.StubLoadConfiguration(cfg)
+			store.StubWriteConfiguration()
+			commandStubs.Register("apt list --installed", "")
+			commandStubs.Register("apt install -y old-package=1.2.3", "package added successfully")
+			Expect(subject()).To(Succeed())
+			Expect(stdout).To(HaveSuffix("Successfully reverted 1 changes\n"))
+			Expect(lastEntry().Undoes).To(Equal(2))
+			Expect(transaction.FindUnfinished(2)).To(BeNil())
+		})
+
+		Context("and fail fast is enabled", func() {
+			BeforeEach(func() {
+				viper.Set("fail-fast", true)
+			})
+
+			It("keeps every change of the operation to undo", func() {
+				commandStubs.Register("apt list --installed", "")
+				commandStubs.RegisterError("apt install -y old-package=1.2.3", 1, "locked")
+				Expect(subject()).ToNot(Succeed())
+				Expect(lastEntry().Undoes).To(BeZero())
+
+				journal, err := transaction.FindUnfinished(2)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(journal.Steps).To(HaveLen(2))
+			})
+		})
+	})
+
+	Context("when an operation did not finish", func() {
+		BeforeEach(func() {
+			journal := &transaction.Journal{Steps: []transaction.Step{
+				{Kind: transaction.StepConfigAdd, Package: &model.Package{Name: "new-package"}},
+			}}
+			Expect(journal.Save()).To(Succeed())
+		})
+
+		It("reverses the unfinished operation first", func() {
+			Expect(subject()).To(Succeed())
+			Expect(cfg.Packages).To(BeEmpty())
+			Expect(lastEntry().Undoes).To(BeZero())
+			Expect(transaction.LoadJournal()).To(BeNil())
+		})
+	})
+
+	Context("when there is nothing to undo", func() {
+		BeforeEach(func() {
+			Expect(history.Append(&history.Entry{Command: "undo", Undoes: 1})).To(Succeed())
+			Expect(history.Append(&history.Entry{Command: "undo", Undoes: 2})).To(Succeed())
+		})
+
+		It("returns an error", func() {
+			Expect(subject()).To(MatchError("Nothing to undo"))
+		})
+	})
+})
@@ -1,16 +1,34 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"strings"
 
+	"github.com/drew-english/system-configurator/cmd/bootstrap"
+	"github.com/drew-english/system-configurator/cmd/config"
+	"github.com/drew-english/system-configurator/cmd/doctor"
+	"github.com/drew-english/system-configurator/cmd/exporter"
+	"github.com/drew-english/system-configurator/cmd/history"
+	"github.com/drew-english/system-configurator/cmd/importer"
 	"github.com/drew-english/system-configurator/cmd/pkg"
 	"github.com/drew-english/system-configurator/cmd/pkg/alternate"
+	"github.com/drew-english/system-configurator/cmd/secret"
+	"github.com/drew-english/system-configurator/cmd/status"
 	"github.com/drew-english/system-configurator/internal/mode"
+	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/pkg/logging"
+	"github.com/drew-english/system-configurator/pkg/sys/pkgmanager"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+var closeLog = func() error { return nil }
+
 var rootCmd = &cobra.Command{
 	Use:   "scfg",
 	Short: "System Configurator CLI",
@@ -18,8 +36,18 @@ var rootCmd = &cobra.Command{
 }
 
 func Execute() {
-	err := rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	store.RemoteContext = ctx
+	err := rootCmd.ExecuteContext(ctx)
+	closeLog()
 	if err != nil {
+		var exitErr interface{ ExitCode() int }
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+
 		os.Exit(1)
 	}
 }
@@ -31,15 +59,61 @@ func init() {
 	viper.BindPFlag("mode", rootCmd.PersistentFlags().Lookup("mode"))
 	viper.SetDefault("mode", "configuration")
 
+	rootCmd.PersistentFlags().String("config", "", "Use the given configuration file or http(s) URL instead of the system, user and project configurations (also set by $SCFG_CONFIG).")
+	rootCmd.PersistentFlags().String("config-checksum", "", "Require a remote --config to match the given sha256:<digest>.")
+	viper.BindPFlag("config", rootCmd.PersistentFlags().Lookup("config"))
+	viper.BindPFlag("config-checksum", rootCmd.PersistentFlags().Lookup("config-checksum"))
+
+	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Log the commands run on the system.")
+	rootCmd.PersistentFlags().Bool("debug", false, "Log debugging details, including package manager detection and configuration paths.")
+	rootCmd.PersistentFlags().String("log-file", "", "Write logs to the given file instead of stderr.")
+	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
+	viper.BindPFlag("debug", rootCmd.PersistentFlags().Lookup("debug"))
+	viper.BindPFlag("log-file", rootCmd.PersistentFlags().Lookup("log-file"))
+
+	viper.SetDefault("timeout.add", "30m")
+	viper.SetDefault("timeout.remove", "10m")
+	viper.SetDefault("timeout.list", "2m")
+
 	rootCmd.AddCommand(pkg.PkgCmd)
 	rootCmd.AddCommand(alternate.AlternateCmd)
+	rootCmd.AddCommand(pkg.UndoCmd)
+	rootCmd.AddCommand(history.HistoryCmd)
+	rootCmd.AddCommand(status.StatusCmd)
+	rootCmd.AddCommand(doctor.DoctorCmd)
+	rootCmd.AddCommand(config.ConfigCmd)
+	rootCmd.AddCommand(secret.SecretCmd)
+	rootCmd.AddCommand(importer.ImportCmd)
+	rootCmd.AddCommand(exporter.ExportCmd)
+	rootCmd.AddCommand(bootstrap.InitCmd)
 }
 
 func initConfig() {
 	viper.SetEnvPrefix("SCFG")
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
 	viper.AutomaticEnv()
 
+	logLevel := slog.LevelWarn
+	if viper.GetBool("debug") {
+		logLevel = slog.LevelDebug
+	} else if viper.GetBool("verbose") {
+		logLevel = slog.LevelInfo
+	}
+
+	var err error
+	closeLog, err = logging.Configure(logging.Options{Level: logLevel, File: viper.GetString("log-file")})
+	cobra.CheckErr(err)
+
 	if mode.Parse(viper.GetString("mode")) == -1 {
 		cobra.CheckErr(fmt.Sprintf("mode `%s` is invalid\n", viper.GetString("mode")))
 	}
+
+	store.ConfigPath = viper.GetString("config")
+	store.ConfigChecksum = viper.GetString("config-checksum")
+
+	pkgmanager.Timeouts = pkgmanager.OperationTimeouts{
+		Add:    viper.GetDuration("timeout.add"),
+		Remove: viper.GetDuration("timeout.remove"),
+		List:   viper.GetDuration("timeout.list"),
+	}
 }
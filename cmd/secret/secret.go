@@ -0,0 +1,58 @@
+package secret
+
+import (
+	"fmt"
+
+	"github.com/drew-english/system-configurator/internal/secret"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	"github.com/spf13/cobra"
+)
+
+var SecretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Inspect secrets",
+	Long: `Inspect the secrets available to scfg.
+Secrets are referenced from the url and key of a repository as {{ secret "name" }}, and looked up in order from:
+- env: the SCFG_SECRET_<NAME> environment variable
+- age: secrets.age in the configuration directory, a YAML mapping of name to value encrypted with age and decrypted with the identity in $SCFG_AGE_IDENTITY or age-identity.txt
+- pass: the system-configurator/<name> entry of the pass password store
+- secret-tool: the OS keyring entry with the attributes service=system-configurator and secret=<name>`,
+}
+
+var CheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check that secrets can be resolved",
+	Long: `Check that each secret can be resolved, showing which provider it comes from without revealing it.
+
+Usage: scfg secret check <name>...`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resolver, err := secret.Default()
+		if err != nil {
+			return fmt.Errorf("Unable to locate secrets: %w", err)
+		}
+
+		style := termio.Style()
+		failed := 0
+		for _, name := range args {
+			provider, err := resolver.Source(cmd.Context(), name)
+			if err != nil {
+				termio.Printf("%s %s: %s\n", style.FailureIcon(), style.Bold(name), err)
+				failed++
+				continue
+			}
+
+			termio.Printf("%s %s: from %s\n", style.SuccessIcon(), style.Bold(name), provider)
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d of %d secrets could not be resolved", failed, len(args))
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	SecretCmd.AddCommand(CheckCmd)
+}
@@ -0,0 +1,51 @@
+package secret_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/drew-english/system-configurator/cmd/secret"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	"github.com/drew-english/system-configurator/spec/stub/run"
+	store_stub "github.com/drew-english/system-configurator/spec/stub/store"
+	termio_stub "github.com/drew-english/system-configurator/spec/stub/termio"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Check", func() {
+	var (
+		stdout string
+		s      = termio.Style()
+	)
+
+	subject := func(args ...string) error {
+		secret.CheckCmd.SetContext(context.Background())
+
+		var err error
+		stdout, _ = termio_stub.CaptureTermOut(func() {
+			err = secret.CheckCmd.RunE(secret.CheckCmd, args)
+		})
+
+		return err
+	}
+
+	BeforeEach(func() {
+		dir := GinkgoT().TempDir()
+		DeferCleanup(store_stub.StubLayers(dir, dir, dir))
+		DeferCleanup(run.StubFind(".*", errors.New("not found")))
+		GinkgoT().Setenv("SCFG_SECRET_GITHUB_TOKEN", "ghp_0123456789")
+	})
+
+	It("shows where each secret comes from without revealing it", func() {
+		Expect(subject("github_token")).To(Succeed())
+		Expect(stdout).To(Equal(s.SuccessIcon() + " " + s.Bold("github_token") + ": from env\n"))
+	})
+
+	It("returns an error for secrets that cannot be resolved", func() {
+		Expect(subject("github_token", "npm_token")).To(MatchError("1 of 2 secrets could not be resolved"))
+		Expect(stdout).To(ContainSubstring(s.FailureIcon() + " " + s.Bold("npm_token") + ": secret `npm_token` was not found in env, age, pass, secret-tool\n"))
+		Expect(stdout).ToNot(ContainSubstring("ghp_0123456789"))
+	})
+})
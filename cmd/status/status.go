@@ -0,0 +1,79 @@
+package status
+
+import (
+	"fmt"
+
+	"github.com/drew-english/system-configurator/internal/drift"
+	"github.com/drew-english/system-configurator/internal/report"
+	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/pkg/sys/pkgmanager"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	"github.com/spf13/cobra"
+)
+
+// ExitDrift is the exit code when the configuration and system have drifted apart.
+const ExitDrift = 4
+
+var StatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show drift between the configuration and system",
+	Long: `Show where the system has drifted from the configuration, regardless of the current mode:
+- Packages in the configuration that are missing from the system.
+- Packages explicitly installed on the system that are not in the configuration, leaving out their dependencies.
+- Packages installed at a different version than configured.
+- Alternates for package managers that are not supported.
+
+Exits with code 4 when drift is found, so it can be used in cron jobs or login hooks.
+
+Usage: scfg status`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := store.LoadConfiguration()
+		if err != nil {
+			return fmt.Errorf("Unable to load configuration: %w", err)
+		}
+
+		manager, err := pkgmanager.FindPackageManager()
+		if err != nil {
+			return fmt.Errorf("Failed to find the package manager: %w", err)
+		}
+
+		sysPkgs, err := manager.ListPackages(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("Unable to read system packages: %w", err)
+		}
+
+		explicitPkgs, err := manager.ListExplicitPackages(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("Unable to read system packages: %w", err)
+		}
+
+		drifted := drift.Detect(cfg, manager.Name(), sysPkgs, explicitPkgs)
+		if !drifted.Drifted() {
+			termio.Printf("%s Configuration and system are in sync\n", termio.Style().SuccessIcon())
+			return nil
+		}
+
+		style := termio.Style()
+		for _, kind := range drift.Kinds {
+			items := drifted.Of(kind)
+			if len(items) == 0 {
+				continue
+			}
+
+			termio.Printf("%s %s (%d)\n", style.FailureIcon(), style.Bold(kind.String()), len(items))
+			for _, item := range items {
+				if item.Detail == "" {
+					termio.Printf("    %s\n", item.Package)
+				} else {
+					termio.Printf("    %s: %s\n", item.Package, item.Detail)
+				}
+			}
+		}
+
+		return &report.ExitError{
+			Code: ExitDrift,
+			Err:  fmt.Errorf("Found %d differences between the configuration and system", len(drifted.Items)),
+		}
+	},
+}
@@ -0,0 +1,106 @@
+package status_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/drew-english/system-configurator/cmd/status"
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	"github.com/drew-english/system-configurator/spec/stub/pkgmanager"
+	"github.com/drew-english/system-configurator/spec/stub/run"
+	"github.com/drew-english/system-configurator/spec/stub/store"
+	termio_stub "github.com/drew-english/system-configurator/spec/stub/termio"
+	"github.com/spf13/cobra"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Status", func() {
+	var (
+		stdout           string
+		cfg              *store.Configuration
+		commandStubs     *run.CommandStubManager
+		teardownCmdStubs func(testing.TB)
+
+		s = termio.Style()
+	)
+
+	subject := func() error {
+		command := &cobra.Command{}
+		command.SetContext(context.Background())
+
+		var err error
+		stdout, _ = termio_stub.CaptureTermOut(func() {
+			err = status.StatusCmd.RunE(command, nil)
+		})
+
+		return err
+	}
+
+	BeforeEach(func() {
+		commandStubs, teardownCmdStubs = run.StubCommand()
+		pkgmanager.StubFindPackageManager("apt")
+		cfg = &store.Configuration{
+			Packages: []*model.Package{
+				{Name: "fzf", Version: "0.44"},
+				{Name: "ripgrep", Alternates: map[string]*model.Package{"apt": {Name: "rg"}}},
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		store.StubLoadConfiguration(cfg)
+	})
+
+	AfterEach(func() {
+		teardownCmdStubs(GinkgoTB())
+	})
+
+	It("reports when there is no drift", func() {
+		commandStubs.Register("apt list --installed", "fzf/now 0.44.1\nrg/now 13.0.0")
+		commandStubs.Register("apt-mark showmanual", "fzf\nrg\n")
+		Expect(subject()).To(Succeed())
+		Expect(stdout).To(Equal(s.SuccessIcon() + " Configuration and system are in sync\n"))
+	})
+
+	It("does not report packages installed as dependencies", func() {
+		commandStubs.Register("apt list --installed", "fzf/now 0.44.1\nrg/now 13.0.0\nlibc6/now 2.39-0ubuntu8")
+		commandStubs.Register("apt-mark showmanual", "fzf\nrg\n")
+		Expect(subject()).To(Succeed())
+		Expect(stdout).To(Equal(s.SuccessIcon() + " Configuration and system are in sync\n"))
+	})
+
+	Context("when the system has drifted", func() {
+		BeforeEach(func() {
+			cfg.Packages = append(cfg.Packages, &model.Package{Name: "bat"})
+		})
+
+		It("summarizes the drift and exits with the drift code", func() {
+			commandStubs.Register("apt list --installed", "fzf/now 0.45.0\nrg/now 13.0.0\ncurl/now 8.5.0\nlibcurl4/now 8.5.0")
+			commandStubs.Register("apt-mark showmanual", "fzf\nrg\ncurl\n")
+			err := subject()
+			Expect(err).To(MatchError("Found 3 differences between the configuration and system"))
+			Expect(err).To(HaveField("Code", status.ExitDrift))
+			Expect(stdout).To(Equal(
+				s.FailureIcon() + " " + s.Bold("Missing from the system") + " (1)\n" +
+					"    bat\n" +
+					s.FailureIcon() + " " + s.Bold("Not in the configuration") + " (1)\n" +
+					"    curl@8.5.0\n" +
+					s.FailureIcon() + " " + s.Bold("Version mismatches") + " (1)\n" +
+					"    fzf: configured 0.44, installed 0.45.0\n",
+			))
+		})
+	})
+
+	Context("when loading the configuration fails", func() {
+		JustBeforeEach(func() {
+			store.StubLoadConfigurationError()
+		})
+
+		It("returns an error", func() {
+			Expect(subject()).To(MatchError("Unable to load configuration: error loading configuration"))
+		})
+	})
+})
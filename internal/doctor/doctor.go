@@ -0,0 +1,288 @@
+// Diagnoses the environment scfg runs in, reporting problems along with how to fix them.
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/drew-english/system-configurator/internal/drift"
+	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/pkg/run"
+	"github.com/drew-english/system-configurator/pkg/sys"
+	"github.com/drew-english/system-configurator/pkg/sys/pkgmanager"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	StatusPass = Status(iota)
+	StatusWarn
+	StatusFail
+)
+
+type (
+	Status int
+
+	Result struct {
+		Status  Status
+		Message string
+		Hint    string // how to fix the problem, when not passing
+	}
+
+	Check struct {
+		Name string
+		Run  func() Result
+	}
+)
+
+// Checks run by the doctor command, in order.
+var Checks = []Check{
+	{Name: "Vendor", Run: checkVendor},
+	{Name: "Package manager", Run: checkPackageManager},
+	{Name: "Configuration file", Run: checkConfigFile},
+	{Name: "Configuration syntax", Run: checkConfigSyntax},
+	{Name: "Alternates", Run: checkAlternates},
+	{Name: "Terminal colors", Run: checkColors},
+}
+
+func pass(format string, a ...any) Result {
+	return Result{Status: StatusPass, Message: fmt.Sprintf(format, a...)}
+}
+
+func warn(hint, format string, a ...any) Result {
+	return Result{Status: StatusWarn, Message: fmt.Sprintf(format, a...), Hint: hint}
+}
+
+func fail(hint, format string, a ...any) Result {
+	return Result{Status: StatusFail, Message: fmt.Sprintf(format, a...), Hint: hint}
+}
+
+func checkVendor() Result {
+	vendor := sys.Vendor()
+	if vendor == "other" {
+		return warn(
+			"Every known package manager is checked instead, see /etc/os-release for the detected vendor",
+			"Unable to detect a supported vendor",
+		)
+	}
+
+	return pass("Detected `%s`", vendor)
+}
+
+func checkPackageManager() Result {
+	supported := sys.SupportedPackageManagers()
+
+	var available []string
+	for _, name := range supported {
+		if _, err := run.Find(name); err == nil && pkgmanager.Managers[name] != nil {
+			available = append(available, name)
+		}
+	}
+
+	if len(available) == 0 {
+		return fail(
+			fmt.Sprintf("Install one of %s, or make sure it is in $PATH", strings.Join(supported, ", ")),
+			"No supported package manager found (supported: %s)",
+			strings.Join(supported, ", "),
+		)
+	}
+
+	return pass(
+		"Using `%s` (available: %s, supported: %s)",
+		available[0],
+		strings.Join(available, ", "),
+		strings.Join(supported, ", "),
+	)
+}
+
+func checkConfigFile() Result {
+	layers, err := configLayers()
+	if err != nil {
+		return fail("Set the $HOME or $XDG_CONFIG_HOME environment variable, or pass --config", "%v", err)
+	}
+
+	var results []Result
+	for _, layer := range layers {
+		if result, ok := checkLayerFile(layer); ok {
+			results = append(results, result)
+		}
+	}
+
+	if len(results) == 0 {
+		return pass("No local configuration")
+	}
+
+	return combine(results)
+}
+
+// checkLayerFile checks the file of a configuration layer, returning false when the layer has no file to check.
+// Only the file commands write to must be writable, other layers only have to be readable.
+func checkLayerFile(layer store.Layer) (Result, bool) {
+	written := layer.Name == store.LayerUser || layer.Name == store.LayerExplicit
+
+	info, err := os.Stat(layer.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if !written {
+			return Result{}, false
+		}
+
+		return warn("It is created the first time a command modifies the configuration", "`%s` does not exist", layer.Path), true
+	} else if err != nil {
+		return fail("Check the permissions of the parent directories", "Unable to read `%s`: %v", layer.Path, err), true
+	}
+
+	if written {
+		f, err := os.OpenFile(layer.Path, os.O_RDWR, 0)
+		if err != nil {
+			return fail(fmt.Sprintf("Run `chmod u+rw %s`", layer.Path), "`%s` is not readable and writable: %v", layer.Path, err), true
+		}
+		f.Close()
+	} else {
+		f, err := os.Open(layer.Path)
+		if err != nil {
+			return fail(fmt.Sprintf("Run `chmod a+r %s`", layer.Path), "`%s` is not readable: %v", layer.Path, err), true
+		}
+		f.Close()
+	}
+
+	if info.Mode().Perm()&0o002 != 0 {
+		return warn(fmt.Sprintf("Run `chmod o-w %s`", layer.Path), "`%s` is writable by every user", layer.Path), true
+	}
+
+	return pass("`%s`", layer.Path), true
+}
+
+func checkConfigSyntax() Result {
+	configs, err := readConfigs()
+	if err != nil {
+		return fail("Fix the YAML at the reported line", "Unable to parse the configuration: %v", err)
+	} else if len(configs) == 0 {
+		return pass("No configuration to check")
+	}
+
+	count := 0
+	for _, cfg := range configs {
+		count += len(cfg.Packages)
+	}
+
+	return pass("%d packages", count)
+}
+
+func checkAlternates() Result {
+	configs, err := readConfigs()
+	if err != nil {
+		return fail("Fix the configuration syntax first", "Unable to check alternates: %v", err)
+	} else if len(configs) == 0 {
+		return pass("No configuration to check")
+	}
+
+	var details []string
+	for _, cfg := range configs {
+		for _, item := range drift.UnresolvedAlternates(cfg) {
+			details = append(details, fmt.Sprintf("%s: %s", item.Package, item.Detail))
+		}
+	}
+
+	if len(details) == 0 {
+		return pass("Every alternate is for a supported package manager")
+	}
+
+	managers := make([]string, 0, len(pkgmanager.Managers))
+	for name := range pkgmanager.Managers {
+		managers = append(managers, name)
+	}
+	slices.Sort(managers)
+
+	return warn(
+		fmt.Sprintf("Alternates must be keyed by one of %s", strings.Join(managers, ", ")),
+		"%s",
+		strings.Join(details, "; "),
+	)
+}
+
+func checkColors() Result {
+	cfg := termio.ConfigFromEnv()
+	if cfg.ColorDisabled {
+		return warn("Unset NO_COLOR to enable colors", "Colors are disabled by NO_COLOR")
+	}
+
+	depth := "16 colors"
+	if cfg.TrueColorEnabled {
+		depth = "true color"
+	} else if cfg.Color256Enabled {
+		depth = "256 colors"
+	}
+
+	return pass("Colors are enabled (%s)", depth)
+}
+
+// configLayers returns the configuration layers with a local file, which may not exist yet.
+func configLayers() ([]store.Layer, error) {
+	layers, err := store.Layers()
+	if err != nil {
+		return nil, err
+	}
+
+	var local []store.Layer
+	for _, layer := range layers {
+		if layer.Path != "" && !store.IsRemote(layer.Path) {
+			local = append(local, layer)
+		}
+	}
+
+	return local, nil
+}
+
+// readConfigs parses the file of every configuration layer that exists, without creating any.
+func readConfigs() ([]*store.Configuration, error) {
+	layers, err := configLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []*store.Configuration
+	for _, layer := range layers {
+		data, err := os.ReadFile(layer.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		cfg := &store.Configuration{}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("`%s`: %w", layer.Path, err)
+		}
+
+		configs = append(configs, cfg)
+	}
+
+	return configs, nil
+}
+
+// combine merges the results of checking several files into the result of the worst one,
+// listing the message and hint of every file that did not pass.
+func combine(results []Result) Result {
+	worst := StatusPass
+	for _, result := range results {
+		worst = max(worst, result.Status)
+	}
+
+	var messages, hints []string
+	for _, result := range results {
+		if worst != StatusPass && result.Status == StatusPass {
+			continue
+		}
+
+		messages = append(messages, result.Message)
+		if result.Hint != "" {
+			hints = append(hints, result.Hint)
+		}
+	}
+
+	return Result{Status: worst, Message: strings.Join(messages, "; "), Hint: strings.Join(hints, "; ")}
+}
@@ -0,0 +1,184 @@
+package doctor_test
+
+import (
+	"errors"
+	"os"
+	"path"
+
+	"github.com/drew-english/system-configurator/internal/doctor"
+	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/pkg/run"
+	store_stub "github.com/drew-english/system-configurator/spec/stub/store"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Checks", func() {
+	var configDir, systemDir, projectDir string
+
+	runCheck := func(name string) doctor.Result {
+		for _, check := range doctor.Checks {
+			if check.Name == name {
+				return check.Run()
+			}
+		}
+
+		Fail("no check named " + name)
+		return doctor.Result{}
+	}
+
+	writeConfig := func(content string, perm os.FileMode) {
+		configPath := path.Join(configDir, store.LocalDefaultFileName)
+		Expect(os.WriteFile(configPath, []byte(content), perm)).To(Succeed())
+		Expect(os.Chmod(configPath, perm)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		configDir = GinkgoT().TempDir()
+		systemDir = GinkgoT().TempDir()
+		projectDir = GinkgoT().TempDir()
+		Expect(os.Mkdir(path.Join(projectDir, ".git"), 0755)).To(Succeed())
+		DeferCleanup(store_stub.StubLayers(systemDir, configDir, projectDir))
+	})
+
+	Describe("Package manager", func() {
+		var findErr error
+
+		BeforeEach(func() {
+			findErr = nil
+			original := run.Find
+			run.Find = func(string) (string, error) { return "", findErr }
+			DeferCleanup(func() { run.Find = original })
+		})
+
+		It("passes when a supported manager is available", func() {
+			result := runCheck("Package manager")
+			Expect(result.Status).To(Equal(doctor.StatusPass))
+			Expect(result.Message).To(HavePrefix("Using `"))
+		})
+
+		Context("when no supported manager is available", func() {
+			BeforeEach(func() {
+				findErr = errors.New("not found")
+			})
+
+			It("fails with a hint", func() {
+				result := runCheck("Package manager")
+				Expect(result.Status).To(Equal(doctor.StatusFail))
+				Expect(result.Hint).To(HavePrefix("Install one of "))
+			})
+		})
+	})
+
+	Describe("Configuration file", func() {
+		It("warns when the file does not exist", func() {
+			result := runCheck("Configuration file")
+			Expect(result.Status).To(Equal(doctor.StatusWarn))
+			Expect(result.Message).To(HaveSuffix("does not exist"))
+		})
+
+		It("passes when the file is readable and writable", func() {
+			writeConfig("packages: []", 0644)
+			Expect(runCheck("Configuration file").Status).To(Equal(doctor.StatusPass))
+		})
+
+		It("warns when the file is writable by every user", func() {
+			writeConfig("packages: []", 0666)
+			result := runCheck("Configuration file")
+			Expect(result.Status).To(Equal(doctor.StatusWarn))
+			Expect(result.Hint).To(HavePrefix("Run `chmod o-w "))
+		})
+
+		It("only requires the system and project configuration to be readable", func() {
+			writeConfig("packages: []", 0644)
+			systemPath := path.Join(systemDir, store.LocalDefaultFileName)
+			Expect(os.WriteFile(systemPath, []byte("packages: []"), 0444)).To(Succeed())
+
+			result := runCheck("Configuration file")
+			Expect(result.Status).To(Equal(doctor.StatusPass))
+			Expect(result.Message).To(Equal("`" + systemPath + "`; `" + path.Join(configDir, store.LocalDefaultFileName) + "`"))
+		})
+
+		It("warns about a project configuration writable by every user", func() {
+			writeConfig("packages: []", 0644)
+			projectPath := path.Join(projectDir, store.ProjectFileName)
+			Expect(os.WriteFile(projectPath, []byte("packages: []"), 0644)).To(Succeed())
+			Expect(os.Chmod(projectPath, 0666)).To(Succeed())
+
+			Expect(runCheck("Configuration file")).To(Equal(doctor.Result{
+				Status:  doctor.StatusWarn,
+				Message: "`" + projectPath + "` is writable by every user",
+				Hint:    "Run `chmod o-w " + projectPath + "`",
+			}))
+		})
+	})
+
+	Describe("Configuration syntax", func() {
+		It("passes when the configuration parses", func() {
+			writeConfig("packages:\n  - fzf\n  - ripgrep@14.1.0\n", 0644)
+			result := runCheck("Configuration syntax")
+			Expect(result).To(Equal(doctor.Result{Status: doctor.StatusPass, Message: "2 packages"}))
+		})
+
+		It("fails when the configuration is not valid YAML", func() {
+			writeConfig("packages:\n  - fzf\n bad", 0644)
+			result := runCheck("Configuration syntax")
+			Expect(result.Status).To(Equal(doctor.StatusFail))
+			Expect(result.Message).To(ContainSubstring("line 2"))
+		})
+
+		It("checks the configuration of every layer", func() {
+			writeConfig("packages:\n  - fzf\n", 0644)
+			Expect(os.WriteFile(path.Join(systemDir, store.LocalDefaultFileName), []byte("packages:\n  - curl\n"), 0644)).To(Succeed())
+			projectPath := path.Join(projectDir, store.ProjectFileName)
+			Expect(os.WriteFile(projectPath, []byte("packages:\n  - go\n bad"), 0644)).To(Succeed())
+
+			result := runCheck("Configuration syntax")
+			Expect(result.Status).To(Equal(doctor.StatusFail))
+			Expect(result.Message).To(HavePrefix("Unable to parse the configuration: `" + projectPath + "`: yaml: line 2"))
+		})
+	})
+
+	Describe("Alternates", func() {
+		It("warns about alternates for unknown package managers", func() {
+			writeConfig("packages:\n  - name: bat\n    alternates:\n      flatpak: batcat\n", 0644)
+			result := runCheck("Alternates")
+			Expect(result.Status).To(Equal(doctor.StatusWarn))
+			Expect(result.Message).To(Equal("bat: unknown package manager `flatpak`"))
+		})
+
+		It("warns about alternates in the system and project configuration", func() {
+			Expect(os.WriteFile(path.Join(systemDir, store.LocalDefaultFileName), []byte("packages:\n  - name: bat\n    alternates: {flatpak: batcat}\n"), 0644)).To(Succeed())
+			Expect(os.WriteFile(path.Join(projectDir, store.ProjectFileName), []byte("packages:\n  - name: fd\n    alternates: {nix: fd}\n"), 0644)).To(Succeed())
+
+			result := runCheck("Alternates")
+			Expect(result.Status).To(Equal(doctor.StatusWarn))
+			Expect(result.Message).To(Equal("bat: unknown package manager `flatpak`; fd: unknown package manager `nix`"))
+		})
+
+		It("passes when there is no configuration", func() {
+			Expect(runCheck("Alternates")).To(Equal(doctor.Result{Status: doctor.StatusPass, Message: "No configuration to check"}))
+		})
+
+		It("fails when the configuration cannot be read", func() {
+			writeConfig("packages:\n  - fzf\n bad", 0644)
+			result := runCheck("Alternates")
+			Expect(result.Status).To(Equal(doctor.StatusFail))
+			Expect(result.Message).To(HavePrefix("Unable to check alternates: "))
+		})
+	})
+
+	Describe("Terminal colors", func() {
+		It("warns when colors are disabled", func() {
+			GinkgoT().Setenv("NO_COLOR", "1")
+			Expect(runCheck("Terminal colors").Status).To(Equal(doctor.StatusWarn))
+		})
+
+		It("reports the color depth", func() {
+			GinkgoT().Setenv("NO_COLOR", "")
+			GinkgoT().Setenv("COLORTERM", "truecolor")
+			Expect(runCheck("Terminal colors").Message).To(Equal("Colors are enabled (true color)"))
+		})
+	})
+})
@@ -0,0 +1,150 @@
+// Compares the configuration with the system to find where they have drifted apart.
+package drift
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/pkg/sys/pkgmanager"
+)
+
+const (
+	KindMissing = Kind(iota)
+	KindUnmanaged
+	KindVersionMismatch
+	KindUnresolvedAlternate
+)
+
+// Kinds in the order they are reported.
+var Kinds = []Kind{KindMissing, KindUnmanaged, KindVersionMismatch, KindUnresolvedAlternate}
+
+var kindToS = map[Kind]string{
+	KindMissing:             "Missing from the system",
+	KindUnmanaged:           "Not in the configuration",
+	KindVersionMismatch:     "Version mismatches",
+	KindUnresolvedAlternate: "Unresolved alternates",
+}
+
+type (
+	Kind int
+
+	Item struct {
+		Kind    Kind
+		Package string
+		Detail  string
+	}
+
+	Report struct {
+		Items []Item
+	}
+)
+
+// Detect compares the configured packages, resolved for the named manager, with the packages installed on the system.
+// Only the explicitly installed packages are reported as not in the configuration, so packages installed as
+// dependencies are not drift.
+func Detect(cfg *store.Configuration, managerName string, sysPkgs, explicitPkgs []*model.Package) *Report {
+	report := &Report{}
+
+	installed := make(map[string]*model.Package, len(sysPkgs))
+	for _, pkg := range sysPkgs {
+		installed[pkg.Name] = pkg
+	}
+
+	report.Items = UnresolvedAlternates(cfg)
+	configured := make(map[string]bool, len(cfg.Packages))
+	for _, pkg := range cfg.Packages {
+		resolved := pkg.ForManager(managerName)
+		configured[resolved.Name] = true
+
+		sysPkg, ok := installed[resolved.Name]
+		if !ok {
+			report.add(KindMissing, resolved.String(), "")
+			continue
+		}
+
+		if resolved.Version != "" && !versionMatches(resolved.Version, sysPkg.Version) {
+			report.add(KindVersionMismatch, resolved.Name, fmt.Sprintf("configured %s, installed %s", resolved.Version, sysPkg.Version))
+		}
+	}
+
+	for _, pkg := range explicitPkgs {
+		if configured[pkg.Name] {
+			continue
+		}
+
+		if sysPkg, ok := installed[pkg.Name]; ok {
+			pkg = sysPkg
+		}
+
+		report.add(KindUnmanaged, pkg.String(), "")
+	}
+
+	slices.SortStableFunc(report.Items, func(a, b Item) int {
+		return cmp.Or(cmp.Compare(a.Kind, b.Kind), cmp.Compare(a.Package, b.Package))
+	})
+
+	return report
+}
+
+// UnresolvedAlternates returns an item for each alternate of a package manager that is not supported,
+// which can never be resolved.
+func UnresolvedAlternates(cfg *store.Configuration) []Item {
+	var items []Item
+	for _, pkg := range cfg.Packages {
+		for managerName := range pkg.Alternates {
+			if _, ok := pkgmanager.Managers[managerName]; !ok {
+				items = append(items, Item{
+					Kind:    KindUnresolvedAlternate,
+					Package: pkg.Name,
+					Detail:  fmt.Sprintf("unknown package manager `%s`", managerName),
+				})
+			}
+		}
+	}
+
+	slices.SortFunc(items, func(a, b Item) int {
+		return cmp.Or(cmp.Compare(a.Package, b.Package), cmp.Compare(a.Detail, b.Detail))
+	})
+
+	return items
+}
+
+// Of returns the items of the given kind.
+func (r *Report) Of(kind Kind) []Item {
+	var items []Item
+	for _, item := range r.Items {
+		if item.Kind == kind {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// Drifted reports whether the configuration and system differ.
+func (r *Report) Drifted() bool {
+	return len(r.Items) > 0
+}
+
+func (k Kind) String() string {
+	return kindToS[k]
+}
+
+func (r *Report) add(kind Kind, pkg, detail string) {
+	r.Items = append(r.Items, Item{Kind: kind, Package: pkg, Detail: detail})
+}
+
+// versionMatches reports whether the installed version satisfies the configured one, which may
+// omit trailing components or a distribution suffix, e.g. `1.2` matches `1.2.3-1ubuntu1`.
+func versionMatches(configured, installed string) bool {
+	if !strings.HasPrefix(installed, configured) {
+		return false
+	}
+
+	rest := installed[len(configured):]
+	return rest == "" || strings.ContainsRune(".-+~:", rune(rest[0]))
+}
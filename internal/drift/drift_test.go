@@ -0,0 +1,71 @@
+package drift_test
+
+import (
+	"github.com/drew-english/system-configurator/internal/drift"
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/internal/store"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Detect", func() {
+	var (
+		cfg                   *store.Configuration
+		sysPkgs, explicitPkgs []*model.Package
+	)
+
+	BeforeEach(func() {
+		cfg = &store.Configuration{
+			Packages: []*model.Package{
+				{Name: "fzf", Version: "0.44"},
+				{Name: "ripgrep", Alternates: map[string]*model.Package{"apt": {Name: "rg"}}},
+			},
+		}
+		sysPkgs = []*model.Package{
+			{Name: "fzf", Version: "0.44.1-1"},
+			{Name: "rg", Version: "13.0.0"},
+		}
+		explicitPkgs = []*model.Package{{Name: "fzf"}, {Name: "rg"}}
+	})
+
+	It("reports nothing when the configuration and system match", func() {
+		report := drift.Detect(cfg, "apt", sysPkgs, explicitPkgs)
+		Expect(report.Drifted()).To(BeFalse())
+	})
+
+	It("reports every kind of drift", func() {
+		cfg.Packages = append(cfg.Packages,
+			&model.Package{Name: "bat", Alternates: map[string]*model.Package{"flatpak": {Name: "batcat"}}},
+			&model.Package{Name: "htop", Version: "3.2"},
+		)
+		sysPkgs = append(sysPkgs,
+			&model.Package{Name: "htop", Version: "3.20.1"},
+			&model.Package{Name: "curl", Version: "8.5.0"},
+		)
+		explicitPkgs = append(explicitPkgs, &model.Package{Name: "htop"}, &model.Package{Name: "curl"})
+
+		report := drift.Detect(cfg, "apt", sysPkgs, explicitPkgs)
+		Expect(report.Drifted()).To(BeTrue())
+		Expect(report.Items).To(Equal([]drift.Item{
+			{Kind: drift.KindMissing, Package: "bat"},
+			{Kind: drift.KindUnmanaged, Package: "curl@8.5.0"},
+			{Kind: drift.KindVersionMismatch, Package: "htop", Detail: "configured 3.2, installed 3.20.1"},
+			{Kind: drift.KindUnresolvedAlternate, Package: "bat", Detail: "unknown package manager `flatpak`"},
+		}))
+		Expect(report.Of(drift.KindMissing)).To(HaveLen(1))
+	})
+
+	It("resolves packages for the given manager", func() {
+		report := drift.Detect(cfg, "brew", sysPkgs, explicitPkgs)
+		Expect(report.Of(drift.KindMissing)).To(Equal([]drift.Item{{Kind: drift.KindMissing, Package: "ripgrep"}}))
+		Expect(report.Of(drift.KindUnmanaged)).To(Equal([]drift.Item{{Kind: drift.KindUnmanaged, Package: "rg@13.0.0"}}))
+	})
+
+	It("does not report packages installed as dependencies", func() {
+		sysPkgs = append(sysPkgs, &model.Package{Name: "libc6", Version: "2.39-0ubuntu8"})
+
+		report := drift.Detect(cfg, "apt", sysPkgs, explicitPkgs)
+		Expect(report.Drifted()).To(BeFalse())
+	})
+})
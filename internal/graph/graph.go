@@ -0,0 +1,108 @@
+// Orders items that require other items to be done before them.
+package graph
+
+import (
+	"fmt"
+	"strings"
+)
+
+type (
+	// Graph is a directed graph of items and the items they require.
+	Graph struct {
+		nodes    []string
+		requires map[string][]string
+	}
+
+	// CycleError is returned when items require each other.
+	CycleError struct {
+		Cycle []string // the items of the cycle, starting and ending with the same item
+	}
+
+	// MissingError is returned when an item requires one that is not in the graph.
+	MissingError struct {
+		Node     string
+		Requires string
+	}
+)
+
+func New() *Graph {
+	return &Graph{requires: make(map[string][]string)}
+}
+
+// Add adds an item and the items it requires, which must also be added before the graph is sorted.
+// Adding an item again adds to its requirements.
+func (g *Graph) Add(node string, requires ...string) {
+	if _, ok := g.requires[node]; !ok {
+		g.nodes = append(g.nodes, node)
+	}
+
+	g.requires[node] = append(g.requires[node], requires...)
+}
+
+// Requires returns the items node requires.
+func (g *Graph) Requires(node string) []string {
+	return g.requires[node]
+}
+
+// Sort returns every item after the items it requires. Items that do not depend on each other
+// keep the order they were added in, so the result is the same for the same graph.
+func (g *Graph) Sort() ([]string, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int, len(g.nodes))
+	sorted := make([]string, 0, len(g.nodes))
+	var path []string
+
+	var visit func(node string) error
+	visit = func(node string) error {
+		switch state[node] {
+		case visited:
+			return nil
+		case visiting:
+			start := 0
+			for path[start] != node {
+				start++
+			}
+
+			cycle := append([]string{}, path[start:]...)
+			return &CycleError{Cycle: append(cycle, node)}
+		}
+
+		state[node] = visiting
+		path = append(path, node)
+		for _, required := range g.requires[node] {
+			if _, ok := g.requires[required]; !ok {
+				return &MissingError{Node: node, Requires: required}
+			}
+
+			if err := visit(required); err != nil {
+				return err
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[node] = visited
+		sorted = append(sorted, node)
+		return nil
+	}
+
+	for _, node := range g.nodes {
+		if err := visit(node); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
+
+func (e *CycleError) Error() string {
+	return "dependency cycle: " + strings.Join(e.Cycle, " -> ")
+}
+
+func (e *MissingError) Error() string {
+	return fmt.Sprintf("`%s` requires `%s`, which is not defined", e.Node, e.Requires)
+}
@@ -0,0 +1,70 @@
+package graph_test
+
+import (
+	"github.com/drew-english/system-configurator/internal/graph"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Graph", func() {
+	var g *graph.Graph
+
+	BeforeEach(func() {
+		g = graph.New()
+	})
+
+	Describe("Sort", func() {
+		It("orders items after the items they require", func() {
+			g.Add("docker-compose", "docker-ce")
+			g.Add("docker-ce", "repository:docker")
+			g.Add("fzf")
+			g.Add("repository:docker")
+
+			Expect(g.Sort()).To(Equal([]string{"repository:docker", "docker-ce", "docker-compose", "fzf"}))
+		})
+
+		It("keeps the order items were added in when they are independent", func() {
+			g.Add("zoxide")
+			g.Add("bat")
+			g.Add("fzf")
+
+			Expect(g.Sort()).To(Equal([]string{"zoxide", "bat", "fzf"}))
+		})
+
+		It("adds to the requirements of an item added again", func() {
+			g.Add("a", "b")
+			g.Add("b")
+			g.Add("c")
+			g.Add("a", "c")
+
+			Expect(g.Requires("a")).To(Equal([]string{"b", "c"}))
+			Expect(g.Sort()).To(Equal([]string{"b", "c", "a"}))
+		})
+
+		It("returns the cycle when items require each other", func() {
+			g.Add("a", "b")
+			g.Add("b", "c")
+			g.Add("c", "b")
+
+			_, err := g.Sort()
+			Expect(err).To(MatchError("dependency cycle: b -> c -> b"))
+			Expect(err).To(BeAssignableToTypeOf(&graph.CycleError{}))
+		})
+
+		It("returns an error when an item requires itself", func() {
+			g.Add("a", "a")
+
+			_, err := g.Sort()
+			Expect(err).To(MatchError("dependency cycle: a -> a"))
+		})
+
+		It("returns an error when a required item was not added", func() {
+			g.Add("a", "b")
+
+			_, err := g.Sort()
+			Expect(err).To(MatchError("`a` requires `b`, which is not defined"))
+			Expect(err).To(BeAssignableToTypeOf(&graph.MissingError{}))
+		})
+	})
+})
@@ -0,0 +1,261 @@
+// Append-only journal of the commands that modified the system or configuration.
+package history
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/drew-english/system-configurator/internal/mode"
+	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/internal/transaction"
+	"github.com/drew-english/system-configurator/pkg/logging"
+	"gopkg.in/yaml.v3"
+)
+
+const historyFileName = "history.jsonl"
+
+type Entry struct {
+	ID               int       `json:"id"`
+	Time             time.Time `json:"time"`
+	Command          string    `json:"command"`
+	Args             []string  `json:"args,omitempty"`
+	Mode             string    `json:"mode"`
+	Manager          string    `json:"manager,omitempty"`
+	Packages         []string  `json:"packages,omitempty"`
+	ExitStatus       int       `json:"exit_status"`
+	ConfigHashBefore string    `json:"config_hash_before,omitempty"`
+	ConfigHashAfter  string    `json:"config_hash_after,omitempty"`
+
+	Steps  []transaction.Step `json:"steps,omitempty"`  // changes applied, used to undo the entry
+	Undoes int                `json:"undoes,omitempty"` // ID of the entry this entry undid
+
+	cfg        *store.Configuration
+	tx         *transaction.Transaction
+	unfinished *transaction.Journal
+}
+
+// Path of the history file.
+var Path = func() (string, error) {
+	location, err := store.StateDefaultLocation()
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(location, historyFileName), nil
+}
+
+// Now provides a hook for testing.
+var Now = time.Now
+
+// Begin starts an entry for a command, which is appended to the history by Finish.
+func Begin(command string, args []string) *Entry {
+	return &Entry{
+		Time:    Now(),
+		Command: command,
+		Args:    args,
+		Mode:    mode.Current().String(),
+	}
+}
+
+// TrackConfig records the hash of cfg before the command modifies it, and hashes it again on Finish.
+func (e *Entry) TrackConfig(cfg *store.Configuration) {
+	e.cfg = cfg
+	e.ConfigHashBefore = configHash(cfg)
+}
+
+// TrackTransaction records the steps applied by tx on Finish.
+func (e *Entry) TrackTransaction(tx *transaction.Transaction) {
+	e.tx = tx
+}
+
+// KeepUnfinished records journal on Finish as the changes left to undo of the entry it undoes,
+// so a later undo can reverse them. A journal without steps means none are left.
+func (e *Entry) KeepUnfinished(journal *transaction.Journal) {
+	e.unfinished = journal
+}
+
+// Finish records the exit status for err and appends the entry to the history.
+// Once recorded, the tracked transaction's journal is no longer needed and is discarded,
+// as is the journal of an operation that never finished when the changes left of it are kept.
+// Failing to write the history is logged rather than failing the command.
+func (e *Entry) Finish(err error) {
+	e.ExitStatus = exitStatus(err)
+	if e.cfg != nil {
+		e.ConfigHashAfter = configHash(e.cfg)
+	}
+
+	if e.tx != nil {
+		e.Steps = e.tx.Steps
+	}
+
+	if err := Append(e); err != nil {
+		logging.Warn("unable to write history", "error", err)
+		return
+	}
+
+	if e.unfinished != nil {
+		if err := e.unfinished.SaveUnfinished(); err != nil {
+			logging.Warn("unable to save unfinished operation", "error", err)
+		}
+	}
+
+	if (e.tx != nil && len(e.tx.Steps) > 0) || (e.unfinished != nil && e.unfinished.Undoes == 0) {
+		if err := transaction.Discard(); err != nil {
+			logging.Warn("unable to discard transaction journal", "error", err)
+		}
+	}
+}
+
+// Append assigns the next ID to the entry and writes it to the end of the history.
+func Append(e *Entry) error {
+	entries, err := List()
+	if err != nil {
+		return err
+	}
+
+	e.ID = 1
+	if len(entries) > 0 {
+		e.ID = entries[len(entries)-1].ID + 1
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	historyPath, err := Path()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(path.Dir(historyPath), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(historyPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// List returns every entry in the order they were recorded.
+func List() ([]*Entry, error) {
+	historyPath, err := Path()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(historyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*Entry
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1024*1024)
+	for line := 1; s.Scan(); line++ {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+
+		entry := &Entry{}
+		if err := json.Unmarshal(s.Bytes(), entry); err != nil {
+			return nil, fmt.Errorf("invalid history entry on line %d: %w", line, err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, s.Err()
+}
+
+// Find returns the entry with the given ID, or nil if there is none.
+func Find(id int) (*Entry, error) {
+	entries, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.ID == id {
+			return entry, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// LastUndoable returns the latest entry with changes that have not been undone, or nil if there is none.
+func LastUndoable() (*Entry, error) {
+	entries, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	undone := undoneBy(entries)
+	for i := len(entries) - 1; i >= 0; i-- {
+		if len(entries[i].Steps) > 0 && undone[entries[i].ID] == nil {
+			return entries[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// UndoneBy returns the entry that undid the entry with the given ID, or nil if it has not been undone.
+func UndoneBy(id int) (*Entry, error) {
+	entries, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	return undoneBy(entries)[id], nil
+}
+
+func undoneBy(entries []*Entry) map[int]*Entry {
+	undone := make(map[int]*Entry)
+	for _, entry := range entries {
+		if entry.Undoes != 0 {
+			undone[entry.Undoes] = entry
+		}
+	}
+
+	return undone
+}
+
+func configHash(cfg *store.Configuration) string {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	return 1
+}
@@ -0,0 +1,19 @@
+package history_test
+
+import (
+	"testing"
+
+	"github.com/drew-english/system-configurator/spec/stub/store"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestHistory(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "History Suite")
+}
+
+var _ = BeforeEach(func() {
+	DeferCleanup(store.StubStateLocation(GinkgoT().TempDir()))
+})
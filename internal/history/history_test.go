@@ -0,0 +1,153 @@
+package history_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/drew-english/system-configurator/internal/history"
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/internal/report"
+	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/internal/transaction"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/spf13/viper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("History", func() {
+	var now = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	BeforeEach(func() {
+		viper.Set("mode", "hybrid")
+		original := history.Now
+		history.Now = func() time.Time { return now }
+		DeferCleanup(func() { history.Now = original })
+	})
+
+	Describe("Finish", func() {
+		It("appends the entry with the next id", func() {
+			history.Begin("package add", []string{"fzf"}).Finish(nil)
+			entry := history.Begin("package remove", []string{"fzf"})
+			entry.Manager = "apt"
+			entry.Packages = []string{"fzf"}
+			entry.Finish(nil)
+
+			entries, err := history.List()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(HaveLen(2))
+			Expect(entries[1]).To(BeComparableTo(&history.Entry{
+				ID:       2,
+				Time:     now,
+				Command:  "package remove",
+				Args:     []string{"fzf"},
+				Mode:     "hybrid",
+				Manager:  "apt",
+				Packages: []string{"fzf"},
+			}, cmpopts.IgnoreUnexported(history.Entry{})))
+		})
+
+		It("records the exit status of the error", func() {
+			history.Begin("package add", nil).Finish(errors.New("failed"))
+			history.Begin("package add", nil).Finish(&report.ExitError{Code: report.ExitPartialFailure, Err: errors.New("partial")})
+
+			entries, _ := history.List()
+			Expect(entries[0].ExitStatus).To(Equal(1))
+			Expect(entries[1].ExitStatus).To(Equal(report.ExitPartialFailure))
+		})
+
+		It("records the configuration hash before and after the command", func() {
+			cfg := &store.Configuration{}
+			entry := history.Begin("package add", nil)
+			entry.TrackConfig(cfg)
+			cfg.AddPackage(&model.Package{Name: "fzf"})
+			entry.Finish(nil)
+
+			found, err := history.Find(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found.ConfigHashBefore).To(HaveLen(64))
+			Expect(found.ConfigHashAfter).To(HaveLen(64))
+			Expect(found.ConfigHashAfter).ToNot(Equal(found.ConfigHashBefore))
+		})
+
+		It("records the steps of the transaction and discards its journal", func() {
+			tx, err := transaction.Begin(context.Background(), nil, &store.Configuration{})
+			Expect(err).ToNot(HaveOccurred())
+
+			entry := history.Begin("package add", nil)
+			entry.TrackTransaction(tx)
+			Expect(tx.AddToConfig(&model.Package{Name: "fzf"})).To(Succeed())
+			entry.Finish(nil)
+
+			found, _ := history.Find(1)
+			Expect(found.Steps).To(Equal([]transaction.Step{
+				{Kind: transaction.StepConfigAdd, Package: &model.Package{Name: "fzf"}},
+			}))
+			Expect(transaction.LoadJournal()).To(BeNil())
+		})
+	})
+
+	Describe("LastUndoable", func() {
+		var steps = []transaction.Step{{Kind: transaction.StepConfigAdd, Package: &model.Package{Name: "fzf"}}}
+
+		It("returns the latest entry with changes that was not undone", func() {
+			Expect(history.Append(&history.Entry{Command: "package add", Steps: steps})).To(Succeed())
+			Expect(history.Append(&history.Entry{Command: "package add", Steps: steps})).To(Succeed())
+			Expect(history.Append(&history.Entry{Command: "undo", Steps: steps, Undoes: 2})).To(Succeed())
+			Expect(history.Append(&history.Entry{Command: "package list"})).To(Succeed())
+
+			last, err := history.LastUndoable()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(last.ID).To(Equal(3))
+
+			Expect(history.Append(&history.Entry{Command: "undo", Steps: steps, Undoes: 3})).To(Succeed())
+			last, _ = history.LastUndoable()
+			Expect(last.ID).To(Equal(5))
+		})
+
+		It("returns nil when there is nothing to undo", func() {
+			Expect(history.Append(&history.Entry{Command: "package list"})).To(Succeed())
+			Expect(history.LastUndoable()).To(BeNil())
+		})
+	})
+
+	Describe("UndoneBy", func() {
+		It("returns the entry that undid the given entry", func() {
+			Expect(history.Append(&history.Entry{Command: "package add"})).To(Succeed())
+			Expect(history.UndoneBy(1)).To(BeNil())
+
+			Expect(history.Append(&history.Entry{Command: "undo", Undoes: 1})).To(Succeed())
+			undoneBy, err := history.UndoneBy(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(undoneBy.ID).To(Equal(2))
+		})
+	})
+
+	Describe("List", func() {
+		It("returns nothing when there is no history", func() {
+			Expect(history.List()).To(BeEmpty())
+		})
+
+		Context("when an entry is malformed", func() {
+			It("returns an error", func() {
+				history.Begin("package add", nil).Finish(nil)
+				historyPath, _ := history.Path()
+				f, _ := os.OpenFile(historyPath, os.O_APPEND|os.O_WRONLY, 0644)
+				f.WriteString("{not json\n")
+				f.Close()
+
+				_, err := history.List()
+				Expect(err).To(MatchError(ContainSubstring("invalid history entry on line 2")))
+			})
+		})
+	})
+
+	Describe("Find", func() {
+		It("returns nil when the entry does not exist", func() {
+			Expect(history.Find(3)).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,244 @@
+package manifest
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/pkg/sys/pkgmanager"
+	"gopkg.in/yaml.v3"
+)
+
+type exporter struct {
+	// manager is the only package manager the format supports, empty when any can be used.
+	manager string
+	// base exports the packages without resolving them for a package manager.
+	base   bool
+	render func(w io.Writer, pkgs []*model.Package, manager pkgmanager.PacakgeManager) error
+}
+
+var exporters = map[string]exporter{
+	"ansible":    {render: exportAnsible},
+	"brewfile":   {manager: "brew", render: exportBrewfile},
+	"dockerfile": {render: exportDockerfile},
+	"nix":        {base: true, render: exportNix},
+	"shell":      {render: exportShell},
+}
+
+// ansibleModules are the Ansible modules installing packages with each package manager, and whether they accept versions.
+var ansibleModules = map[string]struct {
+	name     string
+	versions bool
+}{
+	"apk":    {"community.general.apk", true},
+	"apt":    {"ansible.builtin.apt", true},
+	"brew":   {"community.general.homebrew", false},
+	"dnf":    {"ansible.builtin.dnf", true},
+	"pacman": {"community.general.pacman", true},
+	"snap":   {"community.general.snap", false},
+}
+
+// dockerCommands refresh the package index before installing and clean up the caches after, to keep image layers small.
+var dockerCommands = map[string]struct{ before, after string }{
+	"apk":    {"apk update", "rm -rf /var/cache/apk/*"},
+	"apt":    {"apt-get update", "rm -rf /var/lib/apt/lists/*"},
+	"dnf":    {"", "dnf clean all"},
+	"pacman": {"pacman -Syu --noconfirm", "pacman -Scc --noconfirm"},
+}
+
+var (
+	shellSafeRegex     = regexp.MustCompile(`^[\w@%+=:,./-]+$`)
+	nixIdentifierRegex = regexp.MustCompile(`^[a-zA-Z_][\w'-]*$`)
+)
+
+// ExportFormats returns the names of the formats the configuration can be exported to.
+func ExportFormats() []string {
+	formats := make([]string, 0, len(exporters))
+	for format := range exporters {
+		formats = append(formats, format)
+	}
+
+	slices.Sort(formats)
+	return formats
+}
+
+// Export writes pkgs in the given format, resolved for the named package manager or the
+// host's package manager when no name is given.
+func Export(w io.Writer, format string, pkgs []*model.Package, managerName string) error {
+	exporter, ok := exporters[format]
+	if !ok {
+		return fmt.Errorf("unknown format `%s`, expected one of: %s", format, strings.Join(ExportFormats(), ", "))
+	}
+
+	if exporter.base {
+		return exporter.render(w, pkgs, nil)
+	}
+
+	if exporter.manager != "" {
+		if managerName != "" && managerName != exporter.manager {
+			return fmt.Errorf("the %s format only supports %s", format, exporter.manager)
+		}
+
+		managerName = exporter.manager
+	}
+
+	var manager pkgmanager.PacakgeManager
+	if managerName == "" {
+		var err error
+		if manager, err = pkgmanager.FindPackageManager(); err != nil {
+			return err
+		}
+	} else if manager, ok = pkgmanager.Managers[managerName]; !ok {
+		return fmt.Errorf("unknown package manager `%s`", managerName)
+	}
+
+	resolved := make([]*model.Package, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		resolved = append(resolved, pkg.ForManager(manager.Name()))
+	}
+
+	return exporter.render(w, resolved, manager)
+}
+
+// exportBrewfile writes a Brewfile, tapping the taps packages are installed from.
+func exportBrewfile(w io.Writer, pkgs []*model.Package, _ pkgmanager.PacakgeManager) error {
+	var taps, entries []string
+	for _, pkg := range pkgs {
+		name := pkg.Name
+		tap := pkg.Tap
+		if tap != "" {
+			name = tap + "/" + pkg.Name
+		} else if parts := strings.Split(pkg.Name, "/"); len(parts) == 3 {
+			tap = parts[0] + "/" + parts[1]
+		}
+
+		if tap != "" && !slices.Contains(taps, tap) {
+			taps = append(taps, tap)
+		}
+
+		entry := "brew"
+		if pkg.IsCask() {
+			entry = "cask"
+		}
+
+		entries = append(entries, fmt.Sprintf("%s %q\n", entry, name))
+	}
+
+	for _, tap := range taps {
+		fmt.Fprintf(w, "tap %q\n", tap)
+	}
+
+	_, err := io.WriteString(w, strings.Join(entries, ""))
+	return err
+}
+
+// exportDockerfile writes a RUN instruction installing the packages.
+func exportDockerfile(w io.Writer, pkgs []*model.Package, manager pkgmanager.PacakgeManager) error {
+	var commands []string
+	docker := dockerCommands[manager.Name()]
+	if docker.before != "" {
+		commands = append(commands, docker.before)
+	}
+
+	commands = append(commands, installCommand(manager, pkgs, "      "))
+	if docker.after != "" {
+		commands = append(commands, docker.after)
+	}
+
+	_, err := fmt.Fprintf(w, "RUN %s\n", strings.Join(commands, " && \\\n    "))
+	return err
+}
+
+// exportShell writes a POSIX shell script installing the packages.
+func exportShell(w io.Writer, pkgs []*model.Package, manager pkgmanager.PacakgeManager) error {
+	_, err := fmt.Fprintf(w, "#!/bin/sh\nset -eu\n\n%s\n", installCommand(manager, pkgs, "  "))
+	return err
+}
+
+// exportAnsible writes an Ansible task list installing the packages with the module for the package manager.
+func exportAnsible(w io.Writer, pkgs []*model.Package, manager pkgmanager.PacakgeManager) error {
+	module, ok := ansibleModules[manager.Name()]
+	if !ok {
+		return fmt.Errorf("no Ansible module for %s", manager.Name())
+	}
+
+	names := make([]string, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		name := pkg.Name
+		if module.versions {
+			name = manager.FmtPackageVersion(pkg)
+		}
+
+		names = append(names, name)
+	}
+
+	type packageArgs struct {
+		Name  []string `yaml:"name"`
+		State string   `yaml:"state"`
+	}
+
+	tasks := []struct {
+		Name   string                 `yaml:"name"`
+		Module map[string]packageArgs `yaml:",inline"`
+	}{
+		{Name: "Install packages", Module: map[string]packageArgs{module.name: {Name: names, State: "present"}}},
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(tasks); err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
+
+// exportNix writes a Nix expression for the list of packages, for use in environment.systemPackages
+// or home.packages.
+func exportNix(w io.Writer, pkgs []*model.Package, _ pkgmanager.PacakgeManager) error {
+	var b strings.Builder
+	b.WriteString("{ pkgs ? import <nixpkgs> { } }:\n\nwith pkgs; [\n")
+	for _, pkg := range pkgs {
+		if nixIdentifierRegex.MatchString(pkg.Name) {
+			fmt.Fprintf(&b, "  %s\n", pkg.Name)
+		} else {
+			fmt.Fprintf(&b, "  pkgs.%q\n", pkg.Name)
+		}
+	}
+
+	b.WriteString("]\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// installCommand returns the shell command installing pkgs, with each package on its own line.
+func installCommand(manager pkgmanager.PacakgeManager, pkgs []*model.Package, indent string) string {
+	base := manager.InstallCommand()
+	args := manager.InstallCommand(pkgs...)
+
+	var b strings.Builder
+	for i, arg := range base {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+
+		b.WriteString(shellQuote(arg))
+	}
+
+	for _, arg := range args[len(base):] {
+		b.WriteString(" \\\n" + indent + shellQuote(arg))
+	}
+
+	return b.String()
+}
+
+func shellQuote(s string) string {
+	if shellSafeRegex.MatchString(s) {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
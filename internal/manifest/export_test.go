@@ -0,0 +1,102 @@
+package manifest_test
+
+import (
+	"strings"
+
+	"github.com/drew-english/system-configurator/internal/manifest"
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/spec/stub/pkgmanager"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Export", func() {
+	var (
+		pkgs    []*model.Package
+		manager string
+	)
+
+	subject := func(format string) (string, error) {
+		out := &strings.Builder{}
+		err := manifest.Export(out, format, pkgs, manager)
+		return out.String(), err
+	}
+
+	BeforeEach(func() {
+		manager = "apt"
+		pkgs = []*model.Package{
+			{Name: "fd", Alternates: map[string]*model.Package{"apt": {Name: "fd-find"}}},
+			{Name: "stow", Version: "2.3.1"},
+			{Name: "terraform", Alternates: map[string]*model.Package{"brew": {Name: "hashicorp/tap/terraform"}}},
+		}
+	})
+
+	It("returns an error for an unknown format", func() {
+		_, err := subject("rpm")
+		Expect(err).To(MatchError("unknown format `rpm`, expected one of: ansible, brewfile, dockerfile, nix, shell"))
+	})
+
+	It("returns an error for an unknown package manager", func() {
+		manager = "yum"
+		_, err := subject("shell")
+		Expect(err).To(MatchError("unknown package manager `yum`"))
+	})
+
+	It("resolves the packages for the host package manager by default", func() {
+		manager = ""
+		pkgmanager.StubFindPackageManager("dnf")
+		Expect(subject("shell")).To(Equal("#!/bin/sh\nset -eu\n\ndnf install -y \\\n  fd \\\n  stow-2.3.1 \\\n  terraform\n"))
+	})
+
+	It("writes a Dockerfile RUN instruction", func() {
+		Expect(subject("dockerfile")).To(Equal(`RUN apt-get update && \
+    apt install -y \
+      fd-find \
+      stow=2.3.1 \
+      terraform && \
+    rm -rf /var/lib/apt/lists/*
+`))
+	})
+
+	It("writes an Ansible task list", func() {
+		Expect(subject("ansible")).To(Equal(`- name: Install packages
+  ansible.builtin.apt:
+    name:
+      - fd-find
+      - stow=2.3.1
+      - terraform
+    state: present
+`))
+	})
+
+	It("writes a Nix package list with the base package names", func() {
+		pkgs = append(pkgs, &model.Package{Name: "python3.12"})
+		Expect(subject("nix")).To(Equal("{ pkgs ? import <nixpkgs> { } }:\n\nwith pkgs; [\n  fd\n  stow\n  terraform\n  pkgs.\"python3.12\"\n]\n"))
+	})
+
+	Context("with a Brewfile", func() {
+		BeforeEach(func() {
+			manager = ""
+		})
+
+		It("writes the formulae for brew and taps their taps", func() {
+			Expect(subject("brewfile")).To(Equal("tap \"hashicorp/tap\"\nbrew \"fd\"\nbrew \"stow\"\nbrew \"hashicorp/tap/terraform\"\n"))
+		})
+
+		It("writes casks and the taps packages are installed from", func() {
+			pkgs = []*model.Package{
+				{Name: "firefox", Kind: model.KindCask},
+				{Name: "font-fira-code", Kind: model.KindCask, Tap: "homebrew/cask-fonts"},
+			}
+
+			Expect(subject("brewfile")).To(Equal("tap \"homebrew/cask-fonts\"\ncask \"firefox\"\ncask \"homebrew/cask-fonts/font-fira-code\"\n"))
+		})
+
+		It("returns an error for another package manager", func() {
+			manager = "apt"
+			_, err := subject("brewfile")
+			Expect(err).To(MatchError("the brewfile format only supports brew"))
+		})
+	})
+})
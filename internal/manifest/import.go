@@ -0,0 +1,138 @@
+package manifest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/drew-english/system-configurator/internal/model"
+)
+
+const aptCloneInstalledPkgs = "var/lib/apt-clone/installed.pkgs"
+
+var brewfileEntryRegex = regexp.MustCompile(`^(\w+)\s+(?:"([^"]*)"|'([^']*)')(?:\s*,\s*(?:"([^"]*)"|'([^']*)'))?`)
+
+// importBrewfile reads the formulae and casks of a Homebrew Brewfile, recording the tap of
+// packages given with their full name. Taps are recorded as brew repositories.
+func importBrewfile(data []byte, _ Options) (*Manifest, error) {
+	m := &Manifest{}
+	lines(data, func(number int, line string) {
+		matches := brewfileEntryRegex.FindStringSubmatch(line)
+		if matches == nil {
+			m.skip(number, line, "unrecognized entry")
+			return
+		}
+
+		name := matches[2] + matches[3]
+		switch matches[1] {
+		case "brew", "cask":
+			pkg := &model.Package{Name: path.Base(name)}
+			if tap := path.Dir(name); tap != "." {
+				pkg.Tap = tap
+			}
+
+			if matches[1] == "cask" {
+				pkg.Kind = model.KindCask
+			}
+
+			m.add(pkg)
+		case "tap":
+			if !model.TapRegex.MatchString(name) {
+				m.skip(number, line, "malformed tap, expected <user>/<repository>")
+				return
+			}
+
+			m.addRepository(&model.Repository{
+				Name:    strings.ReplaceAll(name, "/", "-"),
+				Sources: map[string]*model.RepositorySource{"brew": {Tap: name, URL: matches[4] + matches[5]}},
+			})
+		case "mas":
+			m.unsupported(number, line, "Mac App Store apps are not supported")
+		default:
+			m.skip(number, line, fmt.Sprintf("`%s` entries are not supported", matches[1]))
+		}
+	})
+
+	return m, nil
+}
+
+// importList reads a list of packages with one package per line, as printed by `pacman -Qqe`
+// or `apt-mark showmanual`. A package may be followed by its version, either as name@version
+// or in a second column.
+func importList(data []byte, opts Options) (*Manifest, error) {
+	m := &Manifest{}
+	lines(data, func(number int, line string) {
+		fields := strings.Fields(line)
+		pkg, err := model.ParsePackage(fields[0])
+		if err != nil {
+			m.skip(number, line, "invalid package")
+			return
+		}
+
+		if pkg.Version == "" && len(fields) > 1 {
+			pkg.Version = fields[1]
+		}
+
+		m.add(managerPackage(opts.Manager, pkg.Name, pkg.Name, pkg.Version))
+	})
+
+	return m, nil
+}
+
+// importAptClone reads the manually installed packages of an apt-clone state, either the
+// archive created by `apt-clone clone` or its installed.pkgs file. Packages are recorded
+// with their exact version as an apt alternate.
+func importAptClone(data []byte, _ Options) (*Manifest, error) {
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		var err error
+		if data, err = extractAptClone(data); err != nil {
+			return nil, err
+		}
+	}
+
+	m := &Manifest{}
+	lines(data, func(number int, line string) {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			m.skip(number, line, "expected a package, version and auto installed flag")
+			return
+		}
+
+		if fields[2] == "1" {
+			return
+		}
+
+		name, _, _ := strings.Cut(fields[0], ":")
+		m.add(managerPackage("apt", name, fields[0], fields[1]))
+	})
+
+	return m, nil
+}
+
+func extractAptClone(data []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid apt-clone archive: %w", err)
+	}
+	defer gz.Close()
+
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("invalid apt-clone archive: missing %s", aptCloneInstalledPkgs)
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid apt-clone archive: %w", err)
+		}
+
+		if strings.TrimPrefix(path.Clean(header.Name), "/") == aptCloneInstalledPkgs {
+			return io.ReadAll(archive)
+		}
+	}
+}
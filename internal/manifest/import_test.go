@@ -0,0 +1,151 @@
+package manifest_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+
+	"github.com/drew-english/system-configurator/internal/manifest"
+	"github.com/drew-english/system-configurator/internal/model"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Import", func() {
+	var (
+		format string
+		data   string
+		opts   manifest.Options
+	)
+
+	subject := func() (*manifest.Manifest, error) {
+		return manifest.Import(format, []byte(data), opts)
+	}
+
+	BeforeEach(func() {
+		opts = manifest.Options{}
+	})
+
+	It("returns an error for an unknown format", func() {
+		format = "gemfile"
+		_, err := subject()
+		Expect(err).To(MatchError("unknown format `gemfile`, expected one of: apt-clone, brewfile, list"))
+	})
+
+	Context("with a Brewfile", func() {
+		BeforeEach(func() {
+			format = "brewfile"
+			data = `# Taps
+tap "homebrew/cask-fonts"
+tap "user/private", "https://example.com/user/private.git"
+brew "fzf"
+brew 'ripgrep', args: ["with-pcre2"]
+brew "hashicorp/tap/terraform"
+cask "firefox" # browser
+mas "Xcode", id: 497799835
+vscode "golang.go"
+cask_args appdir: "~/Applications"
+`
+		})
+
+		It("imports the formulae, casks and taps and skips the other entries", func() {
+			m, err := subject()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m.Packages).To(Equal([]*model.Package{
+				{Name: "fzf"},
+				{Name: "ripgrep"},
+				{Name: "terraform", Tap: "hashicorp/tap"},
+				{Name: "firefox", Kind: model.KindCask},
+			}))
+			Expect(m.Repositories).To(Equal([]*model.Repository{
+				{Name: "homebrew-cask-fonts", Sources: map[string]*model.RepositorySource{"brew": {Tap: "homebrew/cask-fonts"}}},
+				{Name: "user-private", Sources: map[string]*model.RepositorySource{"brew": {Tap: "user/private", URL: "https://example.com/user/private.git"}}},
+			}))
+			Expect(m.Skipped).To(Equal([]manifest.Skipped{
+				{Line: 9, Entry: `vscode "golang.go"`, Reason: "`vscode` entries are not supported"},
+				{Line: 10, Entry: `cask_args appdir: "~/Applications"`, Reason: "unrecognized entry"},
+			}))
+			Expect(m.Unsupported).To(Equal([]manifest.Skipped{
+				{Line: 8, Entry: `mas "Xcode", id: 497799835`, Reason: "Mac App Store apps are not supported"},
+			}))
+		})
+	})
+
+	Context("with a list", func() {
+		BeforeEach(func() {
+			format = "list"
+			data = "fzf\n\nripgrep 14.1.0-1\nstow@2.3.1\nfzf\n"
+		})
+
+		It("imports each package once", func() {
+			m, err := subject()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m.Packages).To(Equal([]*model.Package{
+				{Name: "fzf"},
+				{Name: "ripgrep", Version: "14.1.0-1"},
+				{Name: "stow", Version: "2.3.1"},
+			}))
+			Expect(m.Skipped).To(BeEmpty())
+		})
+
+		Context("from a package manager", func() {
+			BeforeEach(func() {
+				opts.Manager = "pacman"
+			})
+
+			It("records the versions as alternates for the manager", func() {
+				m, err := subject()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(m.Packages).To(Equal([]*model.Package{
+					{Name: "fzf"},
+					{Name: "ripgrep", Alternates: map[string]*model.Package{"pacman": {Name: "ripgrep", Version: "14.1.0-1"}}},
+					{Name: "stow", Alternates: map[string]*model.Package{"pacman": {Name: "stow", Version: "2.3.1"}}},
+				}))
+			})
+		})
+	})
+
+	Context("with an apt-clone state", func() {
+		BeforeEach(func() {
+			format = "apt-clone"
+			data = "fzf 0.44.1-1 0\nlibc6 2.39-0ubuntu8 1\nwine32:i386 9.0~repack-4build3 0\nbroken\n"
+		})
+
+		It("imports the manually installed packages as apt alternates", func() {
+			m, err := subject()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m.Packages).To(Equal([]*model.Package{
+				{Name: "fzf", Alternates: map[string]*model.Package{"apt": {Name: "fzf", Version: "0.44.1-1"}}},
+				{Name: "wine32", Alternates: map[string]*model.Package{"apt": {Name: "wine32:i386", Version: "9.0~repack-4build3"}}},
+			}))
+			Expect(m.Skipped).To(Equal([]manifest.Skipped{
+				{Line: 4, Entry: "broken", Reason: "expected a package, version and auto installed flag"},
+			}))
+		})
+
+		Context("when it is an apt-clone archive", func() {
+			BeforeEach(func() {
+				buf := &bytes.Buffer{}
+				gz := gzip.NewWriter(buf)
+				archive := tar.NewWriter(gz)
+				content := "fzf 0.44.1-1 0\n"
+				Expect(archive.WriteHeader(&tar.Header{Name: "./var/lib/apt-clone/installed.pkgs", Mode: 0644, Size: int64(len(content))})).To(Succeed())
+				_, err := archive.Write([]byte(content))
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(archive.Close()).To(Succeed())
+				Expect(gz.Close()).To(Succeed())
+				data = buf.String()
+			})
+
+			It("imports the packages of its installed.pkgs", func() {
+				m, err := subject()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(m.Packages).To(Equal([]*model.Package{
+					{Name: "fzf", Alternates: map[string]*model.Package{"apt": {Name: "fzf", Version: "0.44.1-1"}}},
+				}))
+			})
+		})
+	})
+})
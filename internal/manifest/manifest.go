@@ -0,0 +1,117 @@
+// Package manifest converts between the configuration and the package manifests of other tools.
+package manifest
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/drew-english/system-configurator/internal/model"
+)
+
+type (
+	// Manifest is the result of importing a package manifest.
+	Manifest struct {
+		Packages     []*model.Package
+		Repositories []*model.Repository
+		Skipped      []Skipped
+		// Unsupported are entries for things scfg does not manage, such as Mac App Store apps.
+		Unsupported []Skipped
+	}
+
+	// Skipped is an entry of a manifest that could not be imported.
+	Skipped struct {
+		Line   int
+		Entry  string
+		Reason string
+	}
+
+	// Options adjust how a manifest is imported.
+	Options struct {
+		// Manager is the package manager a plain list was produced by, names and versions
+		// are recorded as alternates for it.
+		Manager string
+	}
+
+	importer func(data []byte, opts Options) (*Manifest, error)
+)
+
+var importers = map[string]importer{
+	"apt-clone": importAptClone,
+	"brewfile":  importBrewfile,
+	"list":      importList,
+}
+
+// ImportFormats returns the names of the formats that can be imported.
+func ImportFormats() []string {
+	formats := make([]string, 0, len(importers))
+	for format := range importers {
+		formats = append(formats, format)
+	}
+
+	slices.Sort(formats)
+	return formats
+}
+
+// Import reads the packages of a manifest in the given format.
+func Import(format string, data []byte, opts Options) (*Manifest, error) {
+	importer, ok := importers[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format `%s`, expected one of: %s", format, strings.Join(ImportFormats(), ", "))
+	}
+
+	return importer(data, opts)
+}
+
+func (m *Manifest) add(pkg *model.Package) {
+	if slices.ContainsFunc(m.Packages, func(p *model.Package) bool { return p.Name == pkg.Name }) {
+		return
+	}
+
+	m.Packages = append(m.Packages, pkg)
+}
+
+func (m *Manifest) addRepository(repo *model.Repository) {
+	if slices.ContainsFunc(m.Repositories, func(r *model.Repository) bool { return r.Name == repo.Name }) {
+		return
+	}
+
+	m.Repositories = append(m.Repositories, repo)
+}
+
+func (m *Manifest) skip(line int, entry, reason string) {
+	m.Skipped = append(m.Skipped, Skipped{Line: line, Entry: entry, Reason: reason})
+}
+
+func (m *Manifest) unsupported(line int, entry, reason string) {
+	m.Unsupported = append(m.Unsupported, Skipped{Line: line, Entry: entry, Reason: reason})
+}
+
+// managerPackage builds a package named name, recording the name and version the manager uses
+// as an alternate when they differ from the package.
+func managerPackage(manager, name, managerName, version string) *model.Package {
+	if manager == "" {
+		return &model.Package{Name: name, Version: version}
+	}
+
+	pkg := &model.Package{Name: name}
+	if managerName != name || version != "" {
+		pkg.Alternates = map[string]*model.Package{
+			manager: {Name: managerName, Version: version},
+		}
+	}
+
+	return pkg
+}
+
+// lines calls fn with each line of data that is not blank or a comment, and its line number.
+func lines(data []byte, fn func(number int, line string)) {
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fn(i+1, line)
+	}
+}
@@ -7,13 +7,27 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-var packageRegex = regexp.MustCompile(`^([^@\s]+)(?:$|@(\S+$))`)
+// Kinds of Homebrew packages, a package without a kind is a formula.
+const (
+	KindFormula = "formula"
+	KindCask    = "cask"
+)
+
+var (
+	packageRegex = regexp.MustCompile(`^([^@\s]+)(?:$|@(\S+$))`)
+	// TapRegex matches a Homebrew tap, <user>/<repository>.
+	TapRegex = regexp.MustCompile(`^[\w.-]+/[\w.-]+$`)
+)
 
 type (
 	Package struct {
-		Name       string
-		Version    string
-		Alternates map[string]*Package // map of alternative package manager name to package info
+		Name       string              `json:"name"`
+		Version    string              `json:"version,omitempty"`
+		Kind       string              `json:"kind,omitempty"`       // formula or cask, only used by brew
+		Tap        string              `json:"tap,omitempty"`        // third-party repository the package is installed from, only used by brew
+		Repository string              `json:"repository,omitempty"` // name of the configured repository the package is installed from
+		Requires   []string            `json:"requires,omitempty"`   // packages and repositories to install before the package
+		Alternates map[string]*Package `json:"alternates,omitempty"` // map of alternative package manager name to package info
 
 		yamlStoredString string
 	}
@@ -21,6 +35,10 @@ type (
 	yamlPkg struct {
 		Name       string              `yaml:"name"`
 		Version    string              `yaml:"version,omitempty"`
+		Kind       string              `yaml:"kind,omitempty"`
+		Tap        string              `yaml:"tap,omitempty"`
+		Repository string              `yaml:"repository,omitempty"`
+		Requires   []string            `yaml:"requires,omitempty"`
 		Alternates map[string]*Package `yaml:"alternates,omitempty"`
 	}
 )
@@ -49,6 +67,11 @@ func (p *Package) ForManager(managerName string) *Package {
 	return p
 }
 
+// IsCask reports whether the package is a Homebrew cask.
+func (p *Package) IsCask() bool {
+	return p.Kind == KindCask
+}
+
 func (p *Package) String() string {
 	s := p.Name
 	if p.Version != "" {
@@ -80,6 +103,10 @@ func (p *Package) UnmarshalYAML(value *yaml.Node) error {
 
 		p.Name = decodedValue.Name
 		p.Version = decodedValue.Version
+		p.Kind = decodedValue.Kind
+		p.Tap = decodedValue.Tap
+		p.Repository = decodedValue.Repository
+		p.Requires = decodedValue.Requires
 		p.Alternates = decodedValue.Alternates
 		return nil
 	}
@@ -102,6 +129,10 @@ func (p *Package) MarshalYAML() (interface{}, error) {
 	return &yamlPkg{
 		Name:       p.Name,
 		Version:    p.Version,
+		Kind:       p.Kind,
+		Tap:        p.Tap,
+		Repository: p.Repository,
+		Requires:   p.Requires,
 		Alternates: p.Alternates,
 	}, nil
 }
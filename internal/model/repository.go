@@ -0,0 +1,24 @@
+package model
+
+type (
+	// Repository is a third-party package repository, configured for each package manager it is available for.
+	Repository struct {
+		Name     string                       `yaml:"name" json:"name"`
+		Requires []string                     `yaml:"requires,omitempty" json:"requires,omitempty"` // packages and repositories to install before the repository, e.g. gnupg
+		Sources  map[string]*RepositorySource `yaml:",inline" json:"sources,omitempty"`             // map of package manager name to the repository for it
+	}
+
+	// RepositorySource is how a package manager installs packages from a repository.
+	RepositorySource struct {
+		URL        string   `yaml:"url,omitempty" json:"url,omitempty"`
+		Key        string   `yaml:"key,omitempty" json:"key,omitempty"`               // URL of the signing key, or its fingerprint for pacman
+		Suite      string   `yaml:"suite,omitempty" json:"suite,omitempty"`           // apt distribution, e.g. noble
+		Components []string `yaml:"components,omitempty" json:"components,omitempty"` // apt components, defaulting to main
+		Tap        string   `yaml:"tap,omitempty" json:"tap,omitempty"`               // brew tap, as <user>/<repository>
+	}
+)
+
+// For returns the source of the repository for a package manager, which is nil when it is not available for it.
+func (r *Repository) For(managerName string) *RepositorySource {
+	return r.Sources[managerName]
+}
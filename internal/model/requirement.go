@@ -0,0 +1,39 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of items a package or repository can require.
+const (
+	RequirePackage    = "package"
+	RequireRepository = "repository"
+)
+
+// RequirementKinds are the kinds of items that can be required.
+var RequirementKinds = []string{RequirePackage, RequireRepository}
+
+// ParseRequirement parses a requirement given as [<kind>:]<name>, where the kind defaults to package,
+// and returns it as <kind>:<name>.
+func ParseRequirement(s string) (string, error) {
+	kind, name, ok := strings.Cut(s, ":")
+	if !ok {
+		kind, name = RequirePackage, s
+	}
+
+	if kind != RequirePackage && kind != RequireRepository {
+		return "", fmt.Errorf("unknown kind `%s` in requirement `%s`, expected package or repository", kind, s)
+	}
+
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return "", fmt.Errorf("invalid requirement `%s`, expected [<kind>:]<name>", s)
+	}
+
+	return Requirement(kind, name), nil
+}
+
+// Requirement returns the requirement of the item of a kind with the given name.
+func Requirement(kind, name string) string {
+	return kind + ":" + name
+}
@@ -0,0 +1,180 @@
+// Tracks the outcome of an operation applied to many items and summarizes it.
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/drew-english/system-configurator/pkg/termio"
+)
+
+// Exit codes used when some or all items of an operation failed.
+const (
+	ExitPartialFailure = 2
+	ExitTotalFailure   = 3
+)
+
+const (
+	StatusSucceeded = Status(iota)
+	StatusFailed
+	StatusSkipped
+	StatusRolledBack
+)
+
+type (
+	Status int
+
+	Result struct {
+		Name   string
+		Status Status
+		Err    error
+		Reason string // why a skipped item was not attempted
+	}
+
+	Summary struct {
+		action  string
+		Results []Result
+	}
+
+	// ExitError carries the exit code the process should terminate with.
+	ExitError struct {
+		Code int
+		Err  error
+	}
+)
+
+// NewSummary creates a summary for an action, e.g. "add", that is used in its messages.
+func NewSummary(action string) *Summary {
+	return &Summary{action: action}
+}
+
+func (s *Summary) Succeeded(name string) {
+	s.Results = append(s.Results, Result{Name: name, Status: StatusSucceeded})
+}
+
+func (s *Summary) Failed(name string, err error) {
+	s.Results = append(s.Results, Result{Name: name, Status: StatusFailed, Err: err})
+}
+
+// Skipped records an item that was not attempted.
+func (s *Summary) Skipped(name string) {
+	s.Results = append(s.Results, Result{Name: name, Status: StatusSkipped})
+}
+
+// SkippedFor records an item that was not attempted for the given reason, e.g. because an item it requires failed.
+func (s *Summary) SkippedFor(name, reason string) {
+	s.Results = append(s.Results, Result{Name: name, Status: StatusSkipped, Reason: reason})
+}
+
+// RolledBack marks every succeeded item as reverted.
+func (s *Summary) RolledBack() {
+	for i, r := range s.Results {
+		if r.Status == StatusSucceeded {
+			s.Results[i].Status = StatusRolledBack
+		}
+	}
+}
+
+func (s *Summary) Count(status Status) int {
+	count := 0
+	for _, r := range s.Results {
+		if r.Status == status {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (s *Summary) Names(status Status) []string {
+	var names []string
+	for _, r := range s.Results {
+		if r.Status == status {
+			names = append(names, r.Name)
+		}
+	}
+
+	return names
+}
+
+// Complete reports whether every item succeeded.
+func (s *Summary) Complete() bool {
+	return s.Count(StatusSucceeded) == len(s.Results)
+}
+
+// Print writes a table of every item and its outcome, writing nothing when there are no items.
+func (s *Summary) Print() {
+	if len(s.Results) == 0 {
+		return
+	}
+
+	style := termio.Style()
+	width := len("PACKAGE")
+	for _, r := range s.Results {
+		width = max(width, len(r.Name))
+	}
+
+	row := func(icon, name, details string) {
+		termio.Print(strings.TrimRight(fmt.Sprintf("%s %-*s  %s", icon, width, name, details), " ") + "\n")
+	}
+
+	termio.Print("\n")
+	row(" ", "PACKAGE", "DETAILS")
+	for _, r := range s.Results {
+		switch r.Status {
+		case StatusSucceeded:
+			row(style.SuccessIcon(), r.Name, "")
+		case StatusFailed:
+			row(style.FailureIcon(), r.Name, firstLine(r.Err.Error()))
+		case StatusSkipped:
+			reason := "not attempted"
+			if r.Reason != "" {
+				reason = "not attempted, " + r.Reason
+			}
+
+			row(style.WarningIcon(), r.Name, reason)
+		case StatusRolledBack:
+			row(style.WarningIcon(), r.Name, "rolled back")
+		}
+	}
+}
+
+// Err returns an ExitError when any item did not succeed, distinguishing a
+// partial failure from one where nothing succeeded.
+func (s *Summary) Err() error {
+	if s.Complete() {
+		return nil
+	}
+
+	code := ExitPartialFailure
+	if s.Count(StatusSucceeded) == 0 {
+		code = ExitTotalFailure
+	}
+
+	return &ExitError{
+		Code: code,
+		Err: fmt.Errorf(
+			"Failed to %s %d of %d packages",
+			s.action,
+			len(s.Results)-s.Count(StatusSucceeded),
+			len(s.Results),
+		),
+	}
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,76 @@
+package report_test
+
+import (
+	"errors"
+
+	"github.com/drew-english/system-configurator/internal/report"
+	"github.com/drew-english/system-configurator/pkg/termio"
+	termio_stub "github.com/drew-english/system-configurator/spec/stub/termio"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Summary", func() {
+	var (
+		summary *report.Summary
+
+		s = termio.Style()
+	)
+
+	BeforeEach(func() {
+		summary = report.NewSummary("add")
+	})
+
+	Describe("Err", func() {
+		It("returns nil when every item succeeded", func() {
+			summary.Succeeded("some-package")
+			Expect(summary.Complete()).To(BeTrue())
+			Expect(summary.Err()).ToNot(HaveOccurred())
+		})
+
+		It("returns a partial failure when some items succeeded", func() {
+			summary.Succeeded("some-package")
+			summary.Failed("some-other-package", errors.New("not found"))
+			summary.Skipped("another-package")
+
+			err := summary.Err()
+			Expect(err).To(MatchError("Failed to add 2 of 3 packages"))
+			Expect(err).To(HaveField("Code", report.ExitPartialFailure))
+		})
+
+		It("returns a total failure when no items succeeded", func() {
+			summary.Failed("some-package", errors.New("not found"))
+
+			err := summary.Err()
+			Expect(err).To(MatchError("Failed to add 1 of 1 packages"))
+			Expect(err).To(HaveField("Code", report.ExitTotalFailure))
+		})
+	})
+
+	Describe("Print", func() {
+		It("prints a table of each item and its outcome", func() {
+			summary.Succeeded("some-package")
+			summary.Failed("some-other-package", errors.New("not found\nmore details"))
+			summary.Skipped("pkg")
+
+			stdout, _ := termio_stub.CaptureTermOut(summary.Print)
+			Expect(stdout).To(Equal("\n" +
+				"  PACKAGE             DETAILS\n" +
+				s.SuccessIcon() + " some-package\n" +
+				s.FailureIcon() + " some-other-package  not found\n" +
+				s.WarningIcon() + " pkg                 not attempted\n"))
+		})
+
+		It("prints why a skipped item was not attempted", func() {
+			summary.Failed("docker-ce", errors.New("not found"))
+			summary.SkippedFor("docker-compose", "requires `docker-ce`, which failed")
+
+			stdout, _ := termio_stub.CaptureTermOut(summary.Print)
+			Expect(stdout).To(Equal("\n" +
+				"  PACKAGE         DETAILS\n" +
+				s.FailureIcon() + " docker-ce       not found\n" +
+				s.WarningIcon() + " docker-compose  not attempted, requires `docker-ce`, which failed\n"))
+		})
+	})
+})
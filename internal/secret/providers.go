@@ -0,0 +1,181 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/pkg/logging"
+	"github.com/drew-english/system-configurator/pkg/run"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EnvPrefix = "SCFG_SECRET_"
+
+	// Name of the age-encrypted secrets file in the configuration directory, a YAML mapping of name to value.
+	AgeFileName = "secrets.age"
+	// Name of the age identity used to decrypt it, in the configuration directory unless set by $SCFG_AGE_IDENTITY.
+	AgeIdentityFileName = "age-identity.txt"
+
+	passPrefix     = "system-configurator/"
+	keyringService = "system-configurator"
+)
+
+type (
+	// Env reads secrets from SCFG_SECRET_<NAME> environment variables.
+	Env struct{}
+
+	// AgeFile reads secrets from an age-encrypted YAML file, such as one committed alongside the configuration.
+	AgeFile struct {
+		Path     string
+		Identity string
+		secrets  map[string]string
+	}
+
+	// Pass reads secrets from the `pass` password store, under system-configurator/<name>.
+	Pass struct{}
+
+	// Keyring reads secrets from the OS keyring through `secret-tool`, stored with
+	// the attributes service=system-configurator and secret=<name>.
+	Keyring struct{}
+)
+
+// Default returns a resolver for the environment, the age-encrypted secrets file, pass and the OS keyring, in that order.
+func Default() (*Resolver, error) {
+	location, err := store.LocalDefaultLocation()
+	if err != nil {
+		return nil, err
+	}
+
+	identity := os.Getenv("SCFG_AGE_IDENTITY")
+	if identity == "" {
+		identity = filepath.Join(location, AgeIdentityFileName)
+	}
+
+	return NewResolver(
+		Env{},
+		&AgeFile{Path: filepath.Join(location, AgeFileName), Identity: identity},
+		Pass{},
+		Keyring{},
+	), nil
+}
+
+func (Env) Name() string {
+	return "env"
+}
+
+func (Env) Lookup(_ context.Context, name string) (string, bool, error) {
+	value, ok := os.LookupEnv(EnvVar(name))
+	return value, ok, nil
+}
+
+// EnvVar is the environment variable the env provider reads the named secret from.
+func EnvVar(name string) string {
+	return EnvPrefix + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+
+		return '_'
+	}, name)
+}
+
+func (*AgeFile) Name() string {
+	return "age"
+}
+
+func (a *AgeFile) Lookup(ctx context.Context, name string) (string, bool, error) {
+	if a.secrets == nil {
+		if err := a.decrypt(ctx); err != nil || a.secrets == nil {
+			return "", false, err
+		}
+	}
+
+	value, ok := a.secrets[name]
+	return value, ok, nil
+}
+
+// decrypt reads the secrets file, leaving secrets nil when the file does not exist.
+func (a *AgeFile) decrypt(ctx context.Context) error {
+	if _, err := os.Stat(a.Path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if !available("age") {
+		return fmt.Errorf("age is not installed, it is needed to decrypt `%s`", a.Path)
+	}
+
+	out, err := run.Command(ctx, "age", "--decrypt", "--identity", a.Identity, a.Path).Output()
+	if err != nil {
+		return err
+	}
+
+	secrets := make(map[string]string)
+	if err := yaml.Unmarshal(out, &secrets); err != nil {
+		return errors.New("decrypted secrets are not a mapping of name to value")
+	}
+
+	a.secrets = secrets
+	return nil
+}
+
+func (Pass) Name() string {
+	return "pass"
+}
+
+func (Pass) Lookup(ctx context.Context, name string) (string, bool, error) {
+	if !available("pass") {
+		return "", false, nil
+	}
+
+	out, err := run.Command(ctx, "pass", "show", passPrefix+name).Output()
+	var cmdErr run.CmdError
+	if errors.As(err, &cmdErr) && strings.Contains(cmdErr.Stderr.String(), "is not in the password store") {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+
+	// pass keeps the password on the first line, followed by optional metadata.
+	value, _, _ := strings.Cut(string(out), "\n")
+	return value, true, nil
+}
+
+func (Keyring) Name() string {
+	return "secret-tool"
+}
+
+func (Keyring) Lookup(ctx context.Context, name string) (string, bool, error) {
+	if !available("secret-tool") {
+		return "", false, nil
+	}
+
+	// secret-tool exits with status 1 and no output when the secret does not exist.
+	out, err := run.Command(ctx, "secret-tool", "lookup", "service", keyringService, "secret", name).Output()
+	var cmdErr run.CmdError
+	if errors.As(err, &cmdErr) && cmdErr.Stderr.Len() == 0 {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+
+	return strings.TrimSuffix(string(out), "\n"), true, nil
+}
+
+func available(binary string) bool {
+	if _, err := run.Find(binary); err != nil {
+		logging.Debug("secret provider is not available", "binary", binary)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,130 @@
+package secret_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/drew-english/system-configurator/internal/secret"
+	"github.com/drew-english/system-configurator/spec/stub/run"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Providers", func() {
+	var (
+		ctx              = context.Background()
+		commandStubs     *run.CommandStubManager
+		teardownCmdStubs func(testing.TB)
+	)
+
+	BeforeEach(func() {
+		commandStubs, teardownCmdStubs = run.StubCommand()
+		DeferCleanup(func() { teardownCmdStubs(GinkgoTB()) })
+	})
+
+	Describe("Env", func() {
+		It("reads SCFG_SECRET_<NAME>", func() {
+			GinkgoT().Setenv("SCFG_SECRET_GITHUB_TOKEN", "token")
+			Expect(secret.EnvVar("github.token")).To(Equal("SCFG_SECRET_GITHUB_TOKEN"))
+
+			value, ok, err := secret.Env{}.Lookup(ctx, "github_token")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal("token"))
+		})
+	})
+
+	Describe("Pass", func() {
+		It("reads the first line of the entry", func() {
+			DeferCleanup(run.StubFind("pass", nil))
+			commandStubs.Register("pass show system-configurator/github_token", "token\nuser: someone\n")
+
+			value, ok, err := secret.Pass{}.Lookup(ctx, "github_token")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal("token"))
+		})
+
+		It("does not have entries missing from the store", func() {
+			DeferCleanup(run.StubFind("pass", nil))
+			commandStubs.RegisterError("pass show system-configurator/missing", 1, "Error: system-configurator/missing is not in the password store.\n")
+
+			_, ok, err := secret.Pass{}.Lookup(ctx, "missing")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		It("is skipped when pass is not installed", func() {
+			DeferCleanup(run.StubFind("pass", errors.New("not found")))
+
+			_, ok, err := secret.Pass{}.Lookup(ctx, "github_token")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("Keyring", func() {
+		It("looks up the secret with secret-tool", func() {
+			DeferCleanup(run.StubFind("secret-tool", nil))
+			commandStubs.Register("secret-tool lookup service system-configurator secret github_token", "token")
+
+			value, ok, err := secret.Keyring{}.Lookup(ctx, "github_token")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal("token"))
+		})
+
+		It("does not have secrets missing from the keyring", func() {
+			DeferCleanup(run.StubFind("secret-tool", nil))
+			commandStubs.RegisterError("secret-tool lookup", 1, "")
+
+			_, ok, err := secret.Keyring{}.Lookup(ctx, "missing")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("AgeFile", func() {
+		var provider *secret.AgeFile
+
+		BeforeEach(func() {
+			dir := GinkgoT().TempDir()
+			provider = &secret.AgeFile{Path: filepath.Join(dir, "secrets.age"), Identity: filepath.Join(dir, "age-identity.txt")}
+		})
+
+		It("decrypts the file once and reads secrets from it", func() {
+			Expect(os.WriteFile(provider.Path, []byte("encrypted"), 0644)).To(Succeed())
+			DeferCleanup(run.StubFind("age", nil))
+			commandStubs.Register("age --decrypt --identity .*age-identity.txt .*secrets.age", "github_token: token\nnpm_token: other\n")
+
+			value, ok, err := provider.Lookup(ctx, "github_token")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal("token"))
+
+			value, ok, err = provider.Lookup(ctx, "npm_token")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal("other"))
+		})
+
+		It("returns an error when the file exists but age is not installed", func() {
+			Expect(os.WriteFile(provider.Path, []byte("encrypted"), 0644)).To(Succeed())
+			DeferCleanup(run.StubFind("age", errors.New("not found")))
+
+			_, ok, err := provider.Lookup(ctx, "github_token")
+			Expect(err).To(MatchError("age is not installed, it is needed to decrypt `" + provider.Path + "`"))
+			Expect(ok).To(BeFalse())
+		})
+
+		It("does not have secrets when the file does not exist", func() {
+			_, ok, err := provider.Lookup(ctx, "github_token")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
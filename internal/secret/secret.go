@@ -0,0 +1,104 @@
+// Resolves secrets such as API tokens from local backends, so they can be referenced from
+// the configuration as `{{ secret "name" }}` without being stored in a shared configuration.
+// Resolved values are redacted from logs, command output and command errors.
+package secret
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/drew-english/system-configurator/pkg/logging"
+)
+
+type (
+	// Provider looks up secrets in one backend.
+	Provider interface {
+		Name() string
+		// Lookup returns the named secret, which is false when the backend does not have it or is unavailable.
+		Lookup(ctx context.Context, name string) (string, bool, error)
+	}
+
+	// Resolver looks up secrets in each provider in order, using the first that has it.
+	Resolver struct {
+		Providers []Provider
+		resolved  map[string]resolved
+	}
+
+	resolved struct {
+		value    string
+		provider string
+	}
+)
+
+var nameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_./-]*$`)
+
+func NewResolver(providers ...Provider) *Resolver {
+	return &Resolver{Providers: providers, resolved: make(map[string]resolved)}
+}
+
+// Resolve returns the named secret from the first provider that has it.
+func (r *Resolver) Resolve(ctx context.Context, name string) (string, error) {
+	value, _, err := r.lookup(ctx, name)
+	return value, err
+}
+
+// Source returns the name of the provider the named secret is resolved from, without revealing it.
+func (r *Resolver) Source(ctx context.Context, name string) (string, error) {
+	_, provider, err := r.lookup(ctx, name)
+	return provider, err
+}
+
+func (r *Resolver) lookup(ctx context.Context, name string) (string, string, error) {
+	if !nameRegex.MatchString(name) || strings.Contains(name, "..") {
+		return "", "", fmt.Errorf("invalid secret name `%s`", name)
+	}
+
+	if s, ok := r.resolved[name]; ok {
+		return s.value, s.provider, nil
+	}
+
+	names := make([]string, 0, len(r.Providers))
+	for _, provider := range r.Providers {
+		names = append(names, provider.Name())
+		value, ok, err := provider.Lookup(ctx, name)
+		if err != nil {
+			return "", "", fmt.Errorf("unable to look up secret `%s` in %s: %w", name, provider.Name(), err)
+		}
+
+		if ok {
+			logging.Redact(value)
+			logging.Debug("resolved secret", "name", name, "provider", provider.Name())
+			r.resolved[name] = resolved{value: value, provider: provider.Name()}
+			return value, provider.Name(), nil
+		}
+	}
+
+	return "", "", fmt.Errorf("secret `%s` was not found in %s", name, strings.Join(names, ", "))
+}
+
+// FuncMap provides the `secret` template function.
+func (r *Resolver) FuncMap(ctx context.Context) template.FuncMap {
+	return template.FuncMap{
+		"secret": func(name string) (string, error) {
+			return r.Resolve(ctx, name)
+		},
+	}
+}
+
+// Render executes text as a template in which `{{ secret "name" }}` is replaced by the named secret.
+func (r *Resolver) Render(ctx context.Context, name, text string) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(r.FuncMap(ctx)).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	buf := &strings.Builder{}
+	if err := tmpl.Execute(buf, nil); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,90 @@
+package secret_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/drew-english/system-configurator/internal/secret"
+	"github.com/drew-english/system-configurator/pkg/logging"
+	termio_stub "github.com/drew-english/system-configurator/spec/stub/termio"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type fakeProvider struct {
+	name    string
+	secrets map[string]string
+	err     error
+	lookups int
+}
+
+func (p *fakeProvider) Name() string {
+	return p.name
+}
+
+func (p *fakeProvider) Lookup(_ context.Context, name string) (string, bool, error) {
+	p.lookups++
+	value, ok := p.secrets[name]
+	return value, ok, p.err
+}
+
+var _ = Describe("Resolver", func() {
+	var (
+		ctx           = context.Background()
+		first, second *fakeProvider
+		resolver      *secret.Resolver
+	)
+
+	BeforeEach(func() {
+		first = &fakeProvider{name: "first", secrets: map[string]string{"shared": "from-first"}}
+		second = &fakeProvider{name: "second", secrets: map[string]string{"shared": "from-second", "github_token": "ghp_0123456789"}}
+		resolver = secret.NewResolver(first, second)
+	})
+
+	It("resolves a secret from the first provider that has it", func() {
+		Expect(resolver.Resolve(ctx, "shared")).To(Equal("from-first"))
+		Expect(resolver.Resolve(ctx, "github_token")).To(Equal("ghp_0123456789"))
+		Expect(resolver.Source(ctx, "github_token")).To(Equal("second"))
+	})
+
+	It("looks up each secret once", func() {
+		resolver.Resolve(ctx, "github_token")
+		resolver.Resolve(ctx, "github_token")
+		Expect(second.lookups).To(Equal(1))
+	})
+
+	It("returns an error listing the providers when no provider has the secret", func() {
+		_, err := resolver.Resolve(ctx, "missing")
+		Expect(err).To(MatchError("secret `missing` was not found in first, second"))
+	})
+
+	It("returns an error when a provider fails", func() {
+		first.err = errors.New("locked")
+		_, err := resolver.Resolve(ctx, "shared")
+		Expect(err).To(MatchError("unable to look up secret `shared` in first: locked"))
+	})
+
+	It("refuses invalid names", func() {
+		_, err := resolver.Resolve(ctx, "../etc/passwd")
+		Expect(err).To(MatchError("invalid secret name `../etc/passwd`"))
+	})
+
+	It("renders templates referencing secrets", func() {
+		Expect(resolver.Render(ctx, "netrc", `password {{ secret "github_token" }}`)).To(Equal("password ghp_0123456789"))
+
+		_, err := resolver.Render(ctx, "netrc", `{{ secret "missing" }}`)
+		Expect(err).To(MatchError(ContainSubstring("secret `missing` was not found")))
+	})
+
+	It("redacts resolved secrets from logs", func() {
+		Expect(resolver.Resolve(ctx, "github_token")).To(Equal("ghp_0123456789"))
+
+		_, stderr := termio_stub.CaptureTermOut(func() {
+			logging.Warn("running command", "args", []string{"curl", "-H", "Authorization: token ghp_0123456789"})
+		})
+
+		Expect(stderr).ToNot(ContainSubstring("ghp_0123456789"))
+		Expect(stderr).To(ContainSubstring(logging.RedactedText))
+	})
+})
@@ -0,0 +1,200 @@
+// Detached Ed25519 signatures in the minisign format, so configurations signed by scfg can also be
+// verified with `minisign -V`, and keys created by minisign can be trusted by scfg.
+package signature
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Extension of a signature file, which is kept next to the file it signs.
+const Extension = ".minisig"
+
+const (
+	algorithm       = "Ed"
+	prehashed       = "ED"
+	idSize          = 8
+	commentPrefix   = "untrusted comment: "
+	trustedPrefix   = "trusted comment: "
+	publicKeySize   = len(algorithm) + idSize + ed25519.PublicKeySize
+	privateKeySize  = len(algorithm) + idSize + ed25519.SeedSize
+	signatureSize   = len(algorithm) + idSize + ed25519.SignatureSize
+	secretKeyHeader = "scfg secret key"
+)
+
+var (
+	ErrUntrustedKey     = errors.New("signed by a key that is not trusted")
+	ErrInvalidSignature = errors.New("signature does not match")
+)
+
+type (
+	PublicKey struct {
+		ID  [idSize]byte
+		Key ed25519.PublicKey
+	}
+
+	PrivateKey struct {
+		ID  [idSize]byte
+		Key ed25519.PrivateKey
+	}
+)
+
+// GenerateKey creates a new signing key.
+func GenerateKey() (*PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	priv := &PrivateKey{Key: key}
+	if _, err := rand.Read(priv.ID[:]); err != nil {
+		return nil, err
+	}
+
+	return priv, nil
+}
+
+// Public returns the public key to verify signatures made by the key.
+func (k *PrivateKey) Public() *PublicKey {
+	return &PublicKey{ID: k.ID, Key: k.Key.Public().(ed25519.PublicKey)}
+}
+
+// Marshal encodes the key, unencrypted, in a format specific to scfg.
+func (k *PrivateKey) Marshal() []byte {
+	data := append(append([]byte(algorithm), k.ID[:]...), k.Key.Seed()...)
+	return encode(fmt.Sprintf("%s %s", secretKeyHeader, formatID(k.ID)), data)
+}
+
+// ParsePrivateKey decodes a key encoded by Marshal.
+func ParsePrivateKey(text []byte) (*PrivateKey, error) {
+	lines := splitLines(text)
+	if len(lines) < 2 || !strings.HasPrefix(lines[0], commentPrefix+secretKeyHeader) {
+		return nil, errors.New("invalid secret key, expected a key created by `scfg config sign --generate-key`")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(data) != privateKeySize || string(data[:2]) != algorithm {
+		return nil, errors.New("invalid secret key")
+	}
+
+	priv := &PrivateKey{Key: ed25519.NewKeyFromSeed(data[2+idSize:])}
+	copy(priv.ID[:], data[2:2+idSize])
+	return priv, nil
+}
+
+// String is the hexadecimal key ID, as minisign displays it.
+func (k *PublicKey) String() string {
+	return formatID(k.ID)
+}
+
+// Marshal encodes the key as a minisign public key file.
+func (k *PublicKey) Marshal() []byte {
+	data := append(append([]byte(algorithm), k.ID[:]...), k.Key...)
+	return encode("minisign public key "+formatID(k.ID), data)
+}
+
+// ParsePublicKeys decodes every public key in text, which holds minisign public key files or their
+// base64 lines. Empty lines, comments and lines starting with `#` are ignored.
+func ParsePublicKeys(text []byte) ([]*PublicKey, error) {
+	var keys []*PublicKey
+	for i, line := range splitLines(text) {
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, commentPrefix) {
+			continue
+		}
+
+		data, err := base64.StdEncoding.DecodeString(line)
+		if err != nil || len(data) != publicKeySize || string(data[:2]) != algorithm {
+			return nil, fmt.Errorf("invalid public key on line %d", i+1)
+		}
+
+		key := &PublicKey{Key: ed25519.PublicKey(data[2+idSize:])}
+		copy(key.ID[:], data[2:2+idSize])
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// Sign creates a detached signature of data, recording the file name and time in its trusted comment.
+func Sign(key *PrivateKey, data []byte, fileName string) []byte {
+	sig := ed25519.Sign(key.Key, data)
+	trustedComment := fmt.Sprintf("timestamp:%d\tfile:%s", time.Now().Unix(), fileName)
+	globalSig := ed25519.Sign(key.Key, append(append([]byte{}, sig...), trustedComment...))
+
+	buf := &bytes.Buffer{}
+	buf.Write(encode("signature from scfg secret key", append(append([]byte(algorithm), key.ID[:]...), sig...)))
+	fmt.Fprintf(buf, "%s%s\n%s\n", trustedPrefix, trustedComment, base64.StdEncoding.EncodeToString(globalSig))
+	return buf.Bytes()
+}
+
+// Verify checks a detached signature of data against the trusted keys, returning the key that made it.
+func Verify(trusted []*PublicKey, data, signature []byte) (*PublicKey, error) {
+	lines := splitLines(signature)
+	if len(lines) < 4 || !strings.HasPrefix(lines[0], commentPrefix) || !strings.HasPrefix(lines[2], trustedPrefix) {
+		return nil, errors.New("invalid signature file")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sig) != signatureSize {
+		return nil, errors.New("invalid signature file")
+	}
+
+	if string(sig[:2]) == prehashed {
+		return nil, errors.New("prehashed signatures are not supported, sign with `minisign -S -l`")
+	} else if string(sig[:2]) != algorithm {
+		return nil, fmt.Errorf("unsupported signature algorithm `%s`", sig[:2])
+	}
+
+	var key *PublicKey
+	for _, k := range trusted {
+		if bytes.Equal(k.ID[:], sig[2:2+idSize]) {
+			key = k
+			break
+		}
+	}
+
+	if key == nil {
+		var id [idSize]byte
+		copy(id[:], sig[2:2+idSize])
+		return nil, fmt.Errorf("%w: %s", ErrUntrustedKey, formatID(id))
+	}
+
+	if !ed25519.Verify(key.Key, data, sig[2+idSize:]) {
+		return nil, ErrInvalidSignature
+	}
+
+	globalSig, err := base64.StdEncoding.DecodeString(lines[3])
+	trustedComment := strings.TrimPrefix(lines[2], trustedPrefix)
+	signed := append(append([]byte{}, sig[2+idSize:]...), trustedComment...)
+	if err != nil || !ed25519.Verify(key.Key, signed, globalSig) {
+		return nil, fmt.Errorf("%w: the trusted comment was modified", ErrInvalidSignature)
+	}
+
+	return key, nil
+}
+
+func encode(comment string, data []byte) []byte {
+	return []byte(fmt.Sprintf("%s%s\n%s\n", commentPrefix, comment, base64.StdEncoding.EncodeToString(data)))
+}
+
+func formatID(id [idSize]byte) string {
+	return fmt.Sprintf("%016X", binary.LittleEndian.Uint64(id[:]))
+}
+
+func splitLines(text []byte) []string {
+	var lines []string
+	s := bufio.NewScanner(bytes.NewReader(text))
+	for s.Scan() {
+		lines = append(lines, strings.TrimSpace(s.Text()))
+	}
+
+	return lines
+}
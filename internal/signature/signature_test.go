@@ -0,0 +1,87 @@
+package signature_test
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/drew-english/system-configurator/internal/signature"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Signature", func() {
+	var (
+		key  *signature.PrivateKey
+		data = []byte("packages:\n  - fzf\n")
+	)
+
+	BeforeEach(func() {
+		var err error
+		key, err = signature.GenerateKey()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("verifies a signature made by a trusted key", func() {
+		sig := signature.Sign(key, data, "config.yml")
+		Expect(string(sig)).To(MatchRegexp("^untrusted comment: .*\n.*\ntrusted comment: timestamp:\\d+\tfile:config.yml\n.*\n$"))
+
+		signer, err := signature.Verify([]*signature.PublicKey{key.Public()}, data, sig)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(signer.String()).To(Equal(key.Public().String()))
+	})
+
+	It("refuses modified data", func() {
+		sig := signature.Sign(key, data, "config.yml")
+		_, err := signature.Verify([]*signature.PublicKey{key.Public()}, append(data, "  - jq\n"...), sig)
+		Expect(err).To(MatchError(signature.ErrInvalidSignature))
+	})
+
+	It("refuses a modified trusted comment", func() {
+		sig := bytes.Replace(signature.Sign(key, data, "config.yml"), []byte("file:config.yml"), []byte("file:other.yml"), 1)
+		_, err := signature.Verify([]*signature.PublicKey{key.Public()}, data, sig)
+		Expect(err).To(MatchError("signature does not match: the trusted comment was modified"))
+	})
+
+	It("refuses a signature from a key that is not trusted", func() {
+		other, err := signature.GenerateKey()
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = signature.Verify([]*signature.PublicKey{other.Public()}, data, signature.Sign(key, data, "config.yml"))
+		Expect(err).To(MatchError(signature.ErrUntrustedKey))
+		Expect(err).To(MatchError(HaveSuffix(key.Public().String())))
+	})
+
+	It("refuses a malformed signature", func() {
+		_, err := signature.Verify([]*signature.PublicKey{key.Public()}, data, []byte("not a signature"))
+		Expect(err).To(MatchError("invalid signature file"))
+	})
+
+	It("round trips keys", func() {
+		parsed, err := signature.ParsePrivateKey(key.Marshal())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(parsed).To(Equal(key))
+
+		public := key.Public().Marshal()
+		Expect(string(public)).To(HavePrefix("untrusted comment: minisign public key " + key.Public().String() + "\n"))
+
+		keys, err := signature.ParsePublicKeys(public)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(keys).To(Equal([]*signature.PublicKey{key.Public()}))
+	})
+
+	It("parses a list of public keys with comments", func() {
+		other, err := signature.GenerateKey()
+		Expect(err).ToNot(HaveOccurred())
+
+		list := "# team keys\n\n" + string(key.Public().Marshal()) + strings.Split(string(other.Public().Marshal()), "\n")[1] + "\n"
+		keys, err := signature.ParsePublicKeys([]byte(list))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(keys).To(Equal([]*signature.PublicKey{key.Public(), other.Public()}))
+	})
+
+	It("refuses an invalid public key", func() {
+		_, err := signature.ParsePublicKeys([]byte("# key\nnot-base64\n"))
+		Expect(err).To(MatchError("invalid public key on line 2"))
+	})
+})
@@ -4,11 +4,56 @@ import (
 	"errors"
 
 	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/pkg/logging"
 	"github.com/drew-english/system-configurator/pkg/sys/pkgmanager"
+	"gopkg.in/yaml.v3"
 )
 
 type Configuration struct {
-	Packages []*model.Package `yaml:"packages"`
+	Version      int                 `yaml:"version,omitempty"`
+	Include      []string            `yaml:"include,omitempty"`      // other files to merge into the configuration
+	Personal     string              `yaml:"personal,omitempty"`     // fragment new packages are written to
+	Repositories []*model.Repository `yaml:"repositories,omitempty"` // third-party repositories packages are installed from
+	Packages     []*model.Package    `yaml:"packages"`
+
+	sources *sources
+}
+
+// decodeConfiguration parses a configuration document, migrating it to CurrentVersion and validating it.
+// Problems that do not prevent it from loading are logged as warnings for source.
+func decodeConfiguration(data []byte, source string) (*Configuration, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return nil, err
+	}
+
+	from, err := MigrateDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if from != CurrentVersion {
+		logging.Debug("migrated configuration", "from", from, "to", CurrentVersion)
+	}
+
+	problems := validateDocument(doc)
+	if fatal := problems.Fatal(); len(fatal) > 0 {
+		return nil, fatal
+	}
+
+	for _, problem := range problems {
+		logging.Warn(problem.Message, "source", source, "line", problem.Line, "column", problem.Column)
+	}
+
+	cfg := &Configuration{}
+	if len(doc.Content) > 0 {
+		if err := doc.Decode(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg.Version = CurrentVersion
+	return cfg, nil
 }
 
 func (c *Configuration) ResolvedPkgs() ([]*model.Package, error) {
@@ -60,3 +105,35 @@ func (c *Configuration) FindPackage(name string) (*model.Package, int) {
 
 	return nil, -1
 }
+
+// FindRepository returns the repository with the given name, which is nil when it is not configured.
+func (c *Configuration) FindRepository(name string) *model.Repository {
+	for _, repo := range c.Repositories {
+		if repo.Name == name {
+			return repo
+		}
+	}
+
+	return nil
+}
+
+// AddRepository adds a repository, which must not already be configured.
+func (c *Configuration) AddRepository(repo *model.Repository) error {
+	if c.FindRepository(repo.Name) != nil {
+		return errors.New("repository already exists in configuration")
+	}
+
+	c.Repositories = append(c.Repositories, repo)
+	return nil
+}
+
+func (c *Configuration) RemoveRepository(name string) error {
+	for i, repo := range c.Repositories {
+		if repo.Name == name {
+			c.Repositories = append(c.Repositories[:i], c.Repositories[i+1:]...)
+			return nil
+		}
+	}
+
+	return errors.New("repository does not exist in configuration")
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+const appDirName = "system-configurator"
+
+var ErrNoHomeDir = errors.New("unable to determine the home directory, set the $HOME environment variable")
+
+// Location of the user configuration, $XDG_CONFIG_HOME/system-configurator.
+var LocalDefaultLocation = func() (string, error) {
+	return xdgLocation("XDG_CONFIG_HOME", ".config")
+}
+
+// Location for state that is specific to this machine, such as the history and journals of applied changes,
+// $XDG_STATE_HOME/system-configurator.
+var StateDefaultLocation = func() (string, error) {
+	return xdgLocation("XDG_STATE_HOME", ".local/state")
+}
+
+// Location for data that can be recreated, such as downloaded configurations, $XDG_CACHE_HOME/system-configurator.
+var CacheDefaultLocation = func() (string, error) {
+	return xdgLocation("XDG_CACHE_HOME", ".cache")
+}
+
+// xdgLocation returns the scfg directory within the base directory named by env, or within the
+// default under the home directory when it is unset. Relative paths are ignored, as the XDG Base
+// Directory Specification requires.
+func xdgLocation(env, homeDefault string) (string, error) {
+	if base := os.Getenv(env); base != "" && filepath.IsAbs(base) {
+		return filepath.Join(base, appDirName), nil
+	}
+
+	home, err := resolveHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, homeDefault, appDirName), nil
+}
+
+func resolveHomeDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return "", ErrNoHomeDir
+	}
+
+	return home, nil
+}
@@ -0,0 +1,489 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/internal/signature"
+	"github.com/drew-english/system-configurator/pkg/logging"
+	"gopkg.in/yaml.v3"
+)
+
+const FragmentDirName = "config.d"
+
+type (
+	// fragment is one of the files a configuration is loaded from.
+	fragment struct {
+		layer    string
+		path     string
+		signed   bool // whether the file has a valid signature from a trusted key
+		cfg      *Configuration
+		snapshot []byte // encoding of cfg when it was last read or written, to skip unchanged files
+	}
+
+	// sources records the file each package of a merged configuration came from,
+	// so that changes are written back to the same file.
+	sources struct {
+		main     *fragment
+		personal string
+		files    []*fragment
+		owners   map[string]*fragment
+		seen     map[string]bool
+	}
+
+	// Source is the file a package of the configuration was loaded from.
+	Source struct {
+		Layer string
+		Path  string
+	}
+
+	// ReadOnlyError is returned when a change would modify a file of a layer that is not written,
+	// such as the system or project configuration of a layered store.
+	ReadOnlyError struct {
+		Source
+		Names []string // packages and repositories that would change
+	}
+)
+
+// loadFragments merges the files included by the main configuration into it, see sources.load.
+func loadFragments(layer, mainPath string, mainData []byte) (*Configuration, error) {
+	merged := &Configuration{}
+	src := newSources()
+	if err := src.load(merged, layer, mainPath, mainData, true); err != nil {
+		return nil, err
+	}
+
+	return src.finish(merged), nil
+}
+
+func newSources() *sources {
+	return &sources{owners: make(map[string]*fragment), seen: make(map[string]bool)}
+}
+
+// load merges the main configuration of a layer and the files it includes into merged. Files are merged in order:
+// the main configuration, each `include` in the order listed, `config.d/*.yml` sorted by name, then the
+// personal fragment. A package or repository defined in more than one file takes its definition from the last one.
+// New packages are written to the personal fragment or main configuration of the primary layer.
+func (s *sources) load(merged *Configuration, layer, mainPath string, mainData []byte, primary bool) error {
+	mainFragment, err := loadFragment(layer, mainPath, mainData)
+	if err != nil {
+		return err
+	}
+
+	main := mainFragment.cfg
+
+	var personal string
+	if main.Personal != "" {
+		if personal, err = resolvePath(filepath.Dir(mainPath), main.Personal); err != nil {
+			return err
+		}
+	}
+
+	if primary {
+		merged.Version, merged.Include, merged.Personal = main.Version, main.Include, main.Personal
+		s.main, s.personal = mainFragment, personal
+	}
+
+	s.seen[filepath.Clean(mainPath)] = true
+	s.add(merged, mainFragment)
+
+	paths, err := s.fragmentPaths(mainPath, main, personal)
+	if err != nil {
+		return err
+	}
+
+	for _, fragmentPath := range paths {
+		data, err := os.ReadFile(fragmentPath)
+		if errors.Is(err, fs.ErrNotExist) && fragmentPath == personal {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		logging.Debug("loading configuration fragment", "path", fragmentPath)
+		f, err := loadFragment(layer, fragmentPath, data)
+		if err != nil {
+			return err
+		}
+
+		if len(f.cfg.Include) > 0 || f.cfg.Personal != "" {
+			return fmt.Errorf("invalid configuration `%s`: `include` and `personal` are only allowed in the main configuration", fragmentPath)
+		}
+
+		s.add(merged, f)
+	}
+
+	return nil
+}
+
+// finish sorts the packages of merged and attaches the sources to it.
+func (s *sources) finish(merged *Configuration) *Configuration {
+	slices.SortStableFunc(merged.Packages, func(a, b *model.Package) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	merged.sources = s
+	return merged
+}
+
+// SourceOf returns the file the named package was loaded from, which is false when it was not loaded from a file.
+func (c *Configuration) SourceOf(name string) (Source, bool) {
+	if c.sources == nil {
+		return Source{}, false
+	}
+
+	f, ok := c.sources.owners[name]
+	if !ok {
+		return Source{}, false
+	}
+
+	return Source{Layer: f.layer, Path: f.path}, true
+}
+
+// fragmentPaths returns the files to merge into the main configuration, in order and skipping files already loaded.
+func (s *sources) fragmentPaths(mainPath string, main *Configuration, personal string) ([]string, error) {
+	dir := filepath.Dir(mainPath)
+
+	var paths []string
+	for _, include := range main.Include {
+		pattern, err := resolvePath(dir, include)
+		if err != nil {
+			return nil, err
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include `%s`: %w", include, err)
+		}
+
+		if len(matches) == 0 && !strings.ContainsAny(include, `*?[\`) {
+			return nil, fmt.Errorf("included file `%s` does not exist", pattern)
+		}
+
+		slices.Sort(matches)
+		paths = append(paths, matches...)
+	}
+
+	var fragments []string
+	for _, ext := range []string{"*.yml", "*.yaml"} {
+		matches, _ := filepath.Glob(filepath.Join(dir, fragmentDirName(mainPath), ext))
+		fragments = append(fragments, matches...)
+	}
+
+	slices.Sort(fragments)
+	paths = append(paths, fragments...)
+
+	if personal != "" {
+		paths = append(paths, personal)
+	}
+
+	unique := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if p = filepath.Clean(p); !s.seen[p] {
+			s.seen[p] = true
+			unique = append(unique, p)
+		}
+	}
+
+	return unique, nil
+}
+
+// fragmentDirName is the directory of fragments next to a main configuration. A project configuration uses
+// a hidden directory, so that it does not pick up unrelated files in the repository.
+func fragmentDirName(mainPath string) string {
+	if filepath.Base(mainPath) == ProjectFileName {
+		return ProjectFragmentDirName
+	}
+
+	return FragmentDirName
+}
+
+func (s *sources) add(merged *Configuration, f *fragment) {
+	s.files = append(s.files, f)
+	for _, repo := range f.cfg.Repositories {
+		if i := slices.IndexFunc(merged.Repositories, func(r *model.Repository) bool { return r.Name == repo.Name }); i != -1 {
+			logging.Debug("repository overridden by fragment", "repository", repo.Name, "by", f.path)
+			merged.Repositories[i] = repo
+		} else {
+			merged.Repositories = append(merged.Repositories, repo)
+		}
+	}
+
+	for _, pkg := range f.cfg.Packages {
+		if owner, ok := s.owners[pkg.Name]; ok {
+			logging.Debug("package overridden by fragment", "package", pkg.Name, "from", owner.path, "by", f.path)
+			_, i := merged.FindPackage(pkg.Name)
+			merged.Packages[i] = pkg
+		} else {
+			merged.Packages = append(merged.Packages, pkg)
+		}
+
+		s.owners[pkg.Name] = f
+	}
+}
+
+// write splits cfg back into the files it was loaded from, writing only the files that changed.
+// Packages that were not loaded from any file are written to the personal fragment, or the main configuration,
+// and repositories that were not loaded from any file are written to the main configuration.
+// Only the files of the primary layer are written, a change to a file of another layer is refused with a
+// ReadOnlyError before anything is written.
+func (s *sources) write(cfg *Configuration) error {
+	current := make(map[string]*model.Package, len(cfg.Packages))
+	for _, pkg := range cfg.Packages {
+		current[pkg.Name] = pkg
+		if _, ok := s.owners[pkg.Name]; !ok {
+			s.owners[pkg.Name] = s.newPackageFragment()
+		}
+	}
+
+	loadedRepositories := make(map[string]bool)
+	for _, f := range s.files {
+		for _, repo := range f.cfg.Repositories {
+			loadedRepositories[repo.Name] = true
+		}
+	}
+
+	updates := make([]*Configuration, len(s.files))
+	for i, f := range s.files {
+		updated := &Configuration{Version: f.cfg.Version, Include: f.cfg.Include, Personal: f.cfg.Personal}
+		var removedRepositories []string
+		for _, repo := range f.cfg.Repositories {
+			if cfg.FindRepository(repo.Name) == nil {
+				removedRepositories = append(removedRepositories, repo.Name)
+				continue
+			}
+
+			updated.Repositories = append(updated.Repositories, repo)
+		}
+
+		if f == s.main {
+			for _, repo := range cfg.Repositories {
+				if !loadedRepositories[repo.Name] {
+					updated.Repositories = append(updated.Repositories, repo)
+				}
+			}
+		}
+
+		written := make(map[string]bool)
+		for _, pkg := range f.cfg.Packages {
+			if current[pkg.Name] == nil {
+				continue
+			}
+
+			if s.owners[pkg.Name] == f {
+				pkg = current[pkg.Name]
+			}
+
+			updated.Packages = append(updated.Packages, pkg)
+			written[pkg.Name] = true
+		}
+
+		for _, pkg := range cfg.Packages {
+			if s.owners[pkg.Name] == f && !written[pkg.Name] {
+				updated.AddPackage(pkg)
+			}
+		}
+
+		if f.layer != s.main.layer {
+			changed, err := f.changedPackages(updated)
+			if err != nil {
+				return err
+			}
+
+			changed = append(changed, removedRepositories...)
+			if len(changed) > 0 {
+				return &ReadOnlyError{Source: Source{Layer: f.layer, Path: f.path}, Names: changed}
+			}
+		}
+
+		updates[i] = updated
+	}
+
+	for i, f := range s.files {
+		if err := f.write(updates[i]); err != nil {
+			return err
+		}
+	}
+
+	for name := range s.owners {
+		if current[name] == nil {
+			delete(s.owners, name)
+		}
+	}
+
+	return nil
+}
+
+// newPackageFragment returns the fragment new packages are written to, adding the personal fragment if it was not loaded.
+func (s *sources) newPackageFragment() *fragment {
+	if s.personal == "" {
+		return s.main
+	}
+
+	for _, f := range s.files {
+		if f.path == s.personal {
+			return f
+		}
+	}
+
+	f := &fragment{layer: s.main.layer, path: s.personal, cfg: &Configuration{Version: CurrentVersion}}
+	s.files = append(s.files, f)
+	return f
+}
+
+// loadFragment decodes a configuration file and verifies its signature, if it has one.
+func loadFragment(layer, path string, data []byte) (*fragment, error) {
+	cfg, err := decodeConfiguration(data, path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration `%s`:\n%w", path, err)
+	}
+
+	f, err := newFragment(layer, path, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	f.signed, err = verifyFile(path, data)
+	return f, err
+}
+
+func newFragment(layer, path string, cfg *Configuration) (*fragment, error) {
+	snapshot, err := encodeConfiguration(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fragment{layer: layer, path: path, cfg: cfg, snapshot: snapshot}, nil
+}
+
+func (f *fragment) write(cfg *Configuration) error {
+	data, err := encodeConfiguration(cfg)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(data, f.snapshot) {
+		return nil
+	}
+
+	if err := writeConfigurationFile(f.path, data); err != nil {
+		return err
+	}
+
+	if f.signed {
+		if err := os.Remove(f.path + signature.Extension); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		logging.Warn("removed the signature of a modified configuration, sign it again with `scfg config sign`", "path", f.path)
+		f.signed = false
+	}
+
+	f.cfg = cfg
+	f.snapshot = data
+	return nil
+}
+
+// changedPackages returns the names of the packages that differ between the file as it was last read or
+// written and cfg, including packages that were removed.
+func (f *fragment) changedPackages(cfg *Configuration) ([]string, error) {
+	before := &Configuration{}
+	if err := yaml.Unmarshal(f.snapshot, before); err != nil {
+		return nil, err
+	}
+
+	encoded := make(map[string][]byte, len(before.Packages))
+	for _, pkg := range before.Packages {
+		data, err := encodeYAML(pkg)
+		if err != nil {
+			return nil, err
+		}
+
+		encoded[pkg.Name] = data
+	}
+
+	var changed []string
+	for _, pkg := range cfg.Packages {
+		data, err := encodeYAML(pkg)
+		if err != nil {
+			return nil, err
+		}
+
+		if previous, ok := encoded[pkg.Name]; !ok || !bytes.Equal(previous, data) {
+			changed = append(changed, pkg.Name)
+		}
+
+		delete(encoded, pkg.Name)
+	}
+
+	for name := range encoded {
+		changed = append(changed, name)
+	}
+
+	slices.Sort(changed)
+	return changed, nil
+}
+
+func (e *ReadOnlyError) Error() string {
+	return fmt.Sprintf(
+		"unable to change `%s`, defined in the %s configuration `%s`, which is not written: edit it there instead",
+		strings.Join(e.Names, "`, `"),
+		e.Layer,
+		e.Path,
+	)
+}
+
+func encodeConfiguration(cfg *Configuration) ([]byte, error) {
+	return encodeYAML(cfg)
+}
+
+func encodeYAML(v any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeConfigurationFile replaces the contents of path, keeping its permissions when it exists.
+func writeConfigurationFile(path string, data []byte) error {
+	logging.Debug("writing configuration", "path", path)
+	perm := fs.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, perm)
+}
+
+// resolvePath resolves p relative to dir, expanding a leading `~/` to the home directory.
+func resolvePath(dir, p string) (string, error) {
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		home, err := resolveHomeDir()
+		if err != nil {
+			return "", err
+		}
+
+		return filepath.Join(home, rest), nil
+	}
+
+	if filepath.IsAbs(p) {
+		return p, nil
+	}
+
+	return filepath.Join(dir, p), nil
+}
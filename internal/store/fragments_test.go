@@ -0,0 +1,174 @@
+package store_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/internal/store"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fragments", func() {
+	var (
+		dir        string
+		localStore store.Store
+	)
+
+	writeFile := func(name, content string) {
+		p := filepath.Join(dir, name)
+		Expect(os.MkdirAll(filepath.Dir(p), 0755)).To(Succeed())
+		Expect(os.WriteFile(p, []byte(content), 0644)).To(Succeed())
+	}
+
+	readFile := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		Expect(err).ToNot(HaveOccurred())
+		return string(data)
+	}
+
+	names := func(cfg *store.Configuration) []string {
+		var names []string
+		for _, pkg := range cfg.Packages {
+			names = append(names, pkg.String())
+		}
+
+		return names
+	}
+
+	load := func() *store.Configuration {
+		var err error
+		localStore, err = store.NewLocal(&store.LocalCfg{Location: dir})
+		Expect(err).ToNot(HaveOccurred())
+
+		cfg, err := localStore.LoadConfiguration()
+		Expect(err).ToNot(HaveOccurred())
+		return cfg
+	}
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		writeFile("config.yml", "version: 1\ninclude:\n  - team/base.yml\npackages:\n  - zoxide\n")
+		writeFile("team/base.yml", "packages:\n  - fzf\n  - ripgrep@13.0.0\n")
+		writeFile("config.d/20-work.yml", "packages:\n  - kubectl\n")
+		writeFile("config.d/10-tools.yaml", "packages:\n  - ripgrep@14.1.0\n")
+	})
+
+	It("merges includes and config.d fragments, with later files taking precedence", func() {
+		Expect(names(load())).To(Equal([]string{"fzf", "kubectl", "ripgrep@14.1.0", "zoxide"}))
+	})
+
+	It("writes changes back to the file each package came from", func() {
+		cfg := load()
+		Expect(cfg.RemovePackage("kubectl")).To(Succeed())
+		Expect(cfg.AddPackage(&model.Package{Name: "kubectl", Version: "1.30.0"})).To(Succeed())
+		Expect(cfg.RemovePackage("fzf")).To(Succeed())
+		Expect(localStore.WriteConfiguration(cfg)).To(Succeed())
+
+		Expect(readFile("config.d/20-work.yml")).To(Equal("version: 1\npackages:\n  - name: kubectl\n    version: 1.30.0\n"))
+		Expect(readFile("team/base.yml")).To(Equal("version: 1\npackages:\n  - ripgrep@13.0.0\n"))
+		Expect(readFile("config.yml")).To(Equal("version: 1\ninclude:\n  - team/base.yml\npackages:\n  - zoxide\n"))
+		Expect(readFile("config.d/10-tools.yaml")).To(Equal("packages:\n  - ripgrep@14.1.0\n"))
+	})
+
+	It("writes new packages to the main configuration", func() {
+		cfg := load()
+		Expect(cfg.AddPackage(&model.Package{Name: "bat"})).To(Succeed())
+		Expect(localStore.WriteConfiguration(cfg)).To(Succeed())
+
+		Expect(readFile("config.yml")).To(Equal("version: 1\ninclude:\n  - team/base.yml\npackages:\n  - name: bat\n  - zoxide\n"))
+		Expect(names(load())).To(ContainElement("bat"))
+	})
+
+	Context("when a personal fragment is designated", func() {
+		BeforeEach(func() {
+			writeFile("config.yml", "version: 1\npersonal: mine.yml\npackages:\n  - zoxide\n")
+		})
+
+		It("writes new packages to it", func() {
+			cfg := load()
+			Expect(cfg.AddPackage(&model.Package{Name: "bat"})).To(Succeed())
+			Expect(localStore.WriteConfiguration(cfg)).To(Succeed())
+
+			Expect(readFile("mine.yml")).To(Equal("version: 1\npackages:\n  - name: bat\n"))
+			Expect(readFile("config.yml")).To(Equal("version: 1\npersonal: mine.yml\npackages:\n  - zoxide\n"))
+			Expect(names(load())).To(ContainElement("bat"))
+		})
+	})
+
+	Context("when fragments define repositories", func() {
+		BeforeEach(func() {
+			writeFile("team/base.yml", "repositories:\n  - name: docker\n    requires: [gnupg]\n    apt: {url: https://example.com/old, suite: noble}\n  - name: hashicorp\n    brew: {tap: hashicorp/tap}\npackages:\n  - fzf\n")
+			writeFile("config.d/10-tools.yaml", "repositories:\n  - name: docker\n    apt: {url: https://download.docker.com/linux/ubuntu, suite: noble}\npackages:\n  - name: docker-ce\n    repository: docker\n")
+		})
+
+		It("merges them, with later files taking precedence", func() {
+			cfg := load()
+			Expect(cfg.Repositories).To(HaveLen(2))
+			Expect(cfg.FindRepository("docker").For("apt").URL).To(Equal("https://download.docker.com/linux/ubuntu"))
+			Expect(cfg.FindRepository("hashicorp").For("brew").Tap).To(Equal("hashicorp/tap"))
+			Expect(cfg.FindRepository("missing")).To(BeNil())
+		})
+
+		It("keeps the repositories of each file when writing", func() {
+			cfg := load()
+			Expect(cfg.RemovePackage("fzf")).To(Succeed())
+			Expect(localStore.WriteConfiguration(cfg)).To(Succeed())
+
+			Expect(readFile("team/base.yml")).To(Equal("version: 1\nrepositories:\n  - name: docker\n    requires:\n      - gnupg\n    apt:\n      url: https://example.com/old\n      suite: noble\n  - name: hashicorp\n    brew:\n      tap: hashicorp/tap\npackages: []\n"))
+		})
+
+		It("writes new repositories to the main configuration", func() {
+			cfg := load()
+			Expect(cfg.AddRepository(&model.Repository{Name: "homebrew-cask-fonts", Sources: map[string]*model.RepositorySource{"brew": {Tap: "homebrew/cask-fonts"}}})).To(Succeed())
+			Expect(localStore.WriteConfiguration(cfg)).To(Succeed())
+
+			Expect(readFile("config.yml")).To(Equal("version: 1\ninclude:\n  - team/base.yml\nrepositories:\n  - name: homebrew-cask-fonts\n    brew:\n      tap: homebrew/cask-fonts\npackages:\n  - zoxide\n"))
+			Expect(load().FindRepository("homebrew-cask-fonts")).ToNot(BeNil())
+		})
+
+		It("removes repositories from the file that defined them", func() {
+			cfg := load()
+			Expect(cfg.RemoveRepository("hashicorp")).To(Succeed())
+			Expect(localStore.WriteConfiguration(cfg)).To(Succeed())
+
+			Expect(readFile("team/base.yml")).To(Equal("version: 1\nrepositories:\n  - name: docker\n    requires:\n      - gnupg\n    apt:\n      url: https://example.com/old\n      suite: noble\npackages:\n  - fzf\n"))
+			Expect(load().FindRepository("hashicorp")).To(BeNil())
+		})
+	})
+
+	It("replaces the contents of a file that gets shorter", func() {
+		writeFile("config.yml", "version: 1\npackages:\n  - zoxide\n  - fzf\n  - a-package-with-a-long-name\n")
+		cfg := load()
+		Expect(cfg.RemovePackage("a-package-with-a-long-name")).To(Succeed())
+		Expect(localStore.WriteConfiguration(cfg)).To(Succeed())
+
+		Expect(readFile("config.yml")).To(Equal("version: 1\npackages:\n  - zoxide\n  - fzf\n"))
+	})
+
+	Context("when an included file does not exist", func() {
+		BeforeEach(func() {
+			writeFile("config.yml", "include:\n  - missing.yml\n")
+		})
+
+		It("returns an error", func() {
+			s, _ := store.NewLocal(&store.LocalCfg{Location: dir})
+			_, err := s.LoadConfiguration()
+			Expect(err).To(MatchError("included file `" + filepath.Join(dir, "missing.yml") + "` does not exist"))
+		})
+	})
+
+	Context("when a fragment includes other files", func() {
+		BeforeEach(func() {
+			writeFile("config.d/30-nested.yml", "include:\n  - other.yml\n")
+		})
+
+		It("returns an error", func() {
+			s, _ := store.NewLocal(&store.LocalCfg{Location: dir})
+			_, err := s.LoadConfiguration()
+			Expect(err).To(MatchError(ContainSubstring("`include` and `personal` are only allowed in the main configuration")))
+		})
+	})
+})
@@ -0,0 +1,188 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/drew-english/system-configurator/pkg/logging"
+)
+
+const (
+	LayerSystem   = "system"
+	LayerUser     = "user"
+	LayerProject  = "project"
+	LayerExplicit = "explicit"
+
+	ProjectFileName        = ".scfg.yml"
+	ProjectFragmentDirName = ".scfg.d"
+)
+
+type (
+	// Layer is one level of the configuration. Layers are merged in order, so a package defined
+	// in a later layer takes precedence over the same package in an earlier one.
+	Layer struct {
+		Name string
+		Path string
+	}
+
+	layeredStore struct {
+		layers []Layer
+		user   Store
+	}
+)
+
+// ConfigPath is an explicit configuration file to use instead of the layered configuration, set by `--config`.
+var ConfigPath string
+
+// Location of the system-wide configuration shared by every user of the machine.
+var SystemDefaultLocation = func() string {
+	return "/etc/system-configurator"
+}
+
+// Directory to search for a project configuration from, along with its parents up to the repository root.
+var ProjectSearchDir = os.Getwd
+
+// NewDefault returns the store commands use: the file or URL given by ConfigPath when set, or the layered configuration.
+func NewDefault() (Store, error) {
+	if IsRemote(ConfigPath) {
+		return NewRemote(&RemoteCfg{URL: ConfigPath, Checksum: ConfigChecksum, Context: RemoteContext})
+	}
+
+	if ConfigPath != "" {
+		if _, err := os.Stat(ConfigPath); err != nil {
+			return nil, fmt.Errorf("unable to use configuration `%s`: %w", ConfigPath, err)
+		}
+
+		return NewLocal(&LocalCfg{Location: filepath.Dir(ConfigPath), FileName: filepath.Base(ConfigPath), Layer: LayerExplicit})
+	}
+
+	layers, err := Layers()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLayered(layers)
+}
+
+// Layers returns the configuration layers in order of precedence, lowest first: the system configuration,
+// the user configuration, and the project configuration of the current repository. Only the user
+// configuration is required to exist, layers without a path were not found.
+// When ConfigPath is set, it is the only layer.
+func Layers() ([]Layer, error) {
+	if IsRemote(ConfigPath) {
+		return []Layer{{Name: LayerRemote, Path: ConfigPath}}, nil
+	}
+
+	if ConfigPath != "" {
+		return []Layer{{Name: LayerExplicit, Path: ConfigPath}}, nil
+	}
+
+	userLocation, err := LocalDefaultLocation()
+	if err != nil {
+		return nil, err
+	}
+
+	return []Layer{
+		{Name: LayerSystem, Path: filepath.Join(SystemDefaultLocation(), LocalDefaultFileName)},
+		{Name: LayerUser, Path: filepath.Join(userLocation, LocalDefaultFileName)},
+		{Name: LayerProject, Path: findProjectConfig()},
+	}, nil
+}
+
+// NewLayered returns a store merging the given layers, which writes new packages to the user layer.
+func NewLayered(layers []Layer) (Store, error) {
+	s := &layeredStore{layers: layers}
+	for _, layer := range layers {
+		if layer.Name != LayerUser {
+			continue
+		}
+
+		user, err := NewLocal(&LocalCfg{Location: filepath.Dir(layer.Path), FileName: filepath.Base(layer.Path), Layer: LayerUser})
+		if err != nil {
+			return nil, err
+		}
+
+		s.user = user
+	}
+
+	if s.user == nil {
+		return nil, errors.New("layered configuration requires a user layer")
+	}
+
+	return s, nil
+}
+
+func (ls *layeredStore) LoadConfiguration() (*Configuration, error) {
+	merged := &Configuration{}
+	src := newSources()
+	for _, layer := range ls.layers {
+		if layer.Path == "" {
+			continue
+		}
+
+		data, err := os.ReadFile(layer.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		logging.Debug("loading configuration layer", "layer", layer.Name, "path", layer.Path)
+		if err := src.load(merged, layer.Name, layer.Path, data, layer.Name == LayerUser); err != nil {
+			return nil, err
+		}
+	}
+
+	return src.finish(merged), nil
+}
+
+func (ls *layeredStore) WriteConfiguration(configData *Configuration) error {
+	return ls.user.WriteConfiguration(configData)
+}
+
+// findProjectConfig returns the project configuration in the search directory or one of its parents,
+// stopping at the root of the repository it is in. Outside of a repository only the search directory is checked.
+func findProjectConfig() string {
+	dir, err := ProjectSearchDir()
+	if err != nil {
+		logging.Debug("unable to determine the working directory", "error", err)
+		return ""
+	}
+
+	for {
+		candidate := filepath.Join(dir, ProjectFileName)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir || !insideRepository(parent) {
+			return ""
+		}
+
+		dir = parent
+	}
+}
+
+// insideRepository reports whether dir or one of its parents is the root of a git repository.
+func insideRepository(dir string) bool {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+
+		dir = parent
+	}
+}
@@ -0,0 +1,182 @@
+package store_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/internal/store"
+	store_stub "github.com/drew-english/system-configurator/spec/stub/store"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Layers", func() {
+	var systemDir, userDir, repoDir, workDir string
+
+	writeFile := func(p, content string) {
+		Expect(os.MkdirAll(filepath.Dir(p), 0755)).To(Succeed())
+		Expect(os.WriteFile(p, []byte(content), 0644)).To(Succeed())
+	}
+
+	readFile := func(p string) string {
+		data, err := os.ReadFile(p)
+		Expect(err).ToNot(HaveOccurred())
+		return string(data)
+	}
+
+	load := func() (store.Store, *store.Configuration) {
+		s, err := store.NewDefault()
+		Expect(err).ToNot(HaveOccurred())
+
+		cfg, err := s.LoadConfiguration()
+		Expect(err).ToNot(HaveOccurred())
+		return s, cfg
+	}
+
+	sourceOf := func(cfg *store.Configuration, name string) store.Source {
+		source, ok := cfg.SourceOf(name)
+		Expect(ok).To(BeTrue())
+		return source
+	}
+
+	BeforeEach(func() {
+		root := GinkgoT().TempDir()
+		systemDir = filepath.Join(root, "etc")
+		userDir = filepath.Join(root, "home")
+		repoDir = filepath.Join(root, "repo")
+		workDir = filepath.Join(repoDir, "src", "app")
+		Expect(os.MkdirAll(filepath.Join(repoDir, ".git"), 0755)).To(Succeed())
+		Expect(os.MkdirAll(workDir, 0755)).To(Succeed())
+
+		DeferCleanup(store_stub.StubLayers(systemDir, userDir, workDir))
+
+		writeFile(filepath.Join(systemDir, "config.yml"), "packages:\n  - curl\n  - fzf@0.40.0\n")
+		writeFile(filepath.Join(userDir, "config.yml"), "packages:\n  - fzf@0.50.0\n  - ripgrep\n")
+		writeFile(filepath.Join(repoDir, ".scfg.yml"), "packages:\n  - ripgrep@14.1.0\n  - go\n")
+	})
+
+	It("merges the system, user and project configuration with later layers taking precedence", func() {
+		_, cfg := load()
+
+		var names []string
+		for _, pkg := range cfg.Packages {
+			names = append(names, pkg.String())
+		}
+
+		Expect(names).To(Equal([]string{"curl", "fzf@0.50.0", "go", "ripgrep@14.1.0"}))
+		Expect(sourceOf(cfg, "curl")).To(Equal(store.Source{Layer: store.LayerSystem, Path: filepath.Join(systemDir, "config.yml")}))
+		Expect(sourceOf(cfg, "fzf")).To(Equal(store.Source{Layer: store.LayerUser, Path: filepath.Join(userDir, "config.yml")}))
+		Expect(sourceOf(cfg, "ripgrep")).To(Equal(store.Source{Layer: store.LayerProject, Path: filepath.Join(repoDir, ".scfg.yml")}))
+	})
+
+	It("only writes the user configuration", func() {
+		s, cfg := load()
+		Expect(cfg.AddPackage(&model.Package{Name: "bat"})).To(Succeed())
+		Expect(s.WriteConfiguration(cfg)).To(Succeed())
+
+		Expect(readFile(filepath.Join(userDir, "config.yml"))).To(Equal("version: 1\npackages:\n  - name: bat\n  - fzf@0.50.0\n  - ripgrep\n"))
+		Expect(readFile(filepath.Join(repoDir, ".scfg.yml"))).To(Equal("packages:\n  - ripgrep@14.1.0\n  - go\n"))
+		Expect(readFile(filepath.Join(systemDir, "config.yml"))).To(Equal("packages:\n  - curl\n  - fzf@0.40.0\n"))
+	})
+
+	It("refuses to change packages defined by the system configuration", func() {
+		s, cfg := load()
+		Expect(cfg.AddPackage(&model.Package{Name: "bat"})).To(Succeed())
+		Expect(cfg.RemovePackage("curl")).To(Succeed())
+
+		err := s.WriteConfiguration(cfg)
+		Expect(err).To(MatchError(&store.ReadOnlyError{
+			Source: store.Source{Layer: store.LayerSystem, Path: filepath.Join(systemDir, "config.yml")},
+			Names:  []string{"curl"},
+		}))
+		Expect(err).To(MatchError("unable to change `curl`, defined in the system configuration `" + filepath.Join(systemDir, "config.yml") + "`, which is not written: edit it there instead"))
+		Expect(readFile(filepath.Join(userDir, "config.yml"))).To(Equal("packages:\n  - fzf@0.50.0\n  - ripgrep\n"))
+		Expect(readFile(filepath.Join(systemDir, "config.yml"))).To(Equal("packages:\n  - curl\n  - fzf@0.40.0\n"))
+	})
+
+	It("refuses to remove repositories defined by the system configuration", func() {
+		writeFile(filepath.Join(systemDir, "config.yml"), "repositories:\n  - name: hashicorp\n    brew: {tap: hashicorp/tap}\npackages:\n  - curl\n")
+		s, cfg := load()
+		Expect(cfg.RemoveRepository("hashicorp")).To(Succeed())
+
+		Expect(s.WriteConfiguration(cfg)).To(MatchError(&store.ReadOnlyError{
+			Source: store.Source{Layer: store.LayerSystem, Path: filepath.Join(systemDir, "config.yml")},
+			Names:  []string{"hashicorp"},
+		}))
+	})
+
+	It("refuses to change packages defined by the project configuration", func() {
+		s, cfg := load()
+		Expect(cfg.RemovePackage("go")).To(Succeed())
+		Expect(cfg.RemovePackage("ripgrep")).To(Succeed())
+
+		Expect(s.WriteConfiguration(cfg)).To(MatchError(&store.ReadOnlyError{
+			Source: store.Source{Layer: store.LayerProject, Path: filepath.Join(repoDir, ".scfg.yml")},
+			Names:  []string{"go", "ripgrep"},
+		}))
+		Expect(readFile(filepath.Join(userDir, "config.yml"))).To(Equal("packages:\n  - fzf@0.50.0\n  - ripgrep\n"))
+		Expect(readFile(filepath.Join(repoDir, ".scfg.yml"))).To(Equal("packages:\n  - ripgrep@14.1.0\n  - go\n"))
+	})
+
+	It("loads fragments of the project configuration from .scfg.d", func() {
+		writeFile(filepath.Join(repoDir, "config.d", "tools.yml"), "packages:\n  - unrelated\n")
+		writeFile(filepath.Join(repoDir, ".scfg.d", "tools.yml"), "packages:\n  - make\n")
+
+		_, cfg := load()
+		pkg, _ := cfg.FindPackage("make")
+		Expect(pkg).ToNot(BeNil())
+		pkg, _ = cfg.FindPackage("unrelated")
+		Expect(pkg).To(BeNil())
+	})
+
+	Context("when the working directory is not in a repository", func() {
+		BeforeEach(func() {
+			Expect(os.RemoveAll(filepath.Join(repoDir, ".git"))).To(Succeed())
+		})
+
+		It("only looks for a project configuration in the working directory", func() {
+			layers, err := store.Layers()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(layers[2]).To(Equal(store.Layer{Name: store.LayerProject}))
+		})
+	})
+
+	Context("when the system configuration does not exist", func() {
+		BeforeEach(func() {
+			Expect(os.Remove(filepath.Join(systemDir, "config.yml"))).To(Succeed())
+		})
+
+		It("skips it", func() {
+			_, cfg := load()
+			pkg, _ := cfg.FindPackage("curl")
+			Expect(pkg).To(BeNil())
+		})
+	})
+
+	Context("when an explicit configuration is given", func() {
+		var explicitPath string
+
+		BeforeEach(func() {
+			explicitPath = filepath.Join(GinkgoT().TempDir(), "other.yml")
+			store.ConfigPath = explicitPath
+			DeferCleanup(func() { store.ConfigPath = "" })
+		})
+
+		It("uses only that file", func() {
+			writeFile(explicitPath, "packages:\n  - jq\n")
+
+			_, cfg := load()
+			Expect(cfg.Packages).To(HaveLen(1))
+			Expect(sourceOf(cfg, "jq")).To(Equal(store.Source{Layer: store.LayerExplicit, Path: explicitPath}))
+			Expect(store.Layers()).To(Equal([]store.Layer{{Name: store.LayerExplicit, Path: explicitPath}}))
+			Expect(store.LocalConfigPath()).To(Equal(explicitPath))
+		})
+
+		It("returns an error when it does not exist", func() {
+			_, err := store.NewDefault()
+			Expect(err).To(MatchError(HavePrefix("unable to use configuration `" + explicitPath + "`")))
+		})
+	})
+})
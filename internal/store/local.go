@@ -2,11 +2,11 @@ package store
 
 import (
 	"errors"
-	"fmt"
+	"io"
 	"os"
 	"path"
 
-	"gopkg.in/yaml.v3"
+	"github.com/drew-english/system-configurator/pkg/logging"
 )
 
 const (
@@ -17,6 +17,7 @@ type (
 	LocalCfg struct {
 		Location string
 		FileName string
+		Layer    string
 	}
 
 	localStore struct {
@@ -38,8 +39,14 @@ func NewLocal(cfg *LocalCfg) (s Store, err error) {
 	return
 }
 
-var LocalDefaultLocation = func() string {
-	return fmt.Sprintf("%s/.config/system-configurator", resolveHomeDir())
+// LocalConfigPath is the path of the configuration file commands write to, the file given by
+// ConfigPath when set or the user configuration.
+func LocalConfigPath() (string, error) {
+	if ConfigPath != "" {
+		return ConfigPath, nil
+	}
+
+	return (&LocalCfg{}).filePath()
 }
 
 func (ls *localStore) LoadConfiguration() (*Configuration, error) {
@@ -47,14 +54,13 @@ func (ls *localStore) LoadConfiguration() (*Configuration, error) {
 		return nil, errors.New("error referencing local configuration file")
 	}
 
-	configData := &Configuration{}
-	decoder := yaml.NewDecoder(ls.configFile)
-	err := decoder.Decode(configData)
+	logging.Debug("loading configuration", "path", ls.configFile.Name())
+	data, err := io.ReadAll(ls.configFile)
 	if err != nil {
 		return nil, err
 	}
 
-	return configData, nil
+	return loadFragments(ls.cfg.layer(), ls.configFile.Name(), data)
 }
 
 func (ls *localStore) WriteConfiguration(configData *Configuration) error {
@@ -66,17 +72,28 @@ func (ls *localStore) WriteConfiguration(configData *Configuration) error {
 		return errors.New("configuration data cannot be nil")
 	}
 
-	encoder := yaml.NewEncoder(ls.configFile)
-	encoder.SetIndent(2)
-	err := encoder.Encode(configData)
-	return err
+	if configData.sources != nil {
+		return configData.sources.write(configData)
+	}
+
+	data, err := encodeConfiguration(configData)
+	if err != nil {
+		return err
+	}
+
+	return writeConfigurationFile(ls.configFile.Name(), data)
 }
 
 func (ls *localStore) localConfigFile() (*os.File, error) {
-	file, err := os.OpenFile(ls.cfg.filePath(), os.O_RDWR, 0644)
+	filePath, err := ls.cfg.filePath()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filePath, os.O_RDWR, 0644)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return ls.createLocalConfigFile()
+			return createLocalConfigFile(filePath)
 		}
 
 		return nil, err
@@ -85,13 +102,14 @@ func (ls *localStore) localConfigFile() (*os.File, error) {
 	return file, nil
 }
 
-func (ls *localStore) createLocalConfigFile() (*os.File, error) {
-	err := os.MkdirAll(ls.cfg.location(), 0755)
+func createLocalConfigFile(filePath string) (*os.File, error) {
+	logging.Debug("creating configuration", "path", filePath)
+	err := os.MkdirAll(path.Dir(filePath), 0755)
 	if err != nil {
 		return nil, err
 	}
 
-	file, err := os.Create(ls.cfg.filePath())
+	file, err := os.Create(filePath)
 	if err != nil {
 		return nil, err
 	}
@@ -109,35 +127,35 @@ func (ls *localStore) createLocalConfigFile() (*os.File, error) {
 	return file, nil
 }
 
-func (lc *LocalCfg) filePath() string {
-	return path.Join(lc.location(), lc.fileName())
+func (lc *LocalCfg) filePath() (string, error) {
+	location, err := lc.location()
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(location, lc.fileName()), nil
 }
 
-func (lc *LocalCfg) location() string {
+func (lc *LocalCfg) location() (string, error) {
 	if lc != nil && lc.Location != "" {
-		return lc.Location
+		return lc.Location, nil
 	}
 
 	return LocalDefaultLocation()
 }
 
-func (lc *LocalCfg) fileName() string {
-	if lc != nil && lc.FileName != "" {
-		return lc.FileName
+func (lc *LocalCfg) layer() string {
+	if lc != nil && lc.Layer != "" {
+		return lc.Layer
 	}
 
-	return LocalDefaultFileName
+	return LayerUser
 }
 
-func resolveHomeDir() string {
-	if home := os.Getenv("HOME"); home != "" {
-		return home
-	}
-
-	home, err := os.UserConfigDir()
-	if err != nil {
-		panic("unable to determine user's home directory\nplease set the $HOME environment variable or ensure %USERPROFILE% is set on Windows systems")
+func (lc *LocalCfg) fileName() string {
+	if lc != nil && lc.FileName != "" {
+		return lc.FileName
 	}
 
-	return home
+	return LocalDefaultFileName
 }
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	LockFileName       = "config.lock.yml"
+	lockFileSuffix     = ".lock.yml"
+	CurrentLockVersion = 1
+)
+
+// Lock records the exact version installed for each package of the configuration, per package manager,
+// so that other machines can install the same versions.
+type Lock struct {
+	Version  int                          `yaml:"version"`
+	Managers map[string]map[string]string `yaml:"managers,omitempty"`
+}
+
+// LockPath is the lockfile of the configuration, kept next to it. The lockfile of a remote configuration
+// is kept next to its cached copy, so each URL has its own.
+var LockPath = func() (string, error) {
+	var (
+		cfgPath string
+		err     error
+	)
+
+	if IsRemote(ConfigPath) {
+		cfgPath, err = remoteCachePath(ConfigPath)
+	} else {
+		cfgPath, err = LocalConfigPath()
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(cfgPath), filepath.Ext(cfgPath))
+	return filepath.Join(filepath.Dir(cfgPath), name+lockFileSuffix), nil
+}
+
+// LoadLock reads the lockfile, returning an empty lock when there is none.
+var LoadLock = func() (*Lock, error) {
+	lockPath, err := LockPath()
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &Lock{Version: CurrentLockVersion}
+	data, err := os.ReadFile(lockPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return lock, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("invalid lockfile `%s`: %w", lockPath, err)
+	}
+
+	if lock.Version > CurrentLockVersion {
+		return nil, fmt.Errorf("lockfile version %d is newer than the latest supported version %d, upgrade scfg to use it", lock.Version, CurrentLockVersion)
+	}
+
+	return lock, nil
+}
+
+// WriteLock replaces the lockfile.
+var WriteLock = func(lock *Lock) error {
+	lockPath, err := LockPath()
+	if err != nil {
+		return err
+	}
+
+	lock.Version = CurrentLockVersion
+	data, err := encodeYAML(lock)
+	if err != nil {
+		return err
+	}
+
+	return writeConfigurationFile(lockPath, data)
+}
+
+// Locked returns the version locked for a package of a manager.
+func (l *Lock) Locked(manager, name string) (string, bool) {
+	version, ok := l.Managers[manager][name]
+	return version, ok
+}
+
+// SetManager replaces the versions locked for a manager.
+func (l *Lock) SetManager(manager string, versions map[string]string) {
+	if l.Managers == nil {
+		l.Managers = make(map[string]map[string]string)
+	}
+
+	l.Managers[manager] = versions
+}
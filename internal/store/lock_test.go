@@ -0,0 +1,84 @@
+package store_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/drew-english/system-configurator/internal/store"
+	store_stub "github.com/drew-english/system-configurator/spec/stub/store"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Lock", func() {
+	var root, userDir, projectDir string
+
+	BeforeEach(func() {
+		root = GinkgoT().TempDir()
+		userDir = filepath.Join(root, "home")
+		projectDir = filepath.Join(root, "project")
+		Expect(os.MkdirAll(projectDir, 0755)).To(Succeed())
+		DeferCleanup(store_stub.StubLayers(filepath.Join(root, "etc"), userDir, projectDir))
+	})
+
+	It("is kept next to the user configuration", func() {
+		Expect(store.LockPath()).To(Equal(filepath.Join(userDir, store.LockFileName)))
+	})
+
+	It("is kept next to an explicit configuration", func() {
+		store.ConfigPath = filepath.Join(projectDir, "work.yml")
+		DeferCleanup(func() { store.ConfigPath = "" })
+
+		Expect(store.LockPath()).To(Equal(filepath.Join(projectDir, "work.lock.yml")))
+	})
+
+	It("is kept next to the cached copy of a remote configuration, for each URL", func() {
+		original := store.CacheDefaultLocation
+		store.CacheDefaultLocation = func() (string, error) { return filepath.Join(root, "cache"), nil }
+		DeferCleanup(func() { store.CacheDefaultLocation = original })
+		store.ConfigPath = "https://example.com/config.yml"
+		DeferCleanup(func() { store.ConfigPath = "" })
+
+		lockPath, err := store.LockPath()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(filepath.Dir(lockPath)).To(Equal(filepath.Join(root, "cache", "remote")))
+		Expect(lockPath).To(HaveSuffix(".lock.yml"))
+
+		store.ConfigPath = "https://example.com/other.yml"
+		Expect(store.LockPath()).ToNot(Equal(lockPath))
+	})
+
+	It("returns an empty lock when there is no lockfile", func() {
+		lock, err := store.LoadLock()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lock).To(Equal(&store.Lock{Version: store.CurrentLockVersion}))
+	})
+
+	It("writes and reads the locked versions per manager", func() {
+		lock := &store.Lock{}
+		lock.SetManager("apt", map[string]string{"fzf": "0.44.1-1"})
+		lock.SetManager("brew", map[string]string{"fzf": "0.46.0"})
+		Expect(store.WriteLock(lock)).To(Succeed())
+
+		data, err := os.ReadFile(filepath.Join(userDir, store.LockFileName))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(Equal("version: 1\nmanagers:\n  apt:\n    fzf: 0.44.1-1\n  brew:\n    fzf: 0.46.0\n"))
+
+		lock, err = store.LoadLock()
+		Expect(err).ToNot(HaveOccurred())
+		version, ok := lock.Locked("brew", "fzf")
+		Expect(ok).To(BeTrue())
+		Expect(version).To(Equal("0.46.0"))
+		_, ok = lock.Locked("apk", "fzf")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("refuses a lockfile from a newer version", func() {
+		Expect(os.MkdirAll(userDir, 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(userDir, store.LockFileName), []byte("version: 2\n"), 0644)).To(Succeed())
+
+		_, err := store.LoadLock()
+		Expect(err).To(MatchError("lockfile version 2 is newer than the latest supported version 1, upgrade scfg to use it"))
+	})
+})
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the latest configuration schema version this binary understands.
+const CurrentVersion = 1
+
+// Migration upgrades a configuration document from one schema version to the next.
+type Migration struct {
+	From        int
+	Description string
+	Migrate     func(root *yaml.Node) error
+}
+
+// Migrations applied in order to upgrade older documents, one for each version before CurrentVersion.
+var Migrations = []Migration{
+	{
+		From:        0,
+		Description: "Add the schema version",
+		Migrate:     func(root *yaml.Node) error { return nil },
+	},
+}
+
+// DocumentVersion returns the schema version of a configuration document, which is 0 when it has none.
+func DocumentVersion(doc *yaml.Node) (int, error) {
+	root := documentRoot(doc)
+	if root == nil || root.Kind != yaml.MappingNode {
+		return 0, nil
+	}
+
+	node := mappingValue(root, "version")
+	if node == nil {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(node.Value)
+	if err != nil || node.Kind != yaml.ScalarNode || version < 0 {
+		return 0, fmt.Errorf("%d:%d: `version` must be a non-negative integer", node.Line, node.Column)
+	}
+
+	return version, nil
+}
+
+// MigrateDocument upgrades doc to CurrentVersion in place, returning the version it was upgraded from.
+// Documents from a newer version than CurrentVersion are refused.
+func MigrateDocument(doc *yaml.Node) (int, error) {
+	from, err := DocumentVersion(doc)
+	if err != nil {
+		return 0, err
+	}
+
+	if from > CurrentVersion {
+		return from, fmt.Errorf("configuration version %d is newer than the latest supported version %d, upgrade scfg to use it", from, CurrentVersion)
+	}
+
+	root := documentRoot(doc)
+	if from == CurrentVersion || root == nil || root.Kind != yaml.MappingNode {
+		return from, nil
+	}
+
+	for _, migration := range Migrations {
+		if migration.From < from {
+			continue
+		}
+
+		if err := migration.Migrate(root); err != nil {
+			return from, fmt.Errorf("failed to migrate configuration from version %d: %w", migration.From, err)
+		}
+	}
+
+	setVersion(root, CurrentVersion)
+	return from, nil
+}
+
+func documentRoot(doc *yaml.Node) *yaml.Node {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil
+	}
+
+	return doc.Content[0]
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// setVersion sets the version of root, adding it as the first key when missing.
+func setVersion(root *yaml.Node, version int) {
+	if node := mappingValue(root, "version"); node != nil {
+		node.Value = strconv.Itoa(version)
+		return
+	}
+
+	root.Content = append([]*yaml.Node{
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"},
+		{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(version)},
+	}, root.Content...)
+}
@@ -0,0 +1,68 @@
+package store_test
+
+import (
+	"strings"
+
+	"github.com/drew-english/system-configurator/internal/store"
+	"gopkg.in/yaml.v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MigrateDocument", func() {
+	var doc *yaml.Node
+
+	parse := func(content string) {
+		doc = &yaml.Node{}
+		Expect(yaml.Unmarshal([]byte(content), doc)).To(Succeed())
+	}
+
+	encode := func() string {
+		buf := &strings.Builder{}
+		encoder := yaml.NewEncoder(buf)
+		encoder.SetIndent(2)
+		Expect(encoder.Encode(doc)).To(Succeed())
+		return buf.String()
+	}
+
+	It("adds the current version to documents without one", func() {
+		parse("# my packages\npackages:\n  - fzf\n")
+		Expect(store.MigrateDocument(doc)).To(Equal(0))
+		Expect(encode()).To(Equal("version: 1\n# my packages\npackages:\n  - fzf\n"))
+	})
+
+	It("leaves documents at the current version unchanged", func() {
+		parse("version: 1\npackages: []\n")
+		Expect(store.MigrateDocument(doc)).To(Equal(1))
+		Expect(encode()).To(Equal("version: 1\npackages: []\n"))
+	})
+
+	It("applies each migration from the document's version", func() {
+		original := store.Migrations
+		DeferCleanup(func() { store.Migrations = original })
+		store.Migrations = []store.Migration{{
+			From: 0,
+			Migrate: func(root *yaml.Node) error {
+				root.Content[0].Value = "packages"
+				return nil
+			},
+		}}
+
+		parse("pkgs:\n  - fzf\n")
+		Expect(store.MigrateDocument(doc)).To(Equal(0))
+		Expect(encode()).To(Equal("version: 1\npackages:\n  - fzf\n"))
+	})
+
+	It("refuses documents from a newer version", func() {
+		parse("version: 2\npackages: []\n")
+		_, err := store.MigrateDocument(doc)
+		Expect(err).To(MatchError("configuration version 2 is newer than the latest supported version 1, upgrade scfg to use it"))
+	})
+
+	It("returns an error for an invalid version", func() {
+		parse("version: latest\n")
+		_, err := store.MigrateDocument(doc)
+		Expect(err).To(MatchError("1:10: `version` must be a non-negative integer"))
+	})
+})
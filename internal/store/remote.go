@@ -0,0 +1,302 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/drew-english/system-configurator/internal/signature"
+	"github.com/drew-english/system-configurator/pkg/logging"
+)
+
+const (
+	LayerRemote = "remote"
+
+	remoteCacheDirName = "remote"
+	remoteMaxSize      = 10 << 20
+)
+
+type (
+	RemoteCfg struct {
+		URL string
+		// Checksum pins the configuration to a SHA-256 digest, given as hex with an optional `sha256:` prefix.
+		Checksum string
+		Client   *http.Client
+		// Context cancels the requests for the configuration, context.Background() when nil.
+		Context context.Context
+	}
+
+	remoteStore struct {
+		cfg       *RemoteCfg
+		cachePath string
+	}
+
+	statusError struct {
+		Status string
+		Code   int
+	}
+)
+
+// ConfigChecksum pins a remote ConfigPath to a SHA-256 digest, set by `--config-checksum`.
+var ConfigChecksum string
+
+// RemoteTimeout bounds each request for a remote configuration.
+var RemoteTimeout = 30 * time.Second
+
+// RemoteContext cancels the requests for a remote ConfigPath, set to the context commands are executed with.
+var RemoteContext = context.Background()
+
+// IsRemote reports whether a configuration path is an HTTP(S) URL.
+func IsRemote(p string) bool {
+	return strings.HasPrefix(p, "https://") || strings.HasPrefix(p, "http://")
+}
+
+// NewRemote returns a read-only store for a configuration served over HTTP(S). Responses are cached
+// along with their ETag, so unchanged configurations are not downloaded again and the cached copy
+// can be used when the server is unreachable.
+func NewRemote(cfg *RemoteCfg) (Store, error) {
+	if !IsRemote(cfg.URL) {
+		return nil, fmt.Errorf("invalid remote configuration `%s`, expected an http(s) URL", cfg.URL)
+	}
+
+	if _, err := parseChecksum(cfg.Checksum); err != nil {
+		return nil, err
+	}
+
+	cachePath, err := remoteCachePath(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &remoteStore{cfg: cfg, cachePath: cachePath}, nil
+}
+
+// remoteCachePath is where the configuration at url is cached, keyed by the URL.
+func remoteCachePath(url string) (string, error) {
+	cacheLocation, err := CacheDefaultLocation()
+	if err != nil {
+		return "", err
+	}
+
+	key := sha256.Sum256([]byte(url))
+	return filepath.Join(cacheLocation, remoteCacheDirName, hex.EncodeToString(key[:16])+".yml"), nil
+}
+
+func (rs *remoteStore) LoadConfiguration() (*Configuration, error) {
+	data, err := rs.fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := decodeConfiguration(data, rs.cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration `%s`:\n%w", rs.cfg.URL, err)
+	}
+
+	if len(cfg.Include) > 0 || cfg.Personal != "" {
+		return nil, fmt.Errorf("invalid configuration `%s`: `include` and `personal` are not supported in remote configurations", rs.cfg.URL)
+	}
+
+	f, err := newFragment(LayerRemote, rs.cfg.URL, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if sig := rs.fetchSignature(); sig != nil {
+		if f.signed, err = verify(rs.cfg.URL, data, sig); err != nil {
+			return nil, err
+		}
+	}
+
+	merged := &Configuration{Version: cfg.Version}
+	src := newSources()
+	src.add(merged, f)
+	return src.finish(merged), nil
+}
+
+func (rs *remoteStore) WriteConfiguration(*Configuration) error {
+	return fmt.Errorf("remote configuration `%s` is read-only", rs.cfg.URL)
+}
+
+// fetch returns the configuration, from the cache when the server reports it is unchanged or cannot be reached.
+func (rs *remoteStore) fetch() ([]byte, error) {
+	cached, cacheErr := os.ReadFile(rs.cachePath)
+	if cacheErr != nil && !errors.Is(cacheErr, fs.ErrNotExist) {
+		logging.Warn("unable to read cached configuration", "path", rs.cachePath, "error", cacheErr)
+	}
+
+	var etag string
+	if cacheErr == nil {
+		if data, err := os.ReadFile(rs.etagPath()); err == nil {
+			etag = strings.TrimSpace(string(data))
+		}
+	}
+
+	data, newETag, err := rs.download(rs.cfg.URL, etag)
+	if err != nil {
+		if cacheErr != nil {
+			return nil, fmt.Errorf("unable to download configuration `%s`: %w", rs.cfg.URL, err)
+		}
+
+		logging.Warn("unable to download configuration, using the cached copy", "url", rs.cfg.URL, "error", err)
+		return cached, rs.verify(cached)
+	}
+
+	if data == nil {
+		logging.Debug("remote configuration is unchanged", "url", rs.cfg.URL)
+		return cached, rs.verify(cached)
+	}
+
+	if err := rs.verify(data); err != nil {
+		return nil, err
+	}
+
+	if err := rs.cache(data, newETag); err != nil {
+		logging.Warn("unable to cache configuration", "path", rs.cachePath, "error", err)
+	}
+
+	return data, nil
+}
+
+// fetchSignature returns the detached signature published next to the configuration, or the cached copy when
+// the server cannot be reached. Returns nil when the configuration is not signed.
+func (rs *remoteStore) fetchSignature() []byte {
+	sigURL := rs.cfg.URL + signature.Extension
+	sigPath := rs.cachePath + signature.Extension
+
+	sig, _, err := rs.download(sigURL, "")
+	var status *statusError
+	if errors.As(err, &status) && status.Code == http.StatusNotFound {
+		os.Remove(sigPath)
+		return nil
+	} else if err != nil {
+		logging.Warn("unable to download configuration signature, using the cached copy", "url", sigURL, "error", err)
+		sig, _ = os.ReadFile(sigPath)
+		return sig
+	}
+
+	if err := os.WriteFile(sigPath, sig, 0644); err != nil {
+		logging.Warn("unable to cache configuration signature", "path", sigPath, "error", err)
+	}
+
+	return sig
+}
+
+// download requests url, returning nil data when it matches etag.
+func (rs *remoteStore) download(url, etag string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(rs.context(), http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	logging.Debug("downloading configuration", "url", url, "etag", etag)
+	resp, err := rs.client().Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && etag != "":
+		return nil, etag, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, "", &statusError{Status: resp.Status, Code: resp.StatusCode}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, remoteMaxSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(data) > remoteMaxSize {
+		return nil, "", fmt.Errorf("configuration is larger than %d bytes", remoteMaxSize)
+	}
+
+	return data, resp.Header.Get("ETag"), nil
+}
+
+// verify checks data against the pinned checksum, if any.
+func (rs *remoteStore) verify(data []byte) error {
+	want, _ := parseChecksum(rs.cfg.Checksum)
+	if want == "" {
+		return nil
+	}
+
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return fmt.Errorf("checksum mismatch for configuration `%s`: expected sha256:%s, got sha256:%s", rs.cfg.URL, want, got)
+	}
+
+	return nil
+}
+
+func (rs *remoteStore) cache(data []byte, etag string) error {
+	if err := os.MkdirAll(filepath.Dir(rs.cachePath), 0755); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(rs.cachePath, data, 0644); err != nil {
+		return err
+	}
+
+	if etag == "" {
+		if err := os.Remove(rs.etagPath()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		return nil
+	}
+
+	return os.WriteFile(rs.etagPath(), []byte(etag), 0644)
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected response status `%s`", e.Status)
+}
+
+func (rs *remoteStore) etagPath() string {
+	return strings.TrimSuffix(rs.cachePath, ".yml") + ".etag"
+}
+
+func (rs *remoteStore) context() context.Context {
+	if rs.cfg.Context != nil {
+		return rs.cfg.Context
+	}
+
+	return context.Background()
+}
+
+func (rs *remoteStore) client() *http.Client {
+	if rs.cfg.Client != nil {
+		return rs.cfg.Client
+	}
+
+	return &http.Client{Timeout: RemoteTimeout}
+}
+
+// parseChecksum returns the lowercase hex SHA-256 digest of a checksum, which is empty when none is given.
+func parseChecksum(checksum string) (string, error) {
+	digest := strings.ToLower(strings.TrimPrefix(checksum, "sha256:"))
+	if digest == "" {
+		return "", nil
+	}
+
+	if decoded, err := hex.DecodeString(digest); err != nil || len(decoded) != sha256.Size {
+		return "", fmt.Errorf("invalid checksum `%s`, expected sha256:<hex digest>", checksum)
+	}
+
+	return digest, nil
+}
@@ -0,0 +1,234 @@
+package store_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/drew-english/system-configurator/internal/signature"
+	"github.com/drew-english/system-configurator/internal/store"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Remote", func() {
+	const body = "packages:\n  - fzf\n  - jq\n"
+
+	var (
+		server   *httptest.Server
+		requests []*http.Request
+		status   int
+		checksum string
+		sig      []byte
+	)
+
+	load := func() (*store.Configuration, error) {
+		s, err := store.NewRemote(&store.RemoteCfg{URL: server.URL + "/base.yml", Checksum: checksum})
+		Expect(err).ToNot(HaveOccurred())
+
+		return s.LoadConfiguration()
+	}
+
+	names := func(cfg *store.Configuration) []string {
+		var names []string
+		for _, pkg := range cfg.Packages {
+			names = append(names, pkg.String())
+		}
+
+		return names
+	}
+
+	BeforeEach(func() {
+		requests = nil
+		status = http.StatusOK
+		checksum = ""
+		sig = nil
+
+		cacheDir := GinkgoT().TempDir()
+		original := store.CacheDefaultLocation
+		store.CacheDefaultLocation = func() (string, error) { return cacheDir, nil }
+		DeferCleanup(func() { store.CacheDefaultLocation = original })
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/base.yml"+signature.Extension {
+				if sig == nil {
+					w.WriteHeader(http.StatusNotFound)
+				}
+
+				w.Write(sig)
+				return
+			}
+
+			requests = append(requests, r)
+			if status != http.StatusOK {
+				w.WriteHeader(status)
+				return
+			}
+
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			w.Write([]byte(body))
+		}))
+		DeferCleanup(server.Close)
+	})
+
+	It("loads the configuration from the server", func() {
+		cfg, err := load()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(names(cfg)).To(Equal([]string{"fzf", "jq"}))
+
+		source, ok := cfg.SourceOf("fzf")
+		Expect(ok).To(BeTrue())
+		Expect(source).To(Equal(store.Source{Layer: store.LayerRemote, Path: server.URL + "/base.yml"}))
+	})
+
+	It("uses the cached copy when the server reports it is unchanged", func() {
+		_, err := load()
+		Expect(err).ToNot(HaveOccurred())
+
+		cfg, err := load()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(names(cfg)).To(Equal([]string{"fzf", "jq"}))
+		Expect(requests).To(HaveLen(2))
+		Expect(requests[0].Header.Get("If-None-Match")).To(BeEmpty())
+		Expect(requests[1].Header.Get("If-None-Match")).To(Equal(`"v1"`))
+	})
+
+	It("falls back to the cached copy when the server is unreachable", func() {
+		_, err := load()
+		Expect(err).ToNot(HaveOccurred())
+
+		status = http.StatusServiceUnavailable
+		cfg, err := load()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(names(cfg)).To(Equal([]string{"fzf", "jq"}))
+
+		server.Close()
+		cfg, err = load()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(names(cfg)).To(Equal([]string{"fzf", "jq"}))
+	})
+
+	It("returns an error when the server is unreachable and nothing is cached", func() {
+		status = http.StatusNotFound
+		_, err := load()
+		Expect(err).To(MatchError("unable to download configuration `" + server.URL + "/base.yml`: unexpected response status `404 Not Found`"))
+	})
+
+	It("is read-only", func() {
+		s, err := store.NewRemote(&store.RemoteCfg{URL: server.URL + "/base.yml"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s.WriteConfiguration(&store.Configuration{})).To(MatchError("remote configuration `" + server.URL + "/base.yml` is read-only"))
+	})
+
+	Context("when a checksum is pinned", func() {
+		It("accepts a matching configuration", func() {
+			sum := sha256.Sum256([]byte(body))
+			checksum = "sha256:" + hex.EncodeToString(sum[:])
+
+			_, err := load()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("refuses a configuration that does not match", func() {
+			checksum = "sha256:" + hex.EncodeToString(make([]byte, sha256.Size))
+
+			_, err := load()
+			Expect(err).To(MatchError(HavePrefix("checksum mismatch for configuration `" + server.URL + "/base.yml`")))
+		})
+
+		It("refuses a malformed checksum", func() {
+			_, err := store.NewRemote(&store.RemoteCfg{URL: server.URL, Checksum: "md5:abc"})
+			Expect(err).To(MatchError("invalid checksum `md5:abc`, expected sha256:<hex digest>"))
+		})
+	})
+
+	Context("when the configuration is signed", func() {
+		var key *signature.PrivateKey
+
+		BeforeEach(func() {
+			var err error
+			key, err = signature.GenerateKey()
+			Expect(err).ToNot(HaveOccurred())
+			sig = signature.Sign(key, []byte(body), "base.yml")
+
+			original := store.TrustedKeys
+			store.TrustedKeys = func() ([]*signature.PublicKey, error) { return []*signature.PublicKey{key.Public()}, nil }
+			DeferCleanup(func() { store.TrustedKeys = original })
+		})
+
+		It("verifies the signature", func() {
+			cfg, err := load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.UnsignedRemoteSources()).To(BeEmpty())
+		})
+
+		It("uses the cached signature when the server is unreachable", func() {
+			_, err := load()
+			Expect(err).ToNot(HaveOccurred())
+
+			server.Close()
+			cfg, err := load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.UnsignedRemoteSources()).To(BeEmpty())
+		})
+
+		It("refuses a configuration that does not match the signature", func() {
+			sig = signature.Sign(key, []byte("packages: []\n"), "base.yml")
+
+			_, err := load()
+			Expect(err).To(MatchError(signature.ErrInvalidSignature))
+		})
+
+		It("refuses a signature from a key that is not trusted", func() {
+			other, err := signature.GenerateKey()
+			Expect(err).ToNot(HaveOccurred())
+			sig = signature.Sign(other, []byte(body), "base.yml")
+
+			_, err = load()
+			Expect(err).To(MatchError(signature.ErrUntrustedKey))
+		})
+	})
+
+	It("stops downloading when the context is cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		s, err := store.NewRemote(&store.RemoteCfg{URL: server.URL + "/base.yml", Context: ctx})
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = s.LoadConfiguration()
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(requests).To(BeEmpty())
+	})
+
+	It("reports an unsigned configuration", func() {
+		cfg, err := load()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.UnsignedRemoteSources()).To(Equal([]store.Source{{Layer: store.LayerRemote, Path: server.URL + "/base.yml"}}))
+	})
+
+	Context("when the configuration is given as a URL", func() {
+		BeforeEach(func() {
+			store.ConfigPath = server.URL + "/base.yml"
+			DeferCleanup(func() { store.ConfigPath = "" })
+		})
+
+		It("loads it with the default store", func() {
+			s, err := store.NewDefault()
+			Expect(err).ToNot(HaveOccurred())
+
+			cfg, err := s.LoadConfiguration()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(names(cfg)).To(Equal([]string{"fzf", "jq"}))
+			Expect(store.Layers()).To(Equal([]store.Layer{{Name: store.LayerRemote, Path: server.URL + "/base.yml"}}))
+		})
+	})
+})
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/pkg/sys/pkgmanager"
+)
+
+const schemaID = "https://github.com/drew-english/system-configurator/schema/config.json"
+
+type object = map[string]any
+
+// Schema returns a JSON Schema document describing the configuration, for use with editors.
+func Schema() ([]byte, error) {
+	managers := make([]string, 0, len(pkgmanager.Managers))
+	for name := range pkgmanager.Managers {
+		managers = append(managers, name)
+	}
+	slices.Sort(managers)
+
+	version := object{"type": "string", "pattern": versionRegex.String()}
+	kind := object{"enum": packageKinds, "description": "Whether brew installs the package as a formula (default) or cask"}
+	tap := object{"type": "string", "pattern": model.TapRegex.String(), "description": "Third-party repository brew installs the package from, as <user>/<repository>"}
+	repositorySources := object{}
+	for managerName, keys := range repositorySourceKeys {
+		properties := object{}
+		for _, key := range keys {
+			properties[key] = object{"type": "string"}
+		}
+
+		if _, ok := properties["components"]; ok {
+			properties["components"] = object{"type": "array", "items": object{"type": "string"}}
+		}
+
+		if _, ok := properties["tap"]; ok {
+			properties["tap"] = tap
+		}
+
+		repositorySources[managerName] = object{
+			"type":                 "object",
+			"required":             keys[:1],
+			"additionalProperties": false,
+			"properties":           properties,
+		}
+	}
+
+	requires := object{
+		"type":        "array",
+		"items":       object{"type": "string", "pattern": `^((package|repository):)?[^:\s]\S*$`},
+		"description": "Packages and repositories to install first, as [package:|repository:]<name>",
+	}
+
+	repositorySources["name"] = object{"type": "string", "pattern": repositoryRegex.String()}
+	repositorySources["requires"] = requires
+
+	pkgString := object{
+		"type":        "string",
+		"pattern":     `^[^@\s]+(@[A-Za-z0-9*][A-Za-z0-9._+~:*-]*)?$`,
+		"description": "A package in the form <name>[@<version>]",
+	}
+
+	schema := object{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"$id":                  schemaID,
+		"title":                "system-configurator configuration",
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": object{
+			"version": object{
+				"type":        "integer",
+				"minimum":     0,
+				"maximum":     CurrentVersion,
+				"description": "Schema version of the configuration, older versions are migrated when loaded",
+			},
+			"include": object{
+				"type":        "array",
+				"items":       object{"type": "string"},
+				"description": "Other files to merge into the configuration, relative to it and optionally using glob patterns",
+			},
+			"personal": object{
+				"type":        "string",
+				"description": "Fragment that new packages are written to, relative to the configuration",
+			},
+			"repositories": object{
+				"type":        "array",
+				"items":       object{"$ref": "#/$defs/repository"},
+				"description": "Third-party repositories to configure before installing packages",
+			},
+			"packages": object{
+				"type":  "array",
+				"items": object{"$ref": "#/$defs/package"},
+			},
+		},
+		"$defs": object{
+			"package": object{
+				"oneOf": []any{
+					pkgString,
+					object{
+						"type":                 "object",
+						"required":             []string{"name"},
+						"additionalProperties": false,
+						"properties": object{
+							"name":    object{"type": "string", "minLength": 1},
+							"version": version,
+							"kind":    kind,
+							"tap":     tap,
+							"repository": object{
+								"type":        "string",
+								"description": "Name of the repository the package is installed from, configured before the package is installed",
+							},
+							"requires": requires,
+							"alternates": object{
+								"type":                 "object",
+								"description":          "Packages to use instead for specific package managers",
+								"propertyNames":        object{"enum": managers},
+								"additionalProperties": object{"$ref": "#/$defs/alternate"},
+							},
+						},
+					},
+				},
+			},
+			"repository": object{
+				"type":                 "object",
+				"required":             []string{"name"},
+				"additionalProperties": false,
+				"description":          "A repository and how each package manager installs packages from it",
+				"properties":           repositorySources,
+			},
+			"alternate": object{
+				"oneOf": []any{
+					pkgString,
+					object{
+						"type":                 "object",
+						"required":             []string{"name"},
+						"additionalProperties": false,
+						"properties": object{
+							"name":    object{"type": "string", "minLength": 1},
+							"version": version,
+							"kind":    kind,
+							"tap":     tap,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return json.MarshalIndent(schema, "", "  ")
+}
@@ -6,7 +6,7 @@ type Store interface {
 }
 
 var LoadConfiguration = func() (*Configuration, error) {
-	s, err := NewLocal(nil)
+	s, err := NewDefault()
 	if err != nil {
 		return nil, err
 	}
@@ -15,7 +15,7 @@ var LoadConfiguration = func() (*Configuration, error) {
 }
 
 var WriteConfiguration = func(cfg *Configuration) error {
-	s, err := NewLocal(nil)
+	s, err := NewDefault()
 	if err != nil {
 		return err
 	}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/drew-english/system-configurator/internal/model"
 	"github.com/drew-english/system-configurator/internal/store"
+	termio_stub "github.com/drew-english/system-configurator/spec/stub/termio"
 	"github.com/google/go-cmp/cmp/cmpopts"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -92,13 +93,13 @@ var _ = Describe("Local", func() {
 		})
 
 		Context("when no configuration is given", func() {
-			var original func() string
+			var original func() (string, error)
 			BeforeEach(func() {
 				cfgFixture = ""
 				cfg = nil
 				original = store.LocalDefaultLocation
-				store.LocalDefaultLocation = func() string {
-					return "./default-tmp/system-configurator"
+				store.LocalDefaultLocation = func() (string, error) {
+					return "./default-tmp/system-configurator", nil
 				}
 			})
 
@@ -130,6 +131,7 @@ var _ = Describe("Local", func() {
 			config, err := subject()
 			Expect(err).ToNot(HaveOccurred())
 			Expect(config).To(BeComparableTo(&store.Configuration{
+				Version: store.CurrentVersion,
 				Packages: []*model.Package{
 					{
 						Name:    "some-package",
@@ -142,7 +144,49 @@ var _ = Describe("Local", func() {
 						},
 					},
 				},
-			}, cmpopts.IgnoreUnexported(model.Package{})))
+			}, cmpopts.IgnoreUnexported(model.Package{}, store.Configuration{})))
+		})
+
+		Context("when the configuration is from a newer version", func() {
+			BeforeEach(func() {
+				cfgFixture = "version: 99\npackages: []\n"
+			})
+
+			It("refuses to load it", func() {
+				_, err := subject()
+				Expect(err).To(MatchError(ContainSubstring("configuration version 99 is newer than the latest supported version")))
+			})
+		})
+
+		Context("when the configuration is invalid", func() {
+			BeforeEach(func() {
+				cfgFixture = "packages:\n  - name: fzf\n    versoin: 1.0\n"
+			})
+
+			It("returns the validation errors", func() {
+				_, err := subject()
+				Expect(err).To(MatchError("invalid configuration `tmp/system-configurator/config.yaml`:\n3:5: unknown key `versoin`"))
+			})
+		})
+
+		Context("when an alternate is for an unknown package manager", func() {
+			BeforeEach(func() {
+				cfgFixture = "packages:\n  - name: fzf\n    alternates: {ap: fzf}\n"
+			})
+
+			It("loads the configuration and warns about the alternate", func() {
+				var (
+					loaded *store.Configuration
+					err    error
+				)
+				_, stderr := termio_stub.CaptureTermOut(func() {
+					loaded, err = subject()
+				})
+
+				Expect(err).ToNot(HaveOccurred())
+				Expect(loaded.Packages[0].Alternates).To(HaveKey("ap"))
+				Expect(stderr).To(Equal("level=WARN msg=\"unknown package manager `ap`\" source=tmp/system-configurator/config.yaml line=3 column=18\n"))
+			})
 		})
 
 		Context("when the config file was not loaded correctly", func() {
@@ -167,23 +211,27 @@ var _ = Describe("Local", func() {
 		})
 	})
 
-	Describe("LocalDefaultLocation", func() {
-		subject := func() string {
-			return store.LocalDefaultLocation()
-		}
-
-		It("returns the default location for the local config file", func() {
-			Expect(subject()).To(Equal(path.Join(os.Getenv("HOME"), ".config/system-configurator")))
-		})
-
-		Context("when the $HOME environment variable is not set", func() {
-			BeforeEach(func() {
-				os.Unsetenv("HOME")
-			})
-
-			It("panics", func() {
-				Expect(func() { subject() }).To(Panic())
-			})
-		})
-	})
+	DescribeTable("default locations",
+		func(location func() (string, error), env, homeDefault string) {
+			GinkgoT().Setenv(env, "")
+			GinkgoT().Setenv("HOME", "/home/user")
+			Expect(location()).To(Equal(path.Join("/home/user", homeDefault, "system-configurator")))
+
+			GinkgoT().Setenv(env, "/xdg")
+			Expect(location()).To(Equal("/xdg/system-configurator"))
+
+			By("ignoring relative base directories")
+			GinkgoT().Setenv(env, "relative")
+			Expect(location()).To(Equal(path.Join("/home/user", homeDefault, "system-configurator")))
+
+			By("returning an error without a home directory")
+			GinkgoT().Setenv(env, "")
+			GinkgoT().Setenv("HOME", "")
+			_, err := location()
+			Expect(err).To(MatchError(store.ErrNoHomeDir))
+		},
+		Entry("configuration", store.LocalDefaultLocation, "XDG_CONFIG_HOME", ".config"),
+		Entry("state", store.StateDefaultLocation, "XDG_STATE_HOME", ".local/state"),
+		Entry("cache", store.CacheDefaultLocation, "XDG_CACHE_HOME", ".cache"),
+	)
 })
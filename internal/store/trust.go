@@ -0,0 +1,87 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/drew-english/system-configurator/internal/signature"
+	"github.com/drew-english/system-configurator/pkg/logging"
+)
+
+const TrustedKeysFileName = "trusted_keys"
+
+// TrustedKeys returns the public keys configurations may be signed with, read from the trusted_keys
+// files in the system and user configuration directories.
+var TrustedKeys = func() ([]*signature.PublicKey, error) {
+	userLocation, err := LocalDefaultLocation()
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*signature.PublicKey
+	for _, location := range []string{SystemDefaultLocation(), userLocation} {
+		keysPath := filepath.Join(location, TrustedKeysFileName)
+		data, err := os.ReadFile(keysPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		fileKeys, err := signature.ParsePublicKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted keys `%s`: %w", keysPath, err)
+		}
+
+		keys = append(keys, fileKeys...)
+	}
+
+	return keys, nil
+}
+
+// UnsignedRemoteSources returns the remote sources of the configuration without a valid signature from a trusted key.
+func (c *Configuration) UnsignedRemoteSources() []Source {
+	if c.sources == nil {
+		return nil
+	}
+
+	var unsigned []Source
+	for _, f := range c.sources.files {
+		if f.layer == LayerRemote && !f.signed {
+			unsigned = append(unsigned, Source{Layer: f.layer, Path: f.path})
+		}
+	}
+
+	return unsigned
+}
+
+// verifyFile checks the detached signature next to a configuration file, returning whether it is signed.
+func verifyFile(path string, data []byte) (bool, error) {
+	sig, err := os.ReadFile(path + signature.Extension)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return verify(path, data, sig)
+}
+
+// verify checks a signature of a configuration, which is an error when it is not made by a trusted key.
+func verify(name string, data, sig []byte) (bool, error) {
+	keys, err := TrustedKeys()
+	if err != nil {
+		return false, err
+	}
+
+	key, err := signature.Verify(keys, data, sig)
+	if err != nil {
+		return false, fmt.Errorf("invalid signature for configuration `%s`: %w", name, err)
+	}
+
+	logging.Debug("verified configuration signature", "path", name, "key", key.String())
+	return true, nil
+}
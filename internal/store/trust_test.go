@@ -0,0 +1,84 @@
+package store_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/internal/signature"
+	"github.com/drew-english/system-configurator/internal/store"
+	store_stub "github.com/drew-english/system-configurator/spec/stub/store"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Trust", func() {
+	const content = "packages:\n  - fzf\n"
+
+	var (
+		systemDir, userDir, cfgPath string
+		key                         *signature.PrivateKey
+	)
+
+	load := func() (store.Store, *store.Configuration, error) {
+		s, err := store.NewLocal(&store.LocalCfg{Location: userDir})
+		Expect(err).ToNot(HaveOccurred())
+
+		cfg, err := s.LoadConfiguration()
+		return s, cfg, err
+	}
+
+	BeforeEach(func() {
+		root := GinkgoT().TempDir()
+		systemDir = filepath.Join(root, "etc")
+		userDir = filepath.Join(root, "home")
+		cfgPath = filepath.Join(userDir, "config.yml")
+		DeferCleanup(store_stub.StubLayers(systemDir, userDir, root))
+
+		var err error
+		key, err = signature.GenerateKey()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(os.MkdirAll(systemDir, 0755)).To(Succeed())
+		Expect(os.MkdirAll(userDir, 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(systemDir, store.TrustedKeysFileName), key.Public().Marshal(), 0644)).To(Succeed())
+		Expect(os.WriteFile(cfgPath, []byte(content), 0644)).To(Succeed())
+		Expect(os.WriteFile(cfgPath+signature.Extension, signature.Sign(key, []byte(content), "config.yml"), 0644)).To(Succeed())
+	})
+
+	It("reads the trusted keys of the system and user configuration directories", func() {
+		other, err := signature.GenerateKey()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(userDir, store.TrustedKeysFileName), other.Public().Marshal(), 0644)).To(Succeed())
+
+		Expect(store.TrustedKeys()).To(Equal([]*signature.PublicKey{key.Public(), other.Public()}))
+	})
+
+	It("loads a configuration signed by a trusted key", func() {
+		_, cfg, err := load()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.Packages).To(HaveLen(1))
+	})
+
+	It("refuses a configuration that does not match its signature", func() {
+		Expect(os.WriteFile(cfgPath, []byte("packages:\n  - jq\n"), 0644)).To(Succeed())
+
+		_, _, err := load()
+		Expect(err).To(MatchError(signature.ErrInvalidSignature))
+		Expect(err).To(MatchError(HavePrefix("invalid signature for configuration `" + cfgPath + "`")))
+	})
+
+	It("removes the signature when the configuration is modified", func() {
+		s, cfg, err := load()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.AddPackage(&model.Package{Name: "jq"})).To(Succeed())
+		Expect(s.WriteConfiguration(cfg)).To(Succeed())
+
+		_, err = os.Stat(cfgPath + signature.Extension)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+
+		_, _, err = load()
+		Expect(err).ToNot(HaveOccurred())
+	})
+})
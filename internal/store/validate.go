@@ -0,0 +1,387 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/pkg/sys/pkgmanager"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	configurationKeys = []string{"version", "include", "personal", "repositories", "packages"}
+	packageKeys       = []string{"name", "version", "kind", "tap", "repository", "requires", "alternates"}
+	alternateKeys     = []string{"name", "version", "kind", "tap"}
+	packageKinds      = []string{model.KindFormula, model.KindCask}
+
+	// Keys of the repository source for each package manager that supports repositories, the first is required.
+	repositorySourceKeys = map[string][]string{
+		"apk":    {"url", "key"},
+		"apt":    {"url", "suite", "components", "key"},
+		"brew":   {"tap", "url"},
+		"dnf":    {"url", "key"},
+		"pacman": {"url", "key"},
+	}
+
+	versionRegex    = regexp.MustCompile(`^[A-Za-z0-9*][A-Za-z0-9._+~:*-]*$`)
+	repositoryRegex = regexp.MustCompile(`^[A-Za-z0-9][\w.-]*$`)
+)
+
+type (
+	// ValidationError is a problem found at a position in a configuration document.
+	ValidationError struct {
+		Line    int
+		Column  int
+		Message string
+		Warning bool // the problem does not prevent the configuration from loading
+	}
+
+	ValidationErrors []*ValidationError
+
+	validator struct {
+		errs         ValidationErrors
+		packages     map[string]int // package name to the line it is first defined on
+		repositories map[string]int // repository name to the line it is first defined on
+	}
+)
+
+// Validate checks a configuration document for unknown keys, unknown package managers,
+// duplicate packages or repositories and malformed versions, returning ValidationErrors for any problems found.
+// Alternates for unknown package managers are only warnings, as they are never used rather than misread.
+// Documents from older schema versions are migrated before they are checked.
+func Validate(data []byte) error {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return err
+	}
+
+	if _, err := MigrateDocument(doc); err != nil {
+		return err
+	}
+
+	if errs := validateDocument(doc); len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func validateDocument(doc *yaml.Node) ValidationErrors {
+	root := documentRoot(doc)
+	if root == nil {
+		return nil
+	}
+
+	v := &validator{packages: make(map[string]int), repositories: make(map[string]int)}
+	v.configuration(root)
+	return v.errs
+}
+
+func (e *ValidationError) Error() string {
+	if e.Warning {
+		return fmt.Sprintf("%d:%d: warning: %s", e.Line, e.Column, e.Message)
+	}
+
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// Fatal returns the problems that prevent the configuration from loading.
+func (errs ValidationErrors) Fatal() ValidationErrors {
+	var fatal ValidationErrors
+	for _, err := range errs {
+		if !err.Warning {
+			fatal = append(fatal, err)
+		}
+	}
+
+	return fatal
+}
+
+// AsValidationErrors returns the validation errors wrapped by err, if any.
+func AsValidationErrors(err error) (ValidationErrors, bool) {
+	var errs ValidationErrors
+	ok := errors.As(err, &errs)
+	return errs, ok
+}
+
+func (v *validator) configuration(node *yaml.Node) {
+	if !v.expectKind(node, yaml.MappingNode, "configuration must be a mapping") {
+		return
+	}
+
+	v.mapping(node, configurationKeys, func(key string, value *yaml.Node) {
+		switch key {
+		case "include":
+			if !v.expectKind(value, yaml.SequenceNode, "`include` must be a list of paths") {
+				return
+			}
+
+			for _, include := range value.Content {
+				v.expectKind(include, yaml.ScalarNode, "included path must be a string")
+			}
+		case "personal":
+			v.expectKind(value, yaml.ScalarNode, "`personal` must be a path")
+		case "repositories":
+			if !v.expectKind(value, yaml.SequenceNode, "`repositories` must be a list") {
+				return
+			}
+
+			for _, repo := range value.Content {
+				v.repository(repo)
+			}
+		case "packages":
+			if !v.expectKind(value, yaml.SequenceNode, "`packages` must be a list") {
+				return
+			}
+
+			for _, pkg := range value.Content {
+				v.pkg(pkg)
+			}
+		}
+	})
+}
+
+func (v *validator) pkg(node *yaml.Node) {
+	name, nameNode := v.packageFields(node, packageKeys, func(key string, value *yaml.Node) {
+		switch key {
+		case "repository":
+			v.expectKind(value, yaml.ScalarNode, "`repository` must be the name of a repository")
+			return
+		case "requires":
+			v.requires(value)
+			return
+		}
+
+		if key != "alternates" || !v.expectKind(value, yaml.MappingNode, "`alternates` must be a mapping of package manager to package") {
+			return
+		}
+
+		v.mapping(value, nil, func(managerName string, alternate *yaml.Node) {
+			v.packageFields(alternate, alternateKeys, nil)
+		})
+	})
+
+	if nameNode == nil {
+		return
+	}
+
+	if line, ok := v.packages[name]; ok {
+		v.add(nameNode, "duplicate package `%s`, first defined on line %d", name, line)
+		return
+	}
+
+	v.packages[name] = nameNode.Line
+}
+
+// repository validates a repository, given as a mapping of its name and the source for each package manager.
+func (v *validator) repository(node *yaml.Node) {
+	if !v.expectKind(node, yaml.MappingNode, "repository must be a mapping") {
+		return
+	}
+
+	var name *yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Value == "name" {
+			if v.expectKind(value, yaml.ScalarNode, "`name` must be a string") {
+				name = value
+			}
+
+			continue
+		}
+
+		if key.Value == "requires" {
+			v.requires(value)
+			continue
+		}
+
+		if _, ok := pkgmanager.Managers[key.Value]; !ok {
+			v.add(key, "unknown key `%s`", key.Value)
+			continue
+		}
+
+		keys, ok := repositorySourceKeys[key.Value]
+		if !ok {
+			v.add(key, "%s does not support repositories", key.Value)
+			continue
+		}
+
+		v.repositorySource(key.Value, keys, value)
+	}
+
+	if name == nil {
+		v.add(node, "repository is missing a `name`")
+		return
+	}
+
+	if !repositoryRegex.MatchString(name.Value) {
+		v.add(name, "malformed repository name `%s`, expected letters, digits, `.`, `_` or `-`", name.Value)
+		return
+	}
+
+	if line, ok := v.repositories[name.Value]; ok {
+		v.add(name, "duplicate repository `%s`, first defined on line %d", name.Value, line)
+		return
+	}
+
+	v.repositories[name.Value] = name.Line
+}
+
+func (v *validator) repositorySource(managerName string, keys []string, node *yaml.Node) {
+	if !v.expectKind(node, yaml.MappingNode, "repository source must be a mapping") {
+		return
+	}
+
+	found := false
+	v.mapping(node, keys, func(key string, value *yaml.Node) {
+		switch {
+		case key == "components":
+			if !v.expectKind(value, yaml.SequenceNode, "`components` must be a list") {
+				return
+			}
+
+			for _, component := range value.Content {
+				v.expectKind(component, yaml.ScalarNode, "component must be a string")
+			}
+		case v.expectKind(value, yaml.ScalarNode, fmt.Sprintf("`%s` must be a string", key)):
+			if key == "tap" && !model.TapRegex.MatchString(value.Value) {
+				v.add(value, "malformed tap `%s`, expected <user>/<repository>", value.Value)
+			}
+
+			found = found || key == keys[0]
+		}
+	})
+
+	if !found {
+		v.add(node, "%s repository is missing a `%s`", managerName, keys[0])
+	}
+}
+
+// requires validates a list of requirements, given as [<kind>:]<name>. Whether the required items exist is only
+// known once every file of the configuration is merged, so it is checked when the configuration is ordered.
+func (v *validator) requires(node *yaml.Node) {
+	if !v.expectKind(node, yaml.SequenceNode, "`requires` must be a list of packages and repositories") {
+		return
+	}
+
+	for _, requirement := range node.Content {
+		if !v.expectKind(requirement, yaml.ScalarNode, "requirement must be a string") {
+			continue
+		}
+
+		if _, err := model.ParseRequirement(requirement.Value); err != nil {
+			v.add(requirement, "%v", err)
+		}
+	}
+}
+
+// packageFields validates a package given as a string or mapping, calling extra for keys other than
+// name, version, kind and tap. Returns the package name and the node holding it, which is nil if there is none.
+func (v *validator) packageFields(node *yaml.Node, keys []string, extra func(key string, value *yaml.Node)) (string, *yaml.Node) {
+	if node.Kind == yaml.ScalarNode {
+		pkg, err := model.ParsePackage(node.Value)
+		if err != nil {
+			v.add(node, "invalid package `%s`, expected <name>[@<version>]", node.Value)
+			return "", nil
+		}
+
+		v.version(node, pkg.Version)
+		return pkg.Name, node
+	}
+
+	if !v.expectKind(node, yaml.MappingNode, "package must be a string or mapping") {
+		return "", nil
+	}
+
+	var name *yaml.Node
+	v.mapping(node, keys, func(key string, value *yaml.Node) {
+		switch key {
+		case "name":
+			if v.expectKind(value, yaml.ScalarNode, "`name` must be a string") && value.Value != "" {
+				name = value
+			}
+		case "version":
+			if v.expectKind(value, yaml.ScalarNode, "`version` must be a string") {
+				v.version(value, value.Value)
+			}
+		case "kind":
+			if v.expectKind(value, yaml.ScalarNode, "`kind` must be a string") && !slices.Contains(packageKinds, value.Value) {
+				v.add(value, "unknown package kind `%s`, expected %s", value.Value, strings.Join(packageKinds, " or "))
+			}
+		case "tap":
+			if v.expectKind(value, yaml.ScalarNode, "`tap` must be a string") && !model.TapRegex.MatchString(value.Value) {
+				v.add(value, "malformed tap `%s`, expected <user>/<repository>", value.Value)
+			}
+		default:
+			if extra != nil {
+				extra(key, value)
+			}
+		}
+	})
+
+	if name == nil {
+		v.add(node, "package is missing a `name`")
+		return "", nil
+	}
+
+	return name.Value, name
+}
+
+// mapping calls fn for every key of node, reporting keys that are not in known.
+// When known is nil, keys should instead name a supported package manager, and other keys are warned about.
+func (v *validator) mapping(node *yaml.Node, known []string, fn func(key string, value *yaml.Node)) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if known == nil {
+			if _, ok := pkgmanager.Managers[key.Value]; !ok {
+				v.warn(key, "unknown package manager `%s`", key.Value)
+			}
+		} else if !slices.Contains(known, key.Value) {
+			v.add(key, "unknown key `%s`", key.Value)
+			continue
+		}
+
+		fn(key.Value, value)
+	}
+}
+
+func (v *validator) version(node *yaml.Node, version string) {
+	if version != "" && !versionRegex.MatchString(version) {
+		v.add(node, "malformed version `%s`", version)
+	}
+}
+
+func (v *validator) expectKind(node *yaml.Node, kind yaml.Kind, msg string) bool {
+	if node.Kind != kind {
+		v.add(node, "%s", msg)
+		return false
+	}
+
+	return true
+}
+
+func (v *validator) add(node *yaml.Node, format string, a ...any) {
+	v.errs = append(v.errs, &ValidationError{
+		Line:    node.Line,
+		Column:  node.Column,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+func (v *validator) warn(node *yaml.Node, format string, a ...any) {
+	v.add(node, format, a...)
+	v.errs[len(v.errs)-1].Warning = true
+}
@@ -0,0 +1,173 @@
+package store_test
+
+import (
+	"encoding/json"
+
+	"github.com/drew-english/system-configurator/internal/store"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Validate", func() {
+	subject := func(doc string) store.ValidationErrors {
+		err := store.Validate([]byte(doc))
+		if err == nil {
+			return nil
+		}
+
+		errs, ok := store.AsValidationErrors(err)
+		Expect(ok).To(BeTrue(), "expected validation errors, got %v", err)
+		return errs
+	}
+
+	It("accepts a valid configuration", func() {
+		Expect(subject(`
+repositories:
+  - name: docker
+    apt:
+      url: https://download.docker.com/linux/ubuntu
+      suite: noble
+      components: [stable]
+      key: https://download.docker.com/linux/ubuntu/gpg
+    dnf: {url: https://download.docker.com/linux/fedora/$releasever/$basearch/stable}
+    brew: {tap: homebrew/cask}
+    requires: [gnupg]
+packages:
+  - name: docker-ce
+    repository: docker
+  - name: docker-compose-plugin
+    requires: [docker-ce, package:gnupg, repository:docker]
+  - fzf
+  - ripgrep@14.1.0
+  - name: bat
+    version: 0.24.0
+    alternates:
+      apt: batcat@0.24.0-1
+      brew:
+        name: bat
+  - name: firefox
+    kind: cask
+  - name: font-fira-code
+    kind: cask
+    tap: homebrew/cask-fonts
+`)).To(BeEmpty())
+	})
+
+	It("accepts an empty document", func() {
+		Expect(subject("")).To(BeEmpty())
+		Expect(subject("{}")).To(BeEmpty())
+	})
+
+	It("reports every problem with its position", func() {
+		Expect(subject(`packages:
+  - name: bat
+    alternates: {ap: foo}
+    versoin: 1.0
+  - bat@1.0
+  - name: fzf
+    version: "1.2 beta"
+  - version: 1.0
+extra: true
+`)).To(Equal(store.ValidationErrors{
+			{Line: 3, Column: 18, Message: "unknown package manager `ap`", Warning: true},
+			{Line: 4, Column: 5, Message: "unknown key `versoin`"},
+			{Line: 5, Column: 5, Message: "duplicate package `bat`, first defined on line 2"},
+			{Line: 7, Column: 14, Message: "malformed version `1.2 beta`"},
+			{Line: 8, Column: 5, Message: "package is missing a `name`"},
+			{Line: 9, Column: 1, Message: "unknown key `extra`"},
+		}))
+	})
+
+	It("reports unknown package kinds and malformed taps", func() {
+		Expect(subject(`packages:
+  - name: firefox
+    kind: app
+  - name: terraform
+    alternates:
+      brew: {name: terraform, tap: hashicorp}
+`)).To(Equal(store.ValidationErrors{
+			{Line: 3, Column: 11, Message: "unknown package kind `app`, expected formula or cask"},
+			{Line: 6, Column: 36, Message: "malformed tap `hashicorp`, expected <user>/<repository>"},
+		}))
+	})
+
+	It("reports malformed repositories", func() {
+		Expect(subject(`repositories:
+  - name: docker
+    apt: {suite: noble}
+    snap: {url: https://example.com}
+    mirror: true
+  - name: docker
+    brew: {tap: hashicorp}
+  - name: "../etc"
+  - apk: {url: https://example.com, key: [a]}
+`)).To(Equal(store.ValidationErrors{
+			{Line: 3, Column: 10, Message: "apt repository is missing a `url`"},
+			{Line: 4, Column: 5, Message: "snap does not support repositories"},
+			{Line: 5, Column: 5, Message: "unknown key `mirror`"},
+			{Line: 7, Column: 17, Message: "malformed tap `hashicorp`, expected <user>/<repository>"},
+			{Line: 6, Column: 11, Message: "duplicate repository `docker`, first defined on line 2"},
+			{Line: 8, Column: 11, Message: "malformed repository name `../etc`, expected letters, digits, `.`, `_` or `-`"},
+			{Line: 9, Column: 42, Message: "`key` must be a string"},
+			{Line: 9, Column: 5, Message: "repository is missing a `name`"},
+		}))
+	})
+
+	It("reports malformed requirements", func() {
+		Expect(subject(`repositories:
+  - name: docker
+    requires: gnupg
+packages:
+  - name: docker-ce
+    requires: [script:install-docker, "package:", [a]]
+`)).To(Equal(store.ValidationErrors{
+			{Line: 3, Column: 15, Message: "`requires` must be a list of packages and repositories"},
+			{Line: 6, Column: 16, Message: "unknown kind `script` in requirement `script:install-docker`, expected package or repository"},
+			{Line: 6, Column: 39, Message: "invalid requirement `package:`, expected [<kind>:]<name>"},
+			{Line: 6, Column: 51, Message: "requirement must be a string"},
+		}))
+	})
+
+	It("reports values of the wrong type", func() {
+		Expect(subject("packages: fzf")).To(Equal(store.ValidationErrors{
+			{Line: 1, Column: 11, Message: "`packages` must be a list"},
+		}))
+	})
+
+	It("reports alternates for unknown package managers as warnings", func() {
+		errs := subject("packages:\n  - name: bat\n    alternates: {flatpak: batcat}\n")
+		Expect(errs).To(MatchError("3:18: warning: unknown package manager `flatpak`"))
+		Expect(errs.Fatal()).To(BeEmpty())
+	})
+
+	It("formats errors as line:column: message", func() {
+		err := store.Validate([]byte("extra: true"))
+		Expect(err).To(MatchError("1:1: unknown key `extra`"))
+	})
+
+	It("returns syntax errors as is", func() {
+		err := store.Validate([]byte("packages:\n  - fzf\n bad"))
+		Expect(err).To(HaveOccurred())
+		_, ok := store.AsValidationErrors(err)
+		Expect(ok).To(BeFalse())
+	})
+})
+
+var _ = Describe("Schema", func() {
+	It("returns a JSON Schema listing the supported package managers", func() {
+		data, err := store.Schema()
+		Expect(err).ToNot(HaveOccurred())
+
+		schema := map[string]any{}
+		Expect(json.Unmarshal(data, &schema)).To(Succeed())
+		Expect(schema).To(HaveKeyWithValue("$schema", "https://json-schema.org/draft/2020-12/schema"))
+
+		pkg := schema["$defs"].(map[string]any)["package"].(map[string]any)
+		pkgObject := pkg["oneOf"].([]any)[1].(map[string]any)
+		alternates := pkgObject["properties"].(map[string]any)["alternates"].(map[string]any)
+		Expect(alternates["propertyNames"]).To(Equal(map[string]any{
+			"enum": []any{"apk", "apt", "brew", "dnf", "pacman", "snap"},
+		}))
+	})
+})
@@ -0,0 +1,545 @@
+// Applies package changes to the system and configuration while journaling each
+// completed step, so that a failed or unwanted operation can be reversed.
+package transaction
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/internal/secret"
+	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/pkg/logging"
+	"github.com/drew-english/system-configurator/pkg/sys/pkgmanager"
+)
+
+const (
+	StepInstall      = StepKind("install")
+	StepUninstall    = StepKind("uninstall")
+	StepConfigAdd    = StepKind("config-add")
+	StepConfigRemove = StepKind("config-remove")
+
+	StepAddRepository    = StepKind("add-repository")
+	StepRemoveRepository = StepKind("remove-repository")
+
+	StepConfigAddRepository    = StepKind("config-add-repository")
+	StepConfigRemoveRepository = StepKind("config-remove-repository")
+
+	journalFileName    = "transaction.json"
+	unfinishedFileName = "unfinished.json"
+)
+
+type (
+	StepKind string
+
+	// Step is a single change that was applied to a package or repository, with enough detail to reverse it.
+	Step struct {
+		Kind       StepKind          `json:"kind"`
+		Manager    string            `json:"manager,omitempty"`
+		Package    *model.Package    `json:"package,omitempty"`
+		Repository *model.Repository `json:"repository,omitempty"`
+	}
+
+	Journal struct {
+		Steps  []Step `json:"steps"`
+		Undoes int    `json:"undoes,omitempty"` // ID of the history entry whose changes are left to undo
+	}
+
+	Transaction struct {
+		Journal
+
+		manager   pkgmanager.PacakgeManager
+		cfg       *store.Configuration
+		sysPkgs   []*model.Package
+		installed map[string]*model.Package
+	}
+)
+
+// JournalPath is where the journal of the latest transaction is kept.
+var JournalPath = func() (string, error) {
+	location, err := store.StateDefaultLocation()
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(location, journalFileName), nil
+}
+
+// UnfinishedPath is where the changes left to undo of partially undone operations are kept.
+var UnfinishedPath = func() (string, error) {
+	location, err := store.StateDefaultLocation()
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(location, unfinishedFileName), nil
+}
+
+// Secrets provides the resolver of the secrets referenced by repositories as `{{ secret "name" }}`.
+// Provides a hook for testing
+var Secrets = secret.Default
+
+// Begin starts a transaction. A nil manager or configuration means that side is not modified.
+// When a manager is given, the installed packages are read so that only packages which
+// were not already present are uninstalled on rollback.
+func Begin(ctx context.Context, manager pkgmanager.PacakgeManager, cfg *store.Configuration) (*Transaction, error) {
+	tx := &Transaction{
+		manager:   manager,
+		cfg:       cfg,
+		installed: make(map[string]*model.Package),
+	}
+
+	if manager != nil {
+		pkgs, err := manager.ListPackages(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		tx.sysPkgs = pkgs
+		for _, pkg := range pkgs {
+			tx.installed[pkg.Name] = pkg
+		}
+	}
+
+	return tx, nil
+}
+
+// Installs reports whether any package was installed by the transaction.
+func (tx *Transaction) Installs() bool {
+	for _, step := range tx.Steps {
+		if step.Kind == StepInstall {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SystemPackages returns the packages that were installed when the transaction began.
+func (tx *Transaction) SystemPackages() []*model.Package {
+	return tx.sysPkgs
+}
+
+// AddPackage installs pkg and adds it to the configuration, for each side managed by the transaction.
+func (tx *Transaction) AddPackage(ctx context.Context, pkg *model.Package) error {
+	if tx.manager != nil {
+		if err := tx.Install(ctx, pkg); err != nil {
+			return err
+		}
+	}
+
+	if tx.cfg != nil {
+		if err := tx.AddToConfig(pkg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemovePackage uninstalls a package and removes it from the configuration, for each side managed by the transaction.
+func (tx *Transaction) RemovePackage(ctx context.Context, name string) error {
+	if tx.manager != nil {
+		if err := tx.Uninstall(ctx, name); err != nil {
+			return err
+		}
+	}
+
+	if tx.cfg != nil {
+		if err := tx.RemoveFromConfig(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (tx *Transaction) Install(ctx context.Context, pkg *model.Package) error {
+	if err := tx.manager.AddPackage(ctx, pkg); err != nil {
+		return err
+	}
+
+	if _, ok := tx.installed[pkg.Name]; ok {
+		return nil
+	}
+
+	tx.installed[pkg.Name] = pkg
+	tx.record(Step{Kind: StepInstall, Manager: tx.manager.Name(), Package: pkg})
+	return nil
+}
+
+func (tx *Transaction) Uninstall(ctx context.Context, name string) error {
+	if err := tx.manager.RemovePackage(ctx, name); err != nil {
+		return err
+	}
+
+	pkg, ok := tx.installed[name]
+	if !ok {
+		return nil
+	}
+
+	delete(tx.installed, name)
+	tx.record(Step{Kind: StepUninstall, Manager: tx.manager.Name(), Package: pkg})
+	return nil
+}
+
+// AddRepository configures repo for the manager, journaling it when it was not already configured.
+func (tx *Transaction) AddRepository(ctx context.Context, repo *model.Repository) (bool, error) {
+	resolved, err := resolveSecrets(ctx, tx.manager.Name(), repo)
+	if err != nil {
+		return false, err
+	}
+
+	added, err := pkgmanager.AddRepository(ctx, tx.manager, resolved)
+	if err != nil || !added {
+		return added, err
+	}
+
+	tx.record(Step{Kind: StepAddRepository, Manager: tx.manager.Name(), Repository: repo})
+	return true, nil
+}
+
+func (tx *Transaction) RemoveRepository(ctx context.Context, repo *model.Repository) error {
+	resolved, err := resolveSecrets(ctx, tx.manager.Name(), repo)
+	if err != nil {
+		return err
+	}
+
+	if err := pkgmanager.RemoveRepository(ctx, tx.manager, resolved); err != nil {
+		return err
+	}
+
+	tx.record(Step{Kind: StepRemoveRepository, Manager: tx.manager.Name(), Repository: repo})
+	return nil
+}
+
+func (tx *Transaction) AddToConfig(pkg *model.Package) error {
+	if err := tx.cfg.AddPackage(pkg); err != nil {
+		return err
+	}
+
+	tx.record(Step{Kind: StepConfigAdd, Package: pkg})
+	return nil
+}
+
+func (tx *Transaction) RemoveFromConfig(name string) error {
+	pkg, _ := tx.cfg.FindPackage(name)
+	if err := tx.cfg.RemovePackage(name); err != nil {
+		return err
+	}
+
+	tx.record(Step{Kind: StepConfigRemove, Package: pkg})
+	return nil
+}
+
+// AddRepositoryToConfig adds a repository to the configuration.
+func (tx *Transaction) AddRepositoryToConfig(repo *model.Repository) error {
+	if err := tx.cfg.AddRepository(repo); err != nil {
+		return err
+	}
+
+	tx.record(Step{Kind: StepConfigAddRepository, Repository: repo})
+	return nil
+}
+
+func (tx *Transaction) RemoveRepositoryFromConfig(name string) error {
+	repo := tx.cfg.FindRepository(name)
+	if err := tx.cfg.RemoveRepository(name); err != nil {
+		return err
+	}
+
+	tx.record(Step{Kind: StepConfigRemoveRepository, Repository: repo})
+	return nil
+}
+
+// Rollback reverses the completed steps in the opposite order they were applied.
+// Steps that could not be reversed are kept in the journal.
+func (tx *Transaction) Rollback(ctx context.Context) error {
+	remaining, err := Revert(ctx, tx.Steps, tx.cfg)
+	tx.Steps = remaining
+	if len(remaining) == 0 {
+		return errors.Join(err, Discard())
+	}
+
+	return errors.Join(err, tx.Save())
+}
+
+// Revert reverses steps in the opposite order they were applied, returning the steps that failed.
+// Configuration steps are reversed in cfg, which the caller is responsible for writing.
+func Revert(ctx context.Context, steps []Step, cfg *store.Configuration) ([]Step, error) {
+	var (
+		remaining []Step
+		errs      []error
+	)
+
+	for i := len(steps) - 1; i >= 0; i-- {
+		if err := revertStep(ctx, steps[i], cfg); err != nil {
+			remaining = append([]Step{steps[i]}, remaining...)
+			errs = append(errs, fmt.Errorf("Failed to revert %s of `%s`: %w", steps[i].Kind, steps[i].Subject(), err))
+		}
+	}
+
+	return remaining, errors.Join(errs...)
+}
+
+// LoadJournal reads the journal of the latest transaction, returning nil if there is none.
+func LoadJournal() (*Journal, error) {
+	journalPath, err := JournalPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(journalPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	journal := &Journal{}
+	if err := json.Unmarshal(data, journal); err != nil {
+		return nil, fmt.Errorf("invalid transaction journal: %w", err)
+	}
+
+	return journal, nil
+}
+
+// LoadUnfinished reads the changes left to undo of each partially undone operation, the most recently saved last.
+func LoadUnfinished() ([]*Journal, error) {
+	unfinishedPath, err := UnfinishedPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(unfinishedPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var journals []*Journal
+	if err := json.Unmarshal(data, &journals); err != nil {
+		return nil, fmt.Errorf("invalid unfinished operations: %w", err)
+	}
+
+	return journals, nil
+}
+
+// FindUnfinished returns the changes left to undo of the history entry with the given ID, or nil if there are none.
+func FindUnfinished(id int) (*Journal, error) {
+	journals, err := LoadUnfinished()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, journal := range journals {
+		if journal.Undoes == id {
+			return journal, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ModifiesConfig reports whether any step changed the configuration.
+func (j *Journal) ModifiesConfig() bool {
+	for _, step := range j.Steps {
+		switch step.Kind {
+		case StepConfigAdd, StepConfigRemove, StepConfigAddRepository, StepConfigRemoveRepository:
+			return true
+		}
+	}
+
+	return false
+}
+
+// ModifiesSystem reports whether any step changed the installed packages or configured repositories.
+func (j *Journal) ModifiesSystem() bool {
+	for _, step := range j.Steps {
+		switch step.Kind {
+		case StepInstall, StepUninstall, StepAddRepository, StepRemoveRepository:
+			return true
+		}
+	}
+
+	return false
+}
+
+// Subject returns the name of the package or repository the step changed.
+func (s Step) Subject() string {
+	if s.Repository != nil {
+		return s.Repository.Name
+	}
+
+	return s.Package.String()
+}
+
+// Save replaces the journal of the latest transaction.
+func (j *Journal) Save() error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	journalPath, err := JournalPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(path.Dir(journalPath), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(journalPath, data, 0644)
+}
+
+// SaveUnfinished records the steps of the journal as the changes left to undo of the history entry it undoes,
+// replacing those recorded before. A journal without steps removes them.
+func (j *Journal) SaveUnfinished() error {
+	journals, err := LoadUnfinished()
+	if err != nil {
+		return err
+	}
+
+	journals = slices.DeleteFunc(journals, func(other *Journal) bool { return other.Undoes == j.Undoes })
+	if len(j.Steps) > 0 {
+		journals = append(journals, j)
+	}
+
+	unfinishedPath, err := UnfinishedPath()
+	if err != nil {
+		return err
+	}
+
+	if len(journals) == 0 {
+		if err := os.Remove(unfinishedPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		return nil
+	}
+
+	data, err := json.MarshalIndent(journals, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(path.Dir(unfinishedPath), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(unfinishedPath, data, 0644)
+}
+
+// Discard removes the journal of the latest transaction.
+func Discard() error {
+	journalPath, err := JournalPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(journalPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func revertStep(ctx context.Context, step Step, cfg *store.Configuration) error {
+	switch step.Kind {
+	case StepInstall, StepUninstall:
+		manager, ok := pkgmanager.Managers[step.Manager]
+		if !ok {
+			return fmt.Errorf("unknown package manager `%s`", step.Manager)
+		}
+
+		if step.Kind == StepInstall {
+			return manager.RemovePackage(ctx, step.Package.Name)
+		}
+
+		return manager.AddPackage(ctx, step.Package)
+	case StepAddRepository, StepRemoveRepository:
+		manager, ok := pkgmanager.Managers[step.Manager]
+		if !ok {
+			return fmt.Errorf("unknown package manager `%s`", step.Manager)
+		}
+
+		repo, err := resolveSecrets(ctx, step.Manager, step.Repository)
+		if err != nil {
+			return err
+		}
+
+		if step.Kind == StepAddRepository {
+			return pkgmanager.RemoveRepository(ctx, manager, repo)
+		}
+
+		_, err = pkgmanager.AddRepository(ctx, manager, repo)
+		return err
+	case StepConfigAdd, StepConfigRemove:
+		if cfg == nil {
+			return errors.New("configuration is not loaded")
+		}
+
+		if step.Kind == StepConfigAdd {
+			return cfg.RemovePackage(step.Package.Name)
+		}
+
+		return cfg.AddPackage(step.Package)
+	case StepConfigAddRepository, StepConfigRemoveRepository:
+		if cfg == nil {
+			return errors.New("configuration is not loaded")
+		}
+
+		if step.Kind == StepConfigAddRepository {
+			return cfg.RemoveRepository(step.Repository.Name)
+		}
+
+		return cfg.AddRepository(step.Repository)
+	}
+
+	return fmt.Errorf("unknown step `%s`", step.Kind)
+}
+
+// resolveSecrets returns repo with the secrets referenced by its source for the manager replaced by their values.
+// The values are only passed to the package manager, steps are journaled with the references.
+func resolveSecrets(ctx context.Context, managerName string, repo *model.Repository) (*model.Repository, error) {
+	src := repo.For(managerName)
+	if src == nil || (!strings.Contains(src.URL, "{{") && !strings.Contains(src.Key, "{{")) {
+		return repo, nil
+	}
+
+	resolver, err := Secrets()
+	if err != nil {
+		return nil, fmt.Errorf("unable to locate secrets: %w", err)
+	}
+
+	resolved := *src
+	if resolved.URL, err = resolver.Render(ctx, repo.Name+" url", src.URL); err != nil {
+		return nil, err
+	}
+
+	if resolved.Key, err = resolver.Render(ctx, repo.Name+" key", src.Key); err != nil {
+		return nil, err
+	}
+
+	return &model.Repository{
+		Name:     repo.Name,
+		Requires: repo.Requires,
+		Sources:  map[string]*model.RepositorySource{managerName: &resolved},
+	}, nil
+}
+
+// record appends a step and saves the journal, so it is available even if scfg exits unexpectedly.
+func (tx *Transaction) record(step Step) {
+	tx.Steps = append(tx.Steps, step)
+	if err := tx.Save(); err != nil {
+		logging.Warn("unable to save transaction journal", "error", err)
+	}
+}
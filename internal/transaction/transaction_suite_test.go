@@ -0,0 +1,19 @@
+package transaction_test
+
+import (
+	"testing"
+
+	"github.com/drew-english/system-configurator/spec/stub/store"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestTransaction(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Transaction Suite")
+}
+
+var _ = BeforeEach(func() {
+	DeferCleanup(store.StubStateLocation(GinkgoT().TempDir()))
+})
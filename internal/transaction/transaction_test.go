@@ -0,0 +1,176 @@
+package transaction_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/internal/store"
+	"github.com/drew-english/system-configurator/internal/transaction"
+	"github.com/drew-english/system-configurator/pkg/sys/pkgmanager"
+	"github.com/drew-english/system-configurator/spec/stub/run"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transaction", func() {
+	var (
+		ctx              = context.Background()
+		commandStubs     *run.CommandStubManager
+		teardownCmdStubs func(testing.TB)
+		manager          pkgmanager.PacakgeManager
+		cfg              *store.Configuration
+		tx               *transaction.Transaction
+	)
+
+	BeforeEach(func() {
+		commandStubs, teardownCmdStubs = run.StubCommand()
+		manager = pkgmanager.Managers["apt"]
+		cfg = &store.Configuration{
+			Packages: []*model.Package{{Name: "some-package", Version: "1.2.3"}},
+		}
+	})
+
+	JustBeforeEach(func() {
+		commandStubs.Register("apt list --installed", "some-package/now 1.2.3")
+
+		var err error
+		tx, err = transaction.Begin(ctx, manager, cfg)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		teardownCmdStubs(GinkgoTB())
+	})
+
+	Describe("Begin", func() {
+		It("reads the installed system packages", func() {
+			Expect(tx.SystemPackages()).To(Equal([]*model.Package{{Name: "some-package", Version: "1.2.3"}}))
+		})
+	})
+
+	Describe("AddPackage", func() {
+		It("journals the installation and configuration change", func() {
+			commandStubs.Register("apt install -y new-package", "")
+			Expect(tx.AddPackage(ctx, &model.Package{Name: "new-package"})).To(Succeed())
+			Expect(tx.Steps).To(Equal([]transaction.Step{
+				{Kind: transaction.StepInstall, Manager: "apt", Package: &model.Package{Name: "new-package"}},
+				{Kind: transaction.StepConfigAdd, Package: &model.Package{Name: "new-package"}},
+			}))
+
+			journal, err := transaction.LoadJournal()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(journal.Steps).To(Equal(tx.Steps))
+		})
+
+		Context("when the package was already installed", func() {
+			BeforeEach(func() {
+				cfg.Packages = nil
+			})
+
+			It("does not journal the installation", func() {
+				commandStubs.Register("apt install -y some-package", "")
+				Expect(tx.AddPackage(ctx, &model.Package{Name: "some-package"})).To(Succeed())
+				Expect(tx.Steps).To(HaveLen(1))
+				Expect(tx.Steps[0].Kind).To(Equal(transaction.StepConfigAdd))
+			})
+		})
+
+		Context("when the installation fails", func() {
+			It("does not journal or modify the configuration", func() {
+				commandStubs.RegisterError("apt install -y new-package", 1, "not found")
+				Expect(tx.AddPackage(ctx, &model.Package{Name: "new-package"})).ToNot(Succeed())
+				Expect(tx.Steps).To(BeEmpty())
+				Expect(cfg.Packages).To(HaveLen(1))
+			})
+		})
+	})
+
+	Describe("RemovePackage", func() {
+		It("journals the installed version and configured package", func() {
+			commandStubs.Register("apt remove some-package", "")
+			Expect(tx.RemovePackage(ctx, "some-package")).To(Succeed())
+			Expect(tx.Steps).To(Equal([]transaction.Step{
+				{Kind: transaction.StepUninstall, Manager: "apt", Package: &model.Package{Name: "some-package", Version: "1.2.3"}},
+				{Kind: transaction.StepConfigRemove, Package: &model.Package{Name: "some-package", Version: "1.2.3"}},
+			}))
+			Expect(cfg.Packages).To(BeEmpty())
+		})
+	})
+
+	Describe("AddRepositoryToConfig", func() {
+		It("journals the repository and reverses it on rollback", func() {
+			repo := &model.Repository{Name: "hashicorp-tap", Sources: map[string]*model.RepositorySource{"brew": {Tap: "hashicorp/tap"}}}
+			Expect(tx.AddRepositoryToConfig(repo)).To(Succeed())
+			Expect(tx.Steps).To(Equal([]transaction.Step{{Kind: transaction.StepConfigAddRepository, Repository: repo}}))
+			Expect(tx.ModifiesConfig()).To(BeTrue())
+			Expect(cfg.Repositories).To(Equal([]*model.Repository{repo}))
+
+			Expect(tx.Rollback(ctx)).To(Succeed())
+			Expect(cfg.Repositories).To(BeEmpty())
+		})
+	})
+
+	Describe("Rollback", func() {
+		It("reverses the completed steps and discards the journal", func() {
+			commandStubs.Register("apt install -y new-package", "")
+			commandStubs.Register("apt remove new-package", "")
+			Expect(tx.AddPackage(ctx, &model.Package{Name: "new-package"})).To(Succeed())
+
+			Expect(tx.Rollback(ctx)).To(Succeed())
+			Expect(tx.Steps).To(BeEmpty())
+			Expect(cfg.Packages).To(Equal([]*model.Package{{Name: "some-package", Version: "1.2.3"}}))
+
+			journal, err := transaction.LoadJournal()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(journal).To(BeNil())
+		})
+
+		Context("when a step cannot be reversed", func() {
+			It("keeps the step in the journal", func() {
+				commandStubs.Register("apt install -y new-package", "")
+				commandStubs.RegisterError("apt remove new-package", 1, "locked")
+				Expect(tx.AddPackage(ctx, &model.Package{Name: "new-package"})).To(Succeed())
+
+				Expect(tx.Rollback(ctx)).To(MatchError(ContainSubstring("Failed to revert install of `new-package`")))
+				Expect(tx.Steps).To(HaveLen(1))
+
+				journal, err := transaction.LoadJournal()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(journal.Steps).To(Equal(tx.Steps))
+			})
+		})
+	})
+
+	Describe("SaveUnfinished", func() {
+		var steps = []transaction.Step{{Kind: transaction.StepConfigAdd, Package: &model.Package{Name: "fzf"}}}
+
+		It("keeps the changes left of each operation apart from the journal", func() {
+			Expect((&transaction.Journal{Steps: steps, Undoes: 1}).SaveUnfinished()).To(Succeed())
+			Expect((&transaction.Journal{Steps: steps, Undoes: 2}).SaveUnfinished()).To(Succeed())
+			Expect(transaction.Discard()).To(Succeed())
+
+			Expect(transaction.FindUnfinished(1)).To(Equal(&transaction.Journal{Steps: steps, Undoes: 1}))
+			Expect(transaction.LoadUnfinished()).To(HaveLen(2))
+		})
+
+		It("replaces the changes left of the operation, most recent last", func() {
+			Expect((&transaction.Journal{Steps: steps, Undoes: 1}).SaveUnfinished()).To(Succeed())
+			Expect((&transaction.Journal{Steps: steps, Undoes: 2}).SaveUnfinished()).To(Succeed())
+			Expect((&transaction.Journal{Steps: steps, Undoes: 1}).SaveUnfinished()).To(Succeed())
+
+			unfinished, err := transaction.LoadUnfinished()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(unfinished).To(HaveLen(2))
+			Expect(unfinished[1].Undoes).To(Equal(1))
+		})
+
+		It("removes them when no changes are left", func() {
+			Expect((&transaction.Journal{Steps: steps, Undoes: 1}).SaveUnfinished()).To(Succeed())
+			Expect((&transaction.Journal{Undoes: 1}).SaveUnfinished()).To(Succeed())
+			Expect(transaction.FindUnfinished(1)).To(BeNil())
+			Expect(transaction.LoadUnfinished()).To(BeEmpty())
+		})
+	})
+})
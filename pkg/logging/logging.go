@@ -0,0 +1,77 @@
+// Leveled logging built on log/slog.
+// Records are written to stderr through termio unless a log file is configured.
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/drew-english/system-configurator/pkg/termio"
+)
+
+// Options controls the minimum level logged and where records are written.
+type Options struct {
+	Level slog.Level
+	File  string // when set, records are appended to this file instead of stderr
+}
+
+type termioErrOut struct{}
+
+var logger = newLogger(termioErrOut{}, slog.LevelWarn, false)
+
+// Configure replaces the logger used by the package level functions.
+// The returned function closes the log file, if one was opened.
+func Configure(opts Options) (func() error, error) {
+	if opts.File == "" {
+		logger = newLogger(termioErrOut{}, opts.Level, false)
+		return func() error { return nil }, nil
+	}
+
+	f, err := os.OpenFile(opts.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	logger = newLogger(f, opts.Level, true)
+	return f.Close, nil
+}
+
+func Logger() *slog.Logger {
+	return logger
+}
+
+func Debug(msg string, args ...any) {
+	logger.Debug(msg, args...)
+}
+
+func Info(msg string, args ...any) {
+	logger.Info(msg, args...)
+}
+
+func Warn(msg string, args ...any) {
+	logger.Warn(msg, args...)
+}
+
+func Error(msg string, args ...any) {
+	logger.Error(msg, args...)
+}
+
+func newLogger(w io.Writer, level slog.Level, withTime bool) *slog.Logger {
+	return slog.New(slog.NewTextHandler(redactingWriter{w}, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if !withTime && len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+
+			return a
+		},
+	}))
+}
+
+// Resolves the current termio error output on each write so that swapping
+// termio.DefaultIO also redirects logs.
+func (termioErrOut) Write(p []byte) (int, error) {
+	return termio.DefaultIO.ErrOut.Write(p)
+}
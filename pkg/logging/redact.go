@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Text that replaces redacted values.
+const RedactedText = "[REDACTED]"
+
+type (
+	redactor struct {
+		mu       sync.RWMutex
+		replacer *strings.Replacer
+		values   []string
+	}
+
+	redactingWriter struct {
+		w io.Writer
+	}
+)
+
+var secrets = &redactor{}
+
+// Redact hides value wherever it appears in logs from now on, for values such as secrets that must never be written out.
+func Redact(value string) {
+	if value == "" {
+		return
+	}
+
+	secrets.mu.Lock()
+	defer secrets.mu.Unlock()
+
+	// Logs quote values with special characters, so the quoted form is redacted as well.
+	quoted := strings.Trim(strconv.Quote(value), `"`)
+	for _, v := range []string{value, quoted} {
+		if !slices.Contains(secrets.values, v) {
+			secrets.values = append(secrets.values, v)
+		}
+	}
+
+	// Longer values first, so a value containing another is replaced whole.
+	slices.SortFunc(secrets.values, func(a, b string) int { return len(b) - len(a) })
+	pairs := make([]string, 0, len(secrets.values)*2)
+	for _, v := range secrets.values {
+		pairs = append(pairs, v, RedactedText)
+	}
+
+	secrets.replacer = strings.NewReplacer(pairs...)
+}
+
+// Redacted returns s with every value passed to Redact replaced by RedactedText.
+func Redacted(s string) string {
+	secrets.mu.RLock()
+	defer secrets.mu.RUnlock()
+
+	if secrets.replacer == nil {
+		return s
+	}
+
+	return secrets.replacer.Replace(s)
+}
+
+// RedactingWriter returns a writer that replaces every value passed to Redact before writing to w.
+// Values are only replaced within a single write.
+func RedactingWriter(w io.Writer) io.Writer {
+	return redactingWriter{w}
+}
+
+// Each record is written with a single call, so values are never split across writes.
+func (rw redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(rw.w, Redacted(string(p))); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
@@ -2,15 +2,24 @@ package run
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"strings"
+	"time"
+
+	"github.com/drew-english/system-configurator/pkg/logging"
+	"github.com/drew-english/system-configurator/pkg/termio"
 )
 
 type (
 	RunCmd interface {
 		Run() error
 		Output() ([]byte, error)
+		Stream(label string) error
 	}
 
 	// CmdError provides more visibility into why an exec.Cmd had failed
@@ -22,25 +31,36 @@ type (
 
 	cmdWrap struct {
 		*exec.Cmd
+		ctx context.Context
 	}
 )
 
+// Time given to a command to exit after being interrupted before it is killed.
+var WaitDelay = 10 * time.Second
+
 // Find an executable within path.
 // Provides a hook for testing
 var Find = exec.LookPath
 
-// Generate a run command.
+// Generate a run command bound to ctx. When ctx is done the command is
+// interrupted, then killed if it has not exited after WaitDelay.
 // Provides a hook for testing
-var Command = func(name string, arg ...string) RunCmd {
-	return &cmdWrap{exec.Command(name, arg...)}
+var Command = func(ctx context.Context, name string, arg ...string) RunCmd {
+	cmd := exec.CommandContext(ctx, name, arg...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = WaitDelay
+
+	return &cmdWrap{cmd, ctx}
 }
 
 func (c *cmdWrap) Run() error {
 	var stderr bytes.Buffer
 	c.Stderr = &stderr
 
-	if err := c.Cmd.Run(); err != nil {
-		return CmdError{c.Args, err, &stderr}
+	if err := c.logged(c.Cmd.Run); err != nil {
+		return c.wrapErr(err, &stderr)
 	}
 
 	return nil
@@ -50,20 +70,75 @@ func (c *cmdWrap) Output() ([]byte, error) {
 	var stderr bytes.Buffer
 	c.Stderr = &stderr
 
-	out, err := c.Cmd.Output()
+	var out []byte
+	err := c.logged(func() (err error) {
+		out, err = c.Cmd.Output()
+		return
+	})
 	if err != nil {
-		return out, CmdError{c.Args, err, &stderr}
+		return out, c.wrapErr(err, &stderr)
 	}
 
 	return out, nil
 }
 
+// Stream runs the command, displaying its output through termio under label as it is produced, with secrets redacted.
+// Stderr is still captured for the returned CmdError.
+func (c *cmdWrap) Stream(label string) error {
+	stream := termio.Stream(label)
+	defer stream.Close()
+
+	var stderr bytes.Buffer
+	c.Stdout = logging.RedactingWriter(stream.Out)
+	c.Stderr = io.MultiWriter(&stderr, logging.RedactingWriter(stream.ErrOut))
+
+	if err := c.logged(c.Cmd.Run); err != nil {
+		return c.wrapErr(err, &stderr)
+	}
+
+	return nil
+}
+
+// logged executes run, logging the command with its duration and exit status.
+func (c *cmdWrap) logged(run func() error) error {
+	logging.Debug("running command", "args", c.Args)
+	start := time.Now()
+	err := run()
+
+	logging.Info("ran command", "args", c.Args, "duration", time.Since(start), "exit_status", exitStatus(err))
+	return err
+}
+
+// wrapErr reports the context error in place of the exit status when the
+// command was stopped because its context was done.
+func (c *cmdWrap) wrapErr(err error, stderr *bytes.Buffer) error {
+	if ctxErr := c.ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	}
+
+	return CmdError{c.Args, err, stderr}
+}
+
+// exitStatus of a command's error, -1 when the command did not exit normally.
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	return -1
+}
+
 func (e CmdError) Error() string {
 	msg := e.Stderr.String()
 	if msg != "" && !strings.HasSuffix(msg, "\n") {
 		msg += "\n"
 	}
-	return fmt.Sprintf("%s%s: %s", msg, e.Args[0], e.Err)
+	return logging.Redacted(fmt.Sprintf("%s%s: %s", msg, e.Args[0], e.Err))
 }
 
 func (e CmdError) Unwrap() error {
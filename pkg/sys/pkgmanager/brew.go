@@ -0,0 +1,137 @@
+package pkgmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/pkg/run"
+)
+
+// ErrUnsupported is returned for packages that cannot be installed on the host system.
+var ErrUnsupported = errors.New("not supported on this system")
+
+// CasksSupported reports whether brew can install casks, which are only available on macOS.
+// Provides a hook for testing
+var CasksSupported = runtime.GOOS == "darwin"
+
+// brewPackageManager installs formulae and casks, tapping third-party repositories before installing from them.
+type brewPackageManager struct {
+	*basePackageManager
+}
+
+// Supports returns an ErrUnsupported error when manager cannot install pkg on the host system.
+func Supports(manager PacakgeManager, pkg *model.Package) error {
+	if checker, ok := manager.(interface{ supports(*model.Package) error }); ok {
+		return checker.supports(pkg)
+	}
+
+	return nil
+}
+
+func (pm *brewPackageManager) supports(pkg *model.Package) error {
+	if pkg.IsCask() && !CasksSupported {
+		return fmt.Errorf("cask `%s` is %w, casks are only available on macOS", pkg.Name, ErrUnsupported)
+	}
+
+	return nil
+}
+
+func (pm *brewPackageManager) AddPackage(ctx context.Context, pkg *model.Package) error {
+	if err := pm.supports(pkg); err != nil {
+		return err
+	}
+
+	ctx, cancel := withTimeout(ctx, Timeouts.Add)
+	defer cancel()
+
+	if pkg.Tap != "" {
+		if err := run.Command(ctx, pm.BaseCmd, "tap", pkg.Tap).Stream(pkg.Tap); err != nil {
+			return err
+		}
+	}
+
+	args := slices.Clone(pm.AddCmd)
+	if pkg.IsCask() {
+		args = append(args, "--cask")
+	}
+
+	name := pm.FmtPackageVersion(pkg)
+	return run.Command(ctx, pm.BaseCmd, append(args, name)...).Stream(name)
+}
+
+// ListPackages lists the installed formulae, and the installed casks where they are supported.
+func (pm *brewPackageManager) ListPackages(ctx context.Context) ([]*model.Package, error) {
+	pkgs, err := pm.basePackageManager.ListPackages(ctx)
+	if err != nil || !CasksSupported {
+		return pkgs, err
+	}
+
+	casks, err := pm.listCasks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(pkgs, casks...), nil
+}
+
+// ListExplicitPackages lists the formulae the user installed and every cask, since casks are never installed as dependencies.
+func (pm *brewPackageManager) ListExplicitPackages(ctx context.Context) ([]*model.Package, error) {
+	pkgs, err := pm.basePackageManager.ListExplicitPackages(ctx)
+	if err != nil || !CasksSupported {
+		return pkgs, err
+	}
+
+	casks, err := pm.listCasks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cask := range casks {
+		cask.Version = ""
+	}
+
+	return append(pkgs, casks...), nil
+}
+
+// InstallCommand returns the command line that installs pkgs, qualifying packages from a tap and
+// casks with their repository so that a single command installs both formulae and casks.
+func (pm *brewPackageManager) InstallCommand(pkgs ...*model.Package) []string {
+	args := append([]string{pm.BaseCmd}, pm.AddCmd...)
+	for _, pkg := range pkgs {
+		switch {
+		case pkg.Tap != "":
+			args = append(args, pkg.Tap+"/"+pkg.Name)
+		case pkg.IsCask():
+			args = append(args, "homebrew/cask/"+pkg.Name)
+		default:
+			args = append(args, pkg.Name)
+		}
+	}
+
+	return args
+}
+
+func (pm *brewPackageManager) listCasks(ctx context.Context) ([]*model.Package, error) {
+	ctx, cancel := withTimeout(ctx, Timeouts.List)
+	defer cancel()
+
+	out, err := run.Command(ctx, pm.BaseCmd, "list", "--cask", "--versions").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var casks []*model.Package
+	for _, line := range strings.Split(string(out), "\n") {
+		if cask := pm.parsePgk(line); cask != nil {
+			cask.Kind = model.KindCask
+			casks = append(casks, cask)
+		}
+	}
+
+	return casks, nil
+}
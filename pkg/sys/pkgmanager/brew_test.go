@@ -0,0 +1,118 @@
+package pkgmanager_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/pkg/sys/pkgmanager"
+	"github.com/drew-english/system-configurator/spec/stub/run"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Brew", func() {
+	var (
+		commandStubs     *run.CommandStubManager
+		teardownCmdStubs func(testing.TB)
+		casksSupported   bool
+
+		brew = pkgmanager.Managers["brew"]
+	)
+
+	BeforeEach(func() {
+		commandStubs, teardownCmdStubs = run.StubCommand()
+		casksSupported = true
+	})
+
+	JustBeforeEach(func() {
+		original := pkgmanager.CasksSupported
+		pkgmanager.CasksSupported = casksSupported
+		DeferCleanup(func() { pkgmanager.CasksSupported = original })
+	})
+
+	AfterEach(func() {
+		teardownCmdStubs(GinkgoTB())
+	})
+
+	Describe("AddPackage", func() {
+		It("installs a cask", func() {
+			commandStubs.Register("brew install --cask firefox", "")
+			Expect(brew.AddPackage(context.Background(), &model.Package{Name: "firefox", Kind: model.KindCask})).To(Succeed())
+		})
+
+		It("taps the repository of a package before installing it", func() {
+			commandStubs.Register("brew tap homebrew/cask-fonts", "")
+			commandStubs.Register("brew install --cask font-fira-code", "")
+			Expect(brew.AddPackage(context.Background(), &model.Package{Name: "font-fira-code", Kind: model.KindCask, Tap: "homebrew/cask-fonts"})).To(Succeed())
+		})
+
+		Context("when casks are not supported", func() {
+			BeforeEach(func() {
+				casksSupported = false
+			})
+
+			It("returns an unsupported error for casks", func() {
+				err := brew.AddPackage(context.Background(), &model.Package{Name: "firefox", Kind: model.KindCask})
+				Expect(err).To(MatchError(pkgmanager.ErrUnsupported))
+				Expect(err).To(MatchError("cask `firefox` is not supported on this system, casks are only available on macOS"))
+			})
+
+			It("reports casks as unsupported", func() {
+				Expect(pkgmanager.Supports(brew, &model.Package{Name: "firefox", Kind: model.KindCask})).To(MatchError(pkgmanager.ErrUnsupported))
+				Expect(pkgmanager.Supports(brew, &model.Package{Name: "fzf"})).To(Succeed())
+				Expect(pkgmanager.Supports(pkgmanager.Managers["apt"], &model.Package{Name: "firefox", Kind: model.KindCask})).To(Succeed())
+			})
+
+			It("still installs formulae from a tap", func() {
+				commandStubs.Register("brew tap hashicorp/tap", "")
+				commandStubs.Register("brew install terraform", "")
+				Expect(brew.AddPackage(context.Background(), &model.Package{Name: "terraform", Tap: "hashicorp/tap"})).To(Succeed())
+			})
+		})
+	})
+
+	Describe("ListPackages", func() {
+		It("lists the installed formulae and casks", func() {
+			commandStubs.Register("brew list --formula --versions", "fzf 0.46.0\n")
+			commandStubs.Register("brew list --cask --versions", "firefox 124.0.1\n")
+			Expect(brew.ListPackages(context.Background())).To(Equal([]*model.Package{
+				{Name: "fzf", Version: "0.46.0"},
+				{Name: "firefox", Version: "124.0.1", Kind: model.KindCask},
+			}))
+		})
+
+		Context("when casks are not supported", func() {
+			BeforeEach(func() {
+				casksSupported = false
+			})
+
+			It("lists only the formulae", func() {
+				commandStubs.Register("brew list --formula --versions", "fzf 0.46.0\n")
+				Expect(brew.ListPackages(context.Background())).To(Equal([]*model.Package{{Name: "fzf", Version: "0.46.0"}}))
+			})
+		})
+	})
+
+	Describe("ListExplicitPackages", func() {
+		It("lists the formulae the user installed and every cask", func() {
+			commandStubs.Register("brew leaves --installed-on-request", "fzf\n")
+			commandStubs.Register("brew list --cask --versions", "firefox 124.0.1\n")
+			Expect(brew.ListExplicitPackages(context.Background())).To(Equal([]*model.Package{
+				{Name: "fzf"},
+				{Name: "firefox", Kind: model.KindCask},
+			}))
+		})
+	})
+
+	Describe("InstallCommand", func() {
+		It("qualifies casks and packages from a tap", func() {
+			Expect(brew.InstallCommand(
+				&model.Package{Name: "fzf"},
+				&model.Package{Name: "firefox", Kind: model.KindCask},
+				&model.Package{Name: "terraform", Tap: "hashicorp/tap"},
+			)).To(Equal([]string{"brew", "install", "fzf", "homebrew/cask/firefox", "hashicorp/tap/terraform"}))
+		})
+	})
+})
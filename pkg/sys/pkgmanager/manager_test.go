@@ -1,6 +1,7 @@
 package pkgmanager_test
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -51,13 +52,13 @@ var _ = Describe("PackageManager", func() {
 
 		It("returns nil", func() {
 			commandStubs.Register(addPkgExpression, "package added successfully")
-			Expect(manager.AddPackage(pkg)).ToNot(HaveOccurred())
+			Expect(manager.AddPackage(context.Background(), pkg)).ToNot(HaveOccurred())
 		})
 
 		Context("when the command fails", func() {
 			It("returns an error", func() {
 				commandStubs.RegisterError(addPkgExpression, 1, "failed to find package")
-				Expect(manager.AddPackage(pkg)).To(MatchError(fmt.Sprintf("failed to find package\n%s: %s", manager.Name(), "generic error")))
+				Expect(manager.AddPackage(context.Background(), pkg)).To(MatchError(fmt.Sprintf("failed to find package\n%s: %s", manager.Name(), "generic error")))
 			})
 		})
 	})
@@ -72,17 +73,43 @@ var _ = Describe("PackageManager", func() {
 
 		It("returns nil", func() {
 			commandStubs.Register(rmPkgExpression, "package removed successfully")
-			Expect(manager.RemovePackage(pkg.Name)).ToNot(HaveOccurred())
+			Expect(manager.RemovePackage(context.Background(), pkg.Name)).ToNot(HaveOccurred())
 		})
 
 		Context("when the command fails", func() {
 			It("returns an error", func() {
 				commandStubs.RegisterError(rmPkgExpression, 1, "failed to find package")
-				Expect(manager.RemovePackage(pkg.Name)).To(MatchError(fmt.Sprintf("failed to find package\n%s: %s", manager.Name(), "generic error")))
+				Expect(manager.RemovePackage(context.Background(), pkg.Name)).To(MatchError(fmt.Sprintf("failed to find package\n%s: %s", manager.Name(), "generic error")))
 			})
 		})
 	})
 
+	Describe("InstallCommand", func() {
+		It("returns the command line installing the packages", func() {
+			Expect(pkgmanager.Managers["apt"].InstallCommand(&model.Package{Name: "fzf"}, &model.Package{Name: "stow", Version: "2.3.1"})).
+				To(Equal([]string{"apt", "install", "-y", "fzf", "stow=2.3.1"}))
+		})
+
+		It("splits packages formatted with options into separate arguments", func() {
+			Expect(pkgmanager.Managers["snap"].InstallCommand(&model.Package{Name: "go", Version: "1.22/stable"})).
+				To(Equal([]string{"snap", "install", "--classic", "go", "--channel=1.22/stable"}))
+		})
+	})
+
+	Describe("ListExplicitPackages", func() {
+		It("returns the packages the user installed", func() {
+			commandStubs.Register("apt-mark showmanual", "fzf\nripgrep\n")
+			Expect(pkgmanager.Managers["apt"].ListExplicitPackages(context.Background())).
+				To(Equal([]*model.Package{{Name: "fzf"}, {Name: "ripgrep"}}))
+		})
+
+		It("skips the header of the snap list", func() {
+			commandStubs.Register("snap list", "Name    Version  Rev    Tracking       Publisher  Notes\ngo      1.22.1   10535  1.22/stable    mwhudson   classic\n")
+			Expect(pkgmanager.Managers["snap"].ListExplicitPackages(context.Background())).
+				To(Equal([]*model.Package{{Name: "go"}}))
+		})
+	})
+
 	Describe("ListPackages", func() {
 		stubbedListOutput := map[string]string{
 			"apk": `WARNING: opening from cache https://dl-cdn.alpinelinux.org/alpine/v3.19/main: No such file or directory
@@ -176,10 +203,13 @@ e2fsprogs 1.47.0-1`,
 				BeforeEach(func() {
 					manager = mgr
 					commandStubs.Register(fmt.Sprintf("%s (list|-Q)( --installed){0,1}", mgrName), stubbedListOutput[mgrName])
+					if mgrName == "brew" && pkgmanager.CasksSupported {
+						commandStubs.Register("brew list --cask --versions", "")
+					}
 				})
 
 				It("should return the list of packages", func() {
-					pkgList, err := manager.ListPackages()
+					pkgList, err := manager.ListPackages(context.Background())
 					Expect(err).NotTo(HaveOccurred())
 					Expect(pkgList).To(ContainElements(expectedPkgList[mgrName]))
 				})
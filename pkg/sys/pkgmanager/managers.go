@@ -21,8 +21,12 @@ var (
 		AddCmd:           cmd("add"),
 		RemoveCmd:        cmd("del"),
 		ListCmd:          cmd("list --installed"),
+		ExplicitCmd:      cmd("cat", "/etc/apk/world"),
 		listParsePattern: re(`^([\w-]+)-(\S+-\S+)`),
+		explicitPattern:  re(`^([\w.+-]+)`),
 		versionTmpl:      tpl("{{.Name}}={{.Version}}"),
+		exactVersions:    true,
+		repositories:     apkRepositories{},
 	}
 
 	apt = &basePackageManager{
@@ -30,26 +34,37 @@ var (
 		AddCmd:           cmd("install", "-y"),
 		RemoveCmd:        cmd("remove"),
 		ListCmd:          cmd("list", "--installed"),
+		ExplicitCmd:      cmd("apt-mark", "showmanual"),
 		listParsePattern: re(`^([\w-]+)\/.*?\s(\S+)`),
+		explicitPattern:  re(`^(\S+)$`),
 		versionTmpl:      tpl("{{.Name}}={{.Version}}"),
+		exactVersions:    true,
+		repositories:     aptRepositories{},
 	}
 
-	brew = &basePackageManager{
+	brew = &brewPackageManager{&basePackageManager{
 		BaseCmd:          "brew",
 		AddCmd:           cmd("install"),
 		RemoveCmd:        cmd("remove"),
-		ListCmd:          cmd("list", "--versions"),
+		ListCmd:          cmd("list", "--formula", "--versions"),
+		ExplicitCmd:      cmd("brew", "leaves", "--installed-on-request"),
 		listParsePattern: re(`^([\w-]+)\s(\S+)`),
+		explicitPattern:  re(`^(\S+)$`),
 		versionTmpl:      tpl("{{.Name}}"),
-	}
+		repositories:     brewRepositories{},
+	}}
 
 	dnf = &basePackageManager{
 		BaseCmd:          "dnf",
 		AddCmd:           cmd("install", "-y"),
 		RemoveCmd:        cmd("erase"),
 		ListCmd:          cmd("list", "--installed"),
+		ExplicitCmd:      cmd("dnf", "repoquery", "--userinstalled", "--queryformat", "%{name}\n"),
 		listParsePattern: re(`^(\S+)\.\w+\s+(\S+?)-`),
+		explicitPattern:  re(`^(\S+)$`),
 		versionTmpl:      tpl("{{.Name}}-{{.Version}}"),
+		exactVersions:    true,
+		repositories:     dnfRepositories{},
 	}
 
 	snap = &basePackageManager{
@@ -57,7 +72,9 @@ var (
 		AddCmd:           cmd("install", "--classic"),
 		RemoveCmd:        cmd("remove"),
 		ListCmd:          cmd("list"),
+		ExplicitCmd:      cmd("snap", "list"),
 		listParsePattern: re(``), // TODO: Test on ubuntu machine, docker image unable to run snap
+		explicitPattern:  re(`^([a-z0-9-]+)\s`),
 		versionTmpl:      tpl("{{.Name}} --channel={{.Version}}"),
 	}
 
@@ -66,8 +83,12 @@ var (
 		AddCmd:           cmd("-S", "--noconfirm"),
 		RemoveCmd:        cmd("-Rscn", "--noconfirm"),
 		ListCmd:          cmd("-Q"),
+		ExplicitCmd:      cmd("pacman", "-Qqe"),
 		listParsePattern: re(`^([\w-\.]+)\s(\S+)`),
+		explicitPattern:  re(`^(\S+)$`),
 		versionTmpl:      tpl("{{.Name}}={{.Version}}"),
+		exactVersions:    true,
+		repositories:     pacmanRepositories{},
 	}
 )
 
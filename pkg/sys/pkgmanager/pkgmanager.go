@@ -2,12 +2,15 @@
 package pkgmanager
 
 import (
+	"context"
 	"errors"
 	"regexp"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/pkg/logging"
 	"github.com/drew-english/system-configurator/pkg/run"
 	"github.com/drew-english/system-configurator/pkg/sys"
 )
@@ -15,10 +18,12 @@ import (
 type (
 	PacakgeManager interface {
 		Name() string
-		AddPackage(*model.Package) error
-		RemovePackage(string) error
-		ListPackages() ([]*model.Package, error)
+		AddPackage(context.Context, *model.Package) error
+		RemovePackage(context.Context, string) error
+		ListPackages(context.Context) ([]*model.Package, error)
+		ListExplicitPackages(context.Context) ([]*model.Package, error)
 		FmtPackageVersion(*model.Package) string
+		InstallCommand(...*model.Package) []string
 	}
 
 	basePackageManager struct {
@@ -26,13 +31,26 @@ type (
 		AddCmd           []string
 		RemoveCmd        []string
 		ListCmd          []string
+		ExplicitCmd      []string // full command listing the packages installed explicitly rather than as dependencies
 		listParsePattern *regexp.Regexp
+		explicitPattern  *regexp.Regexp
 		versionTmpl      *template.Template
+		exactVersions    bool                // whether versionTmpl installs exactly the version, rather than ignoring it or treating it as a channel
+		repositories     repositoryInstaller // nil when the manager does not support third-party repositories
+	}
+
+	// Maximum duration of each package manager operation, zero disables the limit.
+	OperationTimeouts struct {
+		Add    time.Duration
+		Remove time.Duration
+		List   time.Duration
 	}
 )
 
 var (
 	cachedManager PacakgeManager
+
+	Timeouts = OperationTimeouts{}
 )
 
 var FindPackageManager = func() (PacakgeManager, error) {
@@ -42,12 +60,18 @@ var FindPackageManager = func() (PacakgeManager, error) {
 
 	possibleManagers := sys.SupportedPackageManagers()
 	for _, mgr := range possibleManagers {
-		if _, err := run.Find(mgr); err == nil && Managers[mgr] != nil {
-			cachedManager = Managers[mgr]
-			return Managers[mgr], nil
+		path, err := run.Find(mgr)
+		if err != nil || Managers[mgr] == nil {
+			logging.Debug("package manager unavailable", "manager", mgr, "error", err)
+			continue
 		}
+
+		logging.Debug("using package manager", "manager", mgr, "path", path)
+		cachedManager = Managers[mgr]
+		return Managers[mgr], nil
 	}
 
+	logging.Debug("no supported package manager found", "candidates", possibleManagers)
 	return nil, errors.New("unable to find a supported package manager on host system")
 }
 
@@ -55,22 +79,42 @@ func ResetCachedManager() {
 	cachedManager = nil
 }
 
+// PinsVersions reports whether manager installs exactly the version of a package.
+func PinsVersions(manager PacakgeManager) bool {
+	pinner, ok := manager.(interface{ pinsVersions() bool })
+	return ok && pinner.pinsVersions()
+}
+
+func (pm *basePackageManager) pinsVersions() bool {
+	return pm.exactVersions
+}
+
 func (pm *basePackageManager) Name() string {
 	return pm.BaseCmd
 }
 
-func (pm *basePackageManager) AddPackage(pkg *model.Package) error {
-	args := append(pm.AddCmd, pm.FmtPackageVersion(pkg))
-	return run.Command(pm.BaseCmd, args...).Run()
+func (pm *basePackageManager) AddPackage(ctx context.Context, pkg *model.Package) error {
+	ctx, cancel := withTimeout(ctx, Timeouts.Add)
+	defer cancel()
+
+	pkgVersion := pm.FmtPackageVersion(pkg)
+	args := append(pm.AddCmd, pkgVersion)
+	return run.Command(ctx, pm.BaseCmd, args...).Stream(pkgVersion)
 }
 
-func (pm *basePackageManager) RemovePackage(pkgName string) error {
+func (pm *basePackageManager) RemovePackage(ctx context.Context, pkgName string) error {
+	ctx, cancel := withTimeout(ctx, Timeouts.Remove)
+	defer cancel()
+
 	args := append(pm.RemoveCmd, pkgName)
-	return run.Command(pm.BaseCmd, args...).Run()
+	return run.Command(ctx, pm.BaseCmd, args...).Stream(pkgName)
 }
 
-func (pm *basePackageManager) ListPackages() ([]*model.Package, error) {
-	out, err := run.Command(pm.BaseCmd, pm.ListCmd...).Output()
+func (pm *basePackageManager) ListPackages(ctx context.Context) ([]*model.Package, error) {
+	ctx, cancel := withTimeout(ctx, Timeouts.List)
+	defer cancel()
+
+	out, err := run.Command(ctx, pm.BaseCmd, pm.ListCmd...).Output()
 	if err != nil {
 		return nil, err
 	}
@@ -91,6 +135,27 @@ func (pm *basePackageManager) ListPackages() ([]*model.Package, error) {
 	return pkgs, nil
 }
 
+// ListExplicitPackages lists the names of the packages the user installed, leaving out the
+// packages installed as their dependencies.
+func (pm *basePackageManager) ListExplicitPackages(ctx context.Context) ([]*model.Package, error) {
+	ctx, cancel := withTimeout(ctx, Timeouts.List)
+	defer cancel()
+
+	out, err := run.Command(ctx, pm.ExplicitCmd[0], pm.ExplicitCmd[1:]...).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var pkgs []*model.Package
+	for _, line := range strings.Split(string(out), "\n") {
+		if matches := pm.explicitPattern.FindStringSubmatch(line); matches != nil {
+			pkgs = append(pkgs, &model.Package{Name: matches[1]})
+		}
+	}
+
+	return pkgs, nil
+}
+
 func (pm *basePackageManager) FmtPackageVersion(pkg *model.Package) string {
 	if pkg.Version == "" {
 		return pkg.Name
@@ -104,6 +169,16 @@ func (pm *basePackageManager) FmtPackageVersion(pkg *model.Package) string {
 	return buf.String()
 }
 
+// InstallCommand returns the command line that installs pkgs, for use outside of scfg.
+func (pm *basePackageManager) InstallCommand(pkgs ...*model.Package) []string {
+	args := append([]string{pm.BaseCmd}, pm.AddCmd...)
+	for _, pkg := range pkgs {
+		args = append(args, strings.Fields(pm.FmtPackageVersion(pkg))...)
+	}
+
+	return args
+}
+
 func (pm *basePackageManager) parsePgk(line string) *model.Package {
 	matches := pm.listParsePattern.FindStringSubmatch(line)
 	if matches == nil {
@@ -115,3 +190,11 @@ func (pm *basePackageManager) parsePgk(line string) *model.Package {
 		Version: matches[2],
 	}
 }
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
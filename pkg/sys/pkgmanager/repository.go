@@ -0,0 +1,366 @@
+package pkgmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/pkg/logging"
+	"github.com/drew-english/system-configurator/pkg/run"
+)
+
+type (
+	// repositoryInstaller configures third-party repositories for a package manager. add returns false
+	// when the repository is already configured, and reverses its changes when it fails so that a broken
+	// repository is never left behind. remove reverses a repository that add configured.
+	repositoryInstaller interface {
+		add(ctx context.Context, name string, src *model.RepositorySource) (bool, error)
+		remove(ctx context.Context, name string, src *model.RepositorySource) error
+	}
+
+	// aptRepositories writes a source list and its signing key to sources.list.d.
+	aptRepositories struct{}
+
+	// dnfRepositories writes a .repo file to yum.repos.d, dnf imports the signing key when it is first used.
+	dnfRepositories struct{}
+
+	// apkRepositories adds the repository to /etc/apk/repositories and its signing key to /etc/apk/keys.
+	apkRepositories struct{}
+
+	// pacmanRepositories adds a repository section to pacman.conf and trusts its signing key.
+	pacmanRepositories struct{}
+
+	// brewRepositories taps the repository.
+	brewRepositories struct{}
+)
+
+// RootDir is prefixed to the system files repositories are written to.
+// Provides a hook for testing
+var RootDir = "/"
+
+// AddRepository configures repo for manager, returning false when it is already configured.
+// Returns an ErrUnsupported error when repo is not available for manager.
+func AddRepository(ctx context.Context, manager PacakgeManager, repo *model.Repository) (bool, error) {
+	src := repo.For(manager.Name())
+	installer, ok := manager.(interface{ repositoryInstaller() repositoryInstaller })
+	if src == nil || !ok || installer.repositoryInstaller() == nil {
+		return false, fmt.Errorf("repository `%s` is %w for %s", repo.Name, ErrUnsupported, manager.Name())
+	}
+
+	ctx, cancel := withTimeout(ctx, Timeouts.Add)
+	defer cancel()
+
+	return installer.repositoryInstaller().add(ctx, repo.Name, src)
+}
+
+// RemoveRepository reverses the configuration of repo for manager by AddRepository.
+// Returns an ErrUnsupported error when repo is not available for manager.
+func RemoveRepository(ctx context.Context, manager PacakgeManager, repo *model.Repository) error {
+	src := repo.For(manager.Name())
+	installer, ok := manager.(interface{ repositoryInstaller() repositoryInstaller })
+	if src == nil || !ok || installer.repositoryInstaller() == nil {
+		return fmt.Errorf("repository `%s` is %w for %s", repo.Name, ErrUnsupported, manager.Name())
+	}
+
+	ctx, cancel := withTimeout(ctx, Timeouts.Remove)
+	defer cancel()
+
+	return installer.repositoryInstaller().remove(ctx, repo.Name, src)
+}
+
+func (pm *basePackageManager) repositoryInstaller() repositoryInstaller {
+	return pm.repositories
+}
+
+func (aptRepositories) add(ctx context.Context, name string, src *model.RepositorySource) (bool, error) {
+	components := src.Components
+	if len(components) == 0 {
+		components = []string{"main"}
+	}
+
+	options := ""
+	keyPath := "/etc/apt/keyrings/" + name + ".asc"
+	if src.Key != "" {
+		options = "[signed-by=" + keyPath + "] "
+	}
+
+	listPath := "/etc/apt/sources.list.d/" + name + ".list"
+	list := fmt.Sprintf("deb %s%s %s %s\n", options, src.URL, src.Suite, strings.Join(components, " "))
+	if current, err := readSystemFile(listPath); err != nil || current == list {
+		return false, err
+	} else if current != "" {
+		return false, errExists(listPath)
+	}
+
+	if src.Key != "" {
+		if err := checkNotExists(keyPath); err != nil {
+			return false, err
+		}
+
+		if err := downloadKey(ctx, src.Key, keyPath); err != nil {
+			return false, err
+		}
+	}
+
+	err := writeSystemFile(listPath, list)
+	if err == nil {
+		err = run.Command(ctx, "apt", "update").Stream(name)
+	}
+
+	if err != nil {
+		return false, errors.Join(err, removeSystemFile(listPath), removeSystemFile(keyPath))
+	}
+
+	return true, nil
+}
+
+func (aptRepositories) remove(ctx context.Context, name string, _ *model.RepositorySource) error {
+	if err := removeSystemFile("/etc/apt/keyrings/" + name + ".asc"); err != nil {
+		return err
+	}
+
+	if err := removeSystemFile("/etc/apt/sources.list.d/" + name + ".list"); err != nil {
+		return err
+	}
+
+	return run.Command(ctx, "apt", "update").Stream(name)
+}
+
+func (dnfRepositories) add(_ context.Context, name string, src *model.RepositorySource) (bool, error) {
+	repo := fmt.Sprintf("[%s]\nname=%s\nbaseurl=%s\nenabled=1\n", name, name, src.URL)
+	if src.Key != "" {
+		repo += "gpgcheck=1\ngpgkey=" + src.Key + "\n"
+	} else {
+		repo += "gpgcheck=0\n"
+	}
+
+	repoPath := "/etc/yum.repos.d/" + name + ".repo"
+	if current, err := readSystemFile(repoPath); err != nil || current == repo {
+		return false, err
+	} else if current != "" {
+		return false, errExists(repoPath)
+	}
+
+	return true, writeSystemFile(repoPath, repo)
+}
+
+func (dnfRepositories) remove(_ context.Context, name string, _ *model.RepositorySource) error {
+	return removeSystemFile("/etc/yum.repos.d/" + name + ".repo")
+}
+
+func (apkRepositories) add(ctx context.Context, name string, src *model.RepositorySource) (bool, error) {
+	const repositoriesPath = "/etc/apk/repositories"
+	current, err := readSystemFile(repositoriesPath)
+	if err != nil || containsLine(current, src.URL) {
+		return false, err
+	}
+
+	keyPath := "/etc/apk/keys/" + name + ".rsa.pub"
+	if src.Key != "" {
+		if err := checkNotExists(keyPath); err != nil {
+			return false, err
+		}
+
+		if err := downloadKey(ctx, src.Key, keyPath); err != nil {
+			return false, err
+		}
+	}
+
+	err = appendSystemFile(repositoriesPath, current, src.URL+"\n")
+	if err == nil {
+		err = run.Command(ctx, "apk", "update").Stream(name)
+	}
+
+	if err != nil {
+		return false, errors.Join(err, writeSystemFile(repositoriesPath, current), removeSystemFile(keyPath))
+	}
+
+	return true, nil
+}
+
+func (apkRepositories) remove(ctx context.Context, name string, src *model.RepositorySource) error {
+	const repositoriesPath = "/etc/apk/repositories"
+	current, err := readSystemFile(repositoriesPath)
+	if err != nil {
+		return err
+	}
+
+	if err := removeSystemFile("/etc/apk/keys/" + name + ".rsa.pub"); err != nil {
+		return err
+	}
+
+	if err := writeSystemFile(repositoriesPath, removeLines(current, src.URL)); err != nil {
+		return err
+	}
+
+	return run.Command(ctx, "apk", "update").Stream(name)
+}
+
+func (pacmanRepositories) add(ctx context.Context, name string, src *model.RepositorySource) (bool, error) {
+	const confPath = "/etc/pacman.conf"
+	current, err := readSystemFile(confPath)
+	if err != nil || containsLine(current, "["+name+"]") {
+		return false, err
+	}
+
+	if src.Key != "" {
+		if err := run.Command(ctx, "pacman-key", "--recv-keys", src.Key).Stream(name); err != nil {
+			return false, err
+		}
+
+		if err := run.Command(ctx, "pacman-key", "--lsign-key", src.Key).Stream(name); err != nil {
+			return false, err
+		}
+	}
+
+	err = appendSystemFile(confPath, current, fmt.Sprintf("\n[%s]\nServer = %s\n", name, src.URL))
+	if err == nil {
+		err = run.Command(ctx, "pacman", "-Sy").Stream(name)
+	}
+
+	if err != nil {
+		return false, errors.Join(err, writeSystemFile(confPath, current))
+	}
+
+	return true, nil
+}
+
+// remove deletes the repository section from pacman.conf, the signing key stays trusted.
+func (pacmanRepositories) remove(ctx context.Context, name string, src *model.RepositorySource) error {
+	const confPath = "/etc/pacman.conf"
+	current, err := readSystemFile(confPath)
+	if err != nil {
+		return err
+	}
+
+	section := fmt.Sprintf("\n[%s]\nServer = %s\n", name, src.URL)
+	if !strings.Contains(current, section) {
+		return fmt.Errorf("repository section `[%s]` not found in %s", name, confPath)
+	}
+
+	if err := writeSystemFile(confPath, strings.Replace(current, section, "", 1)); err != nil {
+		return err
+	}
+
+	return run.Command(ctx, "pacman", "-Sy").Stream(name)
+}
+
+func (brewRepositories) add(ctx context.Context, name string, src *model.RepositorySource) (bool, error) {
+	out, err := run.Command(ctx, "brew", "tap").Output()
+	if err != nil {
+		return false, err
+	}
+
+	if containsLine(strings.ToLower(string(out)), strings.ToLower(src.Tap)) {
+		return false, nil
+	}
+
+	args := []string{"tap", src.Tap}
+	if src.URL != "" {
+		args = append(args, src.URL)
+	}
+
+	if err := run.Command(ctx, "brew", args...).Stream(name); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (brewRepositories) remove(ctx context.Context, name string, src *model.RepositorySource) error {
+	return run.Command(ctx, "brew", "untap", src.Tap).Stream(name)
+}
+
+// downloadKey downloads a signing key to a system path.
+func downloadKey(ctx context.Context, url, path string) error {
+	path = filepath.Join(RootDir, path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return run.Command(ctx, "curl", "-fsSL", "-o", path, url).Run()
+}
+
+// checkNotExists returns an error when a system file exists, so that a file scfg did not write is
+// never overwritten, nor removed when the repository is.
+func checkNotExists(path string) error {
+	if _, err := os.Stat(filepath.Join(RootDir, path)); err == nil {
+		return errExists(path)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func errExists(path string) error {
+	return fmt.Errorf("`%s` already exists, remove it for scfg to manage the repository", filepath.Join(RootDir, path))
+}
+
+// readSystemFile returns the contents of a system file, which are empty when it does not exist.
+func readSystemFile(path string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(RootDir, path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+
+	return string(data), err
+}
+
+func writeSystemFile(path, contents string) error {
+	path = filepath.Join(RootDir, path)
+	logging.Debug("writing repository configuration", "path", path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, []byte(contents), 0644)
+}
+
+// removeSystemFile removes a system file, ignoring one that does not exist.
+func removeSystemFile(path string) error {
+	path = filepath.Join(RootDir, path)
+	logging.Debug("removing repository configuration", "path", path)
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// appendSystemFile appends to a system file with the given current contents, starting a new line if needed.
+func appendSystemFile(path, current, contents string) error {
+	if current != "" && !strings.HasSuffix(current, "\n") {
+		contents = "\n" + contents
+	}
+
+	return writeSystemFile(path, current+contents)
+}
+
+// removeLines returns s without the lines equal to line.
+func removeLines(s, line string) string {
+	lines := strings.SplitAfter(s, "\n")
+	kept := lines[:0]
+	for _, l := range lines {
+		if strings.TrimSpace(l) != line {
+			kept = append(kept, l)
+		}
+	}
+
+	return strings.Join(kept, "")
+}
+
+func containsLine(s, line string) bool {
+	for _, l := range strings.Split(s, "\n") {
+		if strings.TrimSpace(l) == line {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,254 @@
+package pkgmanager_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/drew-english/system-configurator/internal/model"
+	"github.com/drew-english/system-configurator/pkg/sys/pkgmanager"
+	"github.com/drew-english/system-configurator/spec/stub/run"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Repositories", func() {
+	var (
+		commandStubs     *run.CommandStubManager
+		teardownCmdStubs func(testing.TB)
+		root             string
+		repo             *model.Repository
+	)
+
+	BeforeEach(func() {
+		commandStubs, teardownCmdStubs = run.StubCommand()
+		root = GinkgoT().TempDir()
+
+		original := pkgmanager.RootDir
+		pkgmanager.RootDir = root
+		DeferCleanup(func() { pkgmanager.RootDir = original })
+	})
+
+	AfterEach(func() {
+		teardownCmdStubs(GinkgoTB())
+	})
+
+	subject := func(managerName string) (bool, error) {
+		return pkgmanager.AddRepository(context.Background(), pkgmanager.Managers[managerName], repo)
+	}
+
+	remove := func(managerName string) error {
+		return pkgmanager.RemoveRepository(context.Background(), pkgmanager.Managers[managerName], repo)
+	}
+
+	readFile := func(path string) string {
+		data, err := os.ReadFile(filepath.Join(root, path))
+		Expect(err).NotTo(HaveOccurred())
+		return string(data)
+	}
+
+	writeFile := func(path, contents string) {
+		Expect(os.MkdirAll(filepath.Dir(filepath.Join(root, path)), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(root, path), []byte(contents), 0644)).To(Succeed())
+	}
+
+	Context("for apt", func() {
+		BeforeEach(func() {
+			repo = &model.Repository{Name: "docker", Sources: map[string]*model.RepositorySource{
+				"apt": {URL: "https://download.docker.com/linux/ubuntu", Suite: "noble", Components: []string{"stable"}, Key: "https://download.docker.com/linux/ubuntu/gpg"},
+			}}
+		})
+
+		It("downloads the key, writes the source list and updates the package index", func() {
+			commandStubs.Register(`curl -fsSL -o \S+/etc/apt/keyrings/docker.asc https://download.docker.com/linux/ubuntu/gpg`, "")
+			commandStubs.Register("apt update", "")
+
+			Expect(subject("apt")).To(BeTrue())
+			Expect(readFile("/etc/apt/sources.list.d/docker.list")).To(Equal("deb [signed-by=/etc/apt/keyrings/docker.asc] https://download.docker.com/linux/ubuntu noble stable\n"))
+		})
+
+		It("does nothing when the source list is up to date", func() {
+			writeFile("/etc/apt/sources.list.d/docker.list", "deb [signed-by=/etc/apt/keyrings/docker.asc] https://download.docker.com/linux/ubuntu noble stable\n")
+			Expect(subject("apt")).To(BeFalse())
+		})
+
+		It("refuses to overwrite a source list with other contents", func() {
+			writeFile("/etc/apt/sources.list.d/docker.list", "deb [arch=amd64 signed-by=/etc/apt/keyrings/docker.asc] https://download.docker.com/linux/ubuntu noble stable\n")
+
+			_, err := subject("apt")
+			Expect(err).To(MatchError(fmt.Sprintf("`%s` already exists, remove it for scfg to manage the repository", filepath.Join(root, "/etc/apt/sources.list.d/docker.list"))))
+			Expect(readFile("/etc/apt/sources.list.d/docker.list")).To(HavePrefix("deb [arch=amd64"))
+		})
+
+		It("refuses to overwrite an existing key", func() {
+			writeFile("/etc/apt/keyrings/docker.asc", "key")
+
+			_, err := subject("apt")
+			Expect(err).To(MatchError(ContainSubstring("docker.asc` already exists")))
+			Expect(readFile("/etc/apt/keyrings/docker.asc")).To(Equal("key"))
+		})
+
+		It("defaults to the main component without a key", func() {
+			repo.Sources["apt"] = &model.RepositorySource{URL: "https://example.com/apt", Suite: "stable"}
+			commandStubs.Register("apt update", "")
+
+			Expect(subject("apt")).To(BeTrue())
+			Expect(readFile("/etc/apt/sources.list.d/docker.list")).To(Equal("deb https://example.com/apt stable main\n"))
+		})
+
+		It("removes the source list and key it added", func() {
+			writeFile("/etc/apt/keyrings/docker.asc", "key")
+			writeFile("/etc/apt/sources.list.d/docker.list", "deb [signed-by=/etc/apt/keyrings/docker.asc] https://download.docker.com/linux/ubuntu noble stable\n")
+			commandStubs.Register("apt update", "")
+
+			Expect(remove("apt")).To(Succeed())
+			Expect(filepath.Join(root, "/etc/apt/keyrings/docker.asc")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(root, "/etc/apt/sources.list.d/docker.list")).NotTo(BeAnExistingFile())
+		})
+
+		It("returns an error when the key cannot be downloaded", func() {
+			commandStubs.RegisterError("curl", 22, "curl: (22) The requested URL returned error: 404")
+
+			_, err := subject("apt")
+			Expect(err).To(HaveOccurred())
+			Expect(filepath.Join(root, "/etc/apt/sources.list.d/docker.list")).NotTo(BeAnExistingFile())
+		})
+
+		It("removes the source list and key when the package index cannot be updated", func() {
+			commandStubs.Register(`curl -fsSL -o \S+/etc/apt/keyrings/docker.asc`, "", func([]string) { writeFile("/etc/apt/keyrings/docker.asc", "key") })
+			commandStubs.RegisterError("apt update", 100, "E: The repository is not signed.")
+
+			added, err := subject("apt")
+			Expect(added).To(BeFalse())
+			Expect(err).To(MatchError(ContainSubstring("The repository is not signed.")))
+			Expect(filepath.Join(root, "/etc/apt/keyrings/docker.asc")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(root, "/etc/apt/sources.list.d/docker.list")).NotTo(BeAnExistingFile())
+		})
+	})
+
+	Context("for dnf", func() {
+		BeforeEach(func() {
+			repo = &model.Repository{Name: "docker", Sources: map[string]*model.RepositorySource{
+				"dnf": {URL: "https://download.docker.com/linux/fedora/$releasever/$basearch/stable", Key: "https://download.docker.com/linux/fedora/gpg"},
+			}}
+		})
+
+		It("writes the repo file", func() {
+			Expect(subject("dnf")).To(BeTrue())
+			Expect(readFile("/etc/yum.repos.d/docker.repo")).To(Equal("[docker]\nname=docker\nbaseurl=https://download.docker.com/linux/fedora/$releasever/$basearch/stable\nenabled=1\ngpgcheck=1\ngpgkey=https://download.docker.com/linux/fedora/gpg\n"))
+			Expect(subject("dnf")).To(BeFalse())
+
+			Expect(remove("dnf")).To(Succeed())
+			Expect(filepath.Join(root, "/etc/yum.repos.d/docker.repo")).NotTo(BeAnExistingFile())
+		})
+
+		It("refuses to overwrite a repo file with other contents", func() {
+			writeFile("/etc/yum.repos.d/docker.repo", "[docker-ce-stable]\nbaseurl=https://download.docker.com/linux/fedora/$releasever/$basearch/stable\n")
+
+			_, err := subject("dnf")
+			Expect(err).To(MatchError(ContainSubstring("docker.repo` already exists")))
+			Expect(readFile("/etc/yum.repos.d/docker.repo")).To(HavePrefix("[docker-ce-stable]"))
+		})
+	})
+
+	Context("for apk", func() {
+		BeforeEach(func() {
+			repo = &model.Repository{Name: "edge-testing", Sources: map[string]*model.RepositorySource{
+				"apk": {URL: "https://dl-cdn.alpinelinux.org/alpine/edge/testing"},
+			}}
+		})
+
+		It("appends the repository and updates the package index", func() {
+			writeFile("/etc/apk/repositories", "https://dl-cdn.alpinelinux.org/alpine/v3.19/main")
+			commandStubs.Register("apk update", "")
+
+			Expect(subject("apk")).To(BeTrue())
+			Expect(readFile("/etc/apk/repositories")).To(Equal("https://dl-cdn.alpinelinux.org/alpine/v3.19/main\nhttps://dl-cdn.alpinelinux.org/alpine/edge/testing\n"))
+			Expect(subject("apk")).To(BeFalse())
+
+			commandStubs.Register("apk update", "")
+			Expect(remove("apk")).To(Succeed())
+			Expect(readFile("/etc/apk/repositories")).To(Equal("https://dl-cdn.alpinelinux.org/alpine/v3.19/main\n"))
+		})
+
+		It("restores the repositories when the package index cannot be updated", func() {
+			writeFile("/etc/apk/repositories", "https://dl-cdn.alpinelinux.org/alpine/v3.19/main\n")
+			commandStubs.RegisterError("apk update", 1, "UNTRUSTED signature")
+
+			added, err := subject("apk")
+			Expect(added).To(BeFalse())
+			Expect(err).To(HaveOccurred())
+			Expect(readFile("/etc/apk/repositories")).To(Equal("https://dl-cdn.alpinelinux.org/alpine/v3.19/main\n"))
+		})
+	})
+
+	Context("for pacman", func() {
+		BeforeEach(func() {
+			repo = &model.Repository{Name: "chaotic-aur", Sources: map[string]*model.RepositorySource{
+				"pacman": {URL: "https://cdn-mirror.chaotic.cx/$repo/$arch", Key: "3056513887B78AEB"},
+			}}
+		})
+
+		It("trusts the key and adds the repository section", func() {
+			writeFile("/etc/pacman.conf", "[core]\nInclude = /etc/pacman.d/mirrorlist\n")
+			commandStubs.Register("pacman-key --recv-keys 3056513887B78AEB", "")
+			commandStubs.Register("pacman-key --lsign-key 3056513887B78AEB", "")
+			commandStubs.Register("pacman -Sy", "")
+
+			Expect(subject("pacman")).To(BeTrue())
+			Expect(readFile("/etc/pacman.conf")).To(Equal("[core]\nInclude = /etc/pacman.d/mirrorlist\n\n[chaotic-aur]\nServer = https://cdn-mirror.chaotic.cx/$repo/$arch\n"))
+			Expect(subject("pacman")).To(BeFalse())
+
+			commandStubs.Register("pacman -Sy", "")
+			Expect(remove("pacman")).To(Succeed())
+			Expect(readFile("/etc/pacman.conf")).To(Equal("[core]\nInclude = /etc/pacman.d/mirrorlist\n"))
+		})
+
+		It("restores pacman.conf when the package databases cannot be synced", func() {
+			writeFile("/etc/pacman.conf", "[core]\nInclude = /etc/pacman.d/mirrorlist\n")
+			commandStubs.Register("pacman-key --recv-keys 3056513887B78AEB", "")
+			commandStubs.Register("pacman-key --lsign-key 3056513887B78AEB", "")
+			commandStubs.RegisterError("pacman -Sy", 1, "error: failed to synchronize all databases")
+
+			added, err := subject("pacman")
+			Expect(added).To(BeFalse())
+			Expect(err).To(HaveOccurred())
+			Expect(readFile("/etc/pacman.conf")).To(Equal("[core]\nInclude = /etc/pacman.d/mirrorlist\n"))
+		})
+	})
+
+	Context("for brew", func() {
+		BeforeEach(func() {
+			repo = &model.Repository{Name: "hashicorp", Sources: map[string]*model.RepositorySource{
+				"brew": {Tap: "hashicorp/tap"},
+			}}
+		})
+
+		It("taps the repository", func() {
+			commandStubs.Register("brew tap$", "homebrew/core\n")
+			commandStubs.Register("brew tap hashicorp/tap", "")
+			Expect(subject("brew")).To(BeTrue())
+		})
+
+		It("untaps the repository when it is removed", func() {
+			commandStubs.Register("brew untap hashicorp/tap", "")
+			Expect(remove("brew")).To(Succeed())
+		})
+
+		It("does nothing when the repository is tapped", func() {
+			commandStubs.Register("brew tap$", "hashicorp/tap\nhomebrew/core\n")
+			Expect(subject("brew")).To(BeFalse())
+		})
+	})
+
+	It("returns an unsupported error when the repository is not available for the manager", func() {
+		repo = &model.Repository{Name: "docker", Sources: map[string]*model.RepositorySource{"apt": {URL: "https://download.docker.com/linux/ubuntu"}}}
+
+		_, err := subject("dnf")
+		Expect(err).To(MatchError(pkgmanager.ErrUnsupported))
+		Expect(err).To(MatchError("repository `docker` is not supported on this system for dnf"))
+	})
+})
@@ -9,3 +9,7 @@ var (
 func SupportedPackageManagers() []string {
 	return supportedPackageManagers
 }
+
+func Vendor() string {
+	return "darwin"
+}
@@ -8,6 +8,8 @@ import (
 	"regexp"
 	"slices"
 	"strings"
+
+	"github.com/drew-english/system-configurator/pkg/logging"
 )
 
 var (
@@ -29,12 +31,17 @@ func SupportedPackageManagers() []string {
 	return managers[linuxArch()]
 }
 
+// Vendor returns the detected linux vendor, or `other` when it is unknown or unsupported.
+func Vendor() string {
+	return linuxArch()
+}
+
 func linuxArch() (vendor string) {
 	vendor = "other"
 
 	f, err := os.Open(OSReleasePath)
 	if err != nil {
-		// TODO: log warning
+		logging.Warn("unable to read os-release, checking all package managers", "path", OSReleasePath, "error", err)
 		return
 	}
 	defer f.Close()
@@ -47,10 +54,10 @@ func linuxArch() (vendor string) {
 	}
 
 	if !slices.Contains(supportedVendors, vendor) {
-		// TODO: log warning
+		logging.Warn("unsupported linux vendor, checking all package managers", "vendor", vendor)
 		vendor = "other"
 	}
 
-	// TODO: log warning
+	logging.Debug("detected linux vendor", "vendor", vendor, "managers", managers[vendor])
 	return
 }
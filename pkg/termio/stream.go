@@ -0,0 +1,204 @@
+package termio
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+const (
+	spinnerInterval = 100 * time.Millisecond
+	clearLine       = "\r\x1b[K"
+	defaultWidth    = 80
+)
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+type (
+	// OutputStream displays the output of a long running process under a label.
+	OutputStream struct {
+		Out    io.Writer
+		ErrOut io.Writer
+
+		close func()
+	}
+
+	// prefixWriter writes each complete line with a prefix.
+	prefixWriter struct {
+		mu     sync.Mutex
+		w      io.Writer
+		prefix string
+		buf    []byte
+	}
+
+	// spinner collapses output into a single, continuously redrawn line.
+	spinner struct {
+		mu    sync.Mutex
+		w     io.Writer
+		label string
+		style *style
+		line  string
+		buf   []byte
+		frame int
+
+		done chan struct{}
+		wg   sync.WaitGroup
+	}
+)
+
+// Stream creates an OutputStream for label. When stdout is a terminal, output is
+// collapsed into a spinner showing the latest line, otherwise every line is
+// written with a `[label]` prefix.
+func (io *IO) Stream(label string) *OutputStream {
+	if io.StdoutIsTerminal() {
+		s := newSpinner(io.Out, label, io.Style())
+		return &OutputStream{Out: s, ErrOut: s, close: s.stop}
+	}
+
+	prefix := io.Style().Gray("["+label+"]") + " "
+	out := &prefixWriter{w: io.Out, prefix: prefix}
+	errOut := &prefixWriter{w: io.ErrOut, prefix: prefix}
+	return &OutputStream{
+		Out:    out,
+		ErrOut: errOut,
+		close: func() {
+			out.flush()
+			errOut.flush()
+		},
+	}
+}
+
+// Close flushes any remaining output and clears the spinner, if shown.
+func (s *OutputStream) Close() {
+	s.close()
+}
+
+func (pw *prefixWriter) Write(p []byte) (int, error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	pw.buf = append(pw.buf, p...)
+	for {
+		i := bytes.IndexByte(pw.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		if err := pw.writeLine(pw.buf[:i]); err != nil {
+			return len(p), err
+		}
+
+		pw.buf = pw.buf[i+1:]
+	}
+
+	return len(p), nil
+}
+
+func (pw *prefixWriter) flush() {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	if len(pw.buf) > 0 {
+		pw.writeLine(pw.buf)
+		pw.buf = nil
+	}
+}
+
+func (pw *prefixWriter) writeLine(line []byte) error {
+	_, err := io.WriteString(pw.w, pw.prefix+strings.TrimSuffix(string(line), "\r")+"\n")
+	return err
+}
+
+func newSpinner(w io.Writer, label string, style *style) *spinner {
+	s := &spinner{
+		w:     w,
+		label: label,
+		style: style,
+		done:  make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.spin()
+	return s
+}
+
+func (s *spinner) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buf = append(s.buf, p...)
+	for {
+		i := bytes.IndexAny(s.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+
+		if line := strings.TrimSpace(string(s.buf[:i])); line != "" {
+			s.line = line
+		}
+
+		s.buf = s.buf[i+1:]
+	}
+
+	s.render()
+	return len(p), nil
+}
+
+func (s *spinner) spin() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(spinnerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			s.frame = (s.frame + 1) % len(spinnerFrames)
+			s.render()
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *spinner) stop() {
+	close(s.done)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	io.WriteString(s.w, clearLine)
+}
+
+func (s *spinner) render() {
+	text := s.label
+	if s.line != "" {
+		text += ": " + s.line
+	}
+
+	// Leave room for the frame and trailing cursor so the line never wraps, truncating by rune so
+	// multi-byte characters are never split.
+	if width := s.width() - 3; width > 0 && utf8.RuneCountInString(text) > width {
+		text = string([]rune(text)[:width])
+	}
+
+	io.WriteString(s.w, clearLine+s.style.Cyan(spinnerFrames[s.frame])+" "+text)
+}
+
+func (s *spinner) width() int {
+	if f, ok := s.w.(*os.File); ok {
+		if width, _, err := term.GetSize(int(f.Fd())); err == nil {
+			return width
+		}
+	}
+
+	return defaultWidth
+}
@@ -0,0 +1,91 @@
+package termio_test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/drew-english/system-configurator/pkg/termio"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stream", func() {
+	var (
+		io             *termio.IO
+		stdout, stderr *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		stdout, stderr = bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+		io = termio.NewWithConfig(&termio.Config{ColorDisabled: true})
+		io.Out = stdout
+		io.ErrOut = stderr
+	})
+
+	Context("when stdout is not a terminal", func() {
+		It("prefixes each line with the label", func() {
+			stream := io.Stream("some-package")
+			stream.Out.Write([]byte("Reading package lists...\nBuilding dependency"))
+			stream.Out.Write([]byte(" tree...\n"))
+			stream.ErrOut.Write([]byte("some warning\n"))
+			stream.Close()
+
+			Expect(stdout.String()).To(Equal("[some-package] Reading package lists...\n[some-package] Building dependency tree...\n"))
+			Expect(stderr.String()).To(Equal("[some-package] some warning\n"))
+		})
+
+		It("flushes partial lines on close", func() {
+			stream := io.Stream("some-package")
+			stream.Out.Write([]byte("Done"))
+			Expect(stdout.String()).To(BeEmpty())
+
+			stream.Close()
+			Expect(stdout.String()).To(Equal("[some-package] Done\n"))
+		})
+	})
+	Context("when stdout is a terminal", func() {
+		var out *os.File
+
+		BeforeEach(func() {
+			var err error
+			out, err = os.Create(GinkgoT().TempDir() + "/stdout")
+			Expect(err).ToNot(HaveOccurred())
+			DeferCleanup(out.Close)
+			io.Out = out
+
+			original := termio.IsTerminal
+			termio.IsTerminal = func(*os.File) bool { return true }
+			DeferCleanup(func() { termio.IsTerminal = original })
+		})
+
+		readOut := func() string {
+			data, err := os.ReadFile(out.Name())
+			Expect(err).ToNot(HaveOccurred())
+			return string(data)
+		}
+
+		It("redraws the latest line and clears it on close", func() {
+			stream := io.Stream("some-package")
+			stream.Out.Write([]byte("Reading package lists...\nBuilding dependency tree...\n"))
+			stream.Close()
+
+			Expect(readOut()).To(HavePrefix("\r\x1b[K⠋ some-package: Building dependency tree..."))
+			Expect(readOut()).To(HaveSuffix("\r\x1b[K"))
+		})
+
+		It("truncates long lines without splitting multi-byte characters", func() {
+			stream := io.Stream("some-package")
+			stream.Out.Write([]byte(strings.Repeat("é", 100) + "\n"))
+			stream.Close()
+
+			frames := strings.Split(readOut(), "\r\x1b[K")
+			Expect(frames).To(ContainElement("⠋ some-package: " + strings.Repeat("é", 77-len("some-package: "))))
+			for _, frame := range frames {
+				Expect(utf8.ValidString(frame)).To(BeTrue())
+			}
+		})
+	})
+})
@@ -10,11 +10,13 @@ import (
 
 var (
 	DefaultIO = New()
-)
 
-func IsTerminal(f *os.File) bool {
-	return term.IsTerminal(int(f.Fd()))
-}
+	// IsTerminal reports whether f is a terminal.
+	// Provides a hook for testing
+	IsTerminal = func(f *os.File) bool {
+		return term.IsTerminal(int(f.Fd()))
+	}
+)
 
 func IsInteractive() bool {
 	return DefaultIO.IsInteractive()
@@ -42,6 +44,10 @@ func WithNeverPrompt(v bool) *IO {
 	return io
 }
 
+func Stream(label string) *OutputStream {
+	return DefaultIO.Stream(label)
+}
+
 func Print(s string) {
 	DefaultIO.Print(s)
 }
@@ -70,6 +70,10 @@ func (s *baseCommandStub) Output() ([]byte, error) {
 	panic("not implemented, use derived command stubs")
 }
 
+func (s *baseCommandStub) Stream(string) error {
+	panic("not implemented, use derived command stubs")
+}
+
 func (s *successCommandStub) Run() error {
 	return nil
 }
@@ -78,6 +82,10 @@ func (s *successCommandStub) Output() ([]byte, error) {
 	return []byte(s.stdout), nil
 }
 
+func (s *successCommandStub) Stream(string) error {
+	return nil
+}
+
 func (s *errorCommandStub) Run() error {
 	return run.CmdError{
 		Args:   s.matchedCmd,
@@ -86,6 +94,10 @@ func (s *errorCommandStub) Run() error {
 	}
 }
 
+func (s *errorCommandStub) Stream(string) error {
+	return s.Run()
+}
+
 func (s *errorCommandStub) Output() ([]byte, error) {
 	return []byte(s.stderr), run.CmdError{
 		Args:   s.matchedCmd,
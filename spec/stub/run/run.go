@@ -3,6 +3,7 @@
 package run
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
@@ -67,7 +68,7 @@ func StubFind(pattern string, errResult error) func() {
 func registerCommandHook(cs *CommandStubManager) func() {
 	originalCommand := run.Command
 
-	run.Command = func(name string, arg ...string) run.RunCmd {
+	run.Command = func(ctx context.Context, name string, arg ...string) run.RunCmd {
 		args := append([]string{name}, arg...)
 		stub := cs.find(args)
 		if stub == nil {
@@ -47,3 +47,45 @@ func wrapWriteConfiguration(err error) {
 		return err
 	}
 }
+
+// StubStateLocation points the state directory at dir until the returned function is called.
+func StubStateLocation(dir string) func() {
+	originalStateLocation := store.StateDefaultLocation
+	store.StateDefaultLocation = func() (string, error) {
+		return dir, nil
+	}
+
+	return func() {
+		store.StateDefaultLocation = originalStateLocation
+	}
+}
+
+// StubLayers points the system and user configuration at the given directories and searches for a
+// project configuration from projectDir, until the returned function is called.
+func StubLayers(systemDir, userDir, projectDir string) func() {
+	originalSystemLocation := store.SystemDefaultLocation
+	originalUserLocation := store.LocalDefaultLocation
+	originalSearchDir := store.ProjectSearchDir
+
+	store.SystemDefaultLocation = func() string { return systemDir }
+	store.LocalDefaultLocation = func() (string, error) { return userDir, nil }
+	store.ProjectSearchDir = func() (string, error) { return projectDir, nil }
+
+	return func() {
+		store.SystemDefaultLocation = originalSystemLocation
+		store.LocalDefaultLocation = originalUserLocation
+		store.ProjectSearchDir = originalSearchDir
+	}
+}
+
+// StubLockPath points the lockfile at lockPath until the returned function is called.
+func StubLockPath(lockPath string) func() {
+	originalLockPath := store.LockPath
+	store.LockPath = func() (string, error) {
+		return lockPath, nil
+	}
+
+	return func() {
+		store.LockPath = originalLockPath
+	}
+}